@@ -0,0 +1,442 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/mbenabdallah/shared/genre"
+)
+
+// --- Relay Connection Pagination ---
+//
+// animeConnection and Anime.episodeConnection follow the Relay cursor
+// connection specification: first/after page forward, last/before page
+// backward, and pageInfo reports whether more items exist on either side.
+
+const maxPageSize = 100
+
+// PageInfo is the Relay pageInfo object.
+type PageInfo struct {
+	HasNextPage     bool   `json:"hasNextPage"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
+	StartCursor     string `json:"startCursor"`
+	EndCursor       string `json:"endCursor"`
+}
+
+// AnimeEdge is an edge of AnimeConnection.
+type AnimeEdge struct {
+	Cursor string `json:"cursor"`
+	Node   Anime  `json:"node"`
+}
+
+// AnimeConnection is a page of anime.
+type AnimeConnection struct {
+	Edges      []AnimeEdge `json:"edges"`
+	PageInfo   PageInfo    `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+// AnimeEpisodeEdge is an edge of AnimeEpisodeConnection.
+type AnimeEpisodeEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   AnimeEpisode `json:"node"`
+}
+
+// AnimeEpisodeConnection is a page of an anime's episodes.
+type AnimeEpisodeConnection struct {
+	Edges      []AnimeEpisodeEdge `json:"edges"`
+	PageInfo   PageInfo           `json:"pageInfo"`
+	TotalCount int                `json:"totalCount"`
+}
+
+// connectionArgs holds the Relay pagination arguments.
+type connectionArgs struct {
+	First, Last   *int
+	After, Before string
+}
+
+// connectionArgsConfig returns the first/after/last/before argument definitions.
+func connectionArgsConfig() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"first":  &graphql.ArgumentConfig{Type: graphql.Int, Description: "Return the first n items (at most 100)"},
+		"after":  &graphql.ArgumentConfig{Type: graphql.String, Description: "Return items after this cursor"},
+		"last":   &graphql.ArgumentConfig{Type: graphql.Int, Description: "Return the last n items (at most 100)"},
+		"before": &graphql.ArgumentConfig{Type: graphql.String, Description: "Return items before this cursor"},
+	}
+}
+
+func parseConnectionArgs(args map[string]interface{}) (connectionArgs, error) {
+	var c connectionArgs
+	for _, name := range []string{"first", "last"} {
+		n, ok := args[name].(int)
+		if !ok {
+			continue
+		}
+		if n < 0 {
+			return c, fmt.Errorf("%s must not be negative", name)
+		}
+		if n > maxPageSize {
+			return c, fmt.Errorf("%s must not exceed %d", name, maxPageSize)
+		}
+		if name == "first" {
+			c.First = &n
+		} else {
+			c.Last = &n
+		}
+	}
+	c.After, _ = args["after"].(string)
+	c.Before, _ = args["before"].(string)
+	return c, nil
+}
+
+// cursorLocator maps a cursor to positions in the ordered item list:
+// afterIdx is the index of the first item after the cursor and beforeIdx
+// the index one past the last item before it.
+type cursorLocator func(cursor string) (afterIdx, beforeIdx int, err error)
+
+// sliceWindow applies the Relay pagination algorithm to n ordered items and
+// returns the [start, end) range of the page.
+func sliceWindow(n int, args connectionArgs, locate cursorLocator) (int, int, error) {
+	start, end := 0, n
+	if args.After != "" {
+		after, _, err := locate(args.After)
+		if err != nil {
+			return 0, 0, err
+		}
+		start = after
+	}
+	if args.Before != "" {
+		_, before, err := locate(args.Before)
+		if err != nil {
+			return 0, 0, err
+		}
+		end = before
+	}
+	if end < start {
+		end = start
+	}
+	if args.First != nil && end-start > *args.First {
+		end = start + *args.First
+	}
+	if args.Last != nil && end-start > *args.Last {
+		start = end - *args.Last
+	}
+	return start, end, nil
+}
+
+func encodeCursor(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid cursor")
+	}
+	return nil
+}
+
+// --- animeConnection ---
+
+// animeCursor records the sort position of an anime for keyset paging, so
+// cursors stay valid when anime are added or removed between requests.
+type animeCursor struct {
+	Order string `json:"o"`
+	Key   string `json:"k"`
+	ID    int    `json:"i"`
+}
+
+// animeOrder describes how animeConnection sorts its results.
+type animeOrder struct {
+	Field string // ID, TITLE or EPISODES
+	Desc  bool
+}
+
+func (o animeOrder) String() string {
+	if o.Desc {
+		return o.Field + ":DESC"
+	}
+	return o.Field + ":ASC"
+}
+
+func (o animeOrder) key(a Anime) string {
+	switch o.Field {
+	case "TITLE":
+		return strings.ToLower(a.Title)
+	case "EPISODES":
+		return fmt.Sprintf("%010d", a.Episodes)
+	default:
+		return ""
+	}
+}
+
+// less orders by key, then ID, so the ordering is total.
+func (o animeOrder) less(aKey string, aID int, bKey string, bID int) bool {
+	if aKey != bKey {
+		return (aKey < bKey) != o.Desc
+	}
+	return (aID < bID) != o.Desc
+}
+
+// animeFilter narrows animeConnection results.
+type animeFilter struct {
+	TitleContains string
+	Genre         string // genre slug
+}
+
+func (f animeFilter) matches(a Anime) bool {
+	if f.TitleContains != "" && !strings.Contains(strings.ToLower(a.Title), strings.ToLower(f.TitleContains)) {
+		return false
+	}
+	if f.Genre != "" && !a.Genres.Contains(f.Genre) {
+		return false
+	}
+	return true
+}
+
+var pageInfoType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"startCursor":     &graphql.Field{Type: graphql.String},
+			"endCursor":       &graphql.Field{Type: graphql.String},
+		},
+	},
+)
+
+var animeEdgeType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "AnimeEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: animeType},
+		},
+	},
+)
+
+var animeConnectionType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "AnimeConnection",
+		Fields: graphql.Fields{
+			"edges":      &graphql.Field{Type: graphql.NewList(animeEdgeType)},
+			"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+			"totalCount": &graphql.Field{Type: graphql.Int, Description: "Number of anime matching the filter"},
+		},
+	},
+)
+
+var animeFilterType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "AnimeFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"titleContains": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"genre":         &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Genre slug, name or alias"},
+		},
+	},
+)
+
+var animeOrderFieldEnum = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name: "AnimeOrderField",
+		Values: graphql.EnumValueConfigMap{
+			"ID":       &graphql.EnumValueConfig{Value: "ID"},
+			"TITLE":    &graphql.EnumValueConfig{Value: "TITLE"},
+			"EPISODES": &graphql.EnumValueConfig{Value: "EPISODES"},
+		},
+	},
+)
+
+var orderDirectionEnum = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name: "OrderDirection",
+		Values: graphql.EnumValueConfigMap{
+			"ASC":  &graphql.EnumValueConfig{Value: "ASC"},
+			"DESC": &graphql.EnumValueConfig{Value: "DESC"},
+		},
+	},
+)
+
+var animeOrderType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "AnimeOrder",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"field":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(animeOrderFieldEnum)},
+			"direction": &graphql.InputObjectFieldConfig{Type: orderDirectionEnum, DefaultValue: "ASC"},
+		},
+	},
+)
+
+var animeConnectionField = &graphql.Field{
+	Type:        animeConnectionType,
+	Description: "Get a page of anime (Relay cursor connection)",
+	Args: func() graphql.FieldConfigArgument {
+		args := connectionArgsConfig()
+		args["filter"] = &graphql.ArgumentConfig{Type: animeFilterType}
+		args["orderBy"] = &graphql.ArgumentConfig{Type: animeOrderType}
+		return args
+	}(),
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		log.Printf("Resolving animeConnection query with args: %v", params.Args)
+		args, err := parseConnectionArgs(params.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		var filter animeFilter
+		if f, ok := params.Args["filter"].(map[string]interface{}); ok {
+			filter.TitleContains, _ = f["titleContains"].(string)
+			if name, _ := f["genre"].(string); strings.TrimSpace(name) != "" {
+				g, ok := genre.Lookup(name)
+				if !ok {
+					return nil, fmt.Errorf("unknown genre %q", name)
+				}
+				filter.Genre = g.Slug
+			}
+		}
+		order := animeOrder{Field: "ID"}
+		if o, ok := params.Args["orderBy"].(map[string]interface{}); ok {
+			order.Field, _ = o["field"].(string)
+			dir, _ := o["direction"].(string)
+			order.Desc = dir == "DESC"
+		}
+
+		all, err := animeRepo.List()
+		if err != nil {
+			return nil, err
+		}
+		matched := make([]Anime, 0, len(all))
+		for _, a := range all {
+			if filter.matches(a) {
+				matched = append(matched, a)
+			}
+		}
+		sort.Slice(matched, func(i, j int) bool {
+			return order.less(order.key(matched[i]), matched[i].ID, order.key(matched[j]), matched[j].ID)
+		})
+
+		locate := func(cursor string) (int, int, error) {
+			var c animeCursor
+			if err := decodeCursor(cursor, &c); err != nil {
+				return 0, 0, err
+			}
+			if c.Order != order.String() {
+				return 0, 0, fmt.Errorf("cursor does not match orderBy")
+			}
+			after := sort.Search(len(matched), func(i int) bool {
+				return order.less(c.Key, c.ID, order.key(matched[i]), matched[i].ID)
+			})
+			before := sort.Search(len(matched), func(i int) bool {
+				return !order.less(order.key(matched[i]), matched[i].ID, c.Key, c.ID)
+			})
+			return after, before, nil
+		}
+		start, end, err := sliceWindow(len(matched), args, locate)
+		if err != nil {
+			return nil, err
+		}
+
+		conn := AnimeConnection{Edges: []AnimeEdge{}, TotalCount: len(matched)}
+		for _, a := range matched[start:end] {
+			cursor := encodeCursor(animeCursor{Order: order.String(), Key: order.key(a), ID: a.ID})
+			conn.Edges = append(conn.Edges, AnimeEdge{Cursor: cursor, Node: a})
+		}
+		conn.PageInfo = pageInfo(len(conn.Edges), start, end, len(matched),
+			func(i int) string { return conn.Edges[i].Cursor })
+		return conn, nil
+	},
+}
+
+// pageInfo builds the Relay pageInfo for the [start, end) window of n items.
+func pageInfo(edges, start, end, n int, cursorAt func(i int) string) PageInfo {
+	info := PageInfo{HasPreviousPage: start > 0, HasNextPage: end < n}
+	if edges > 0 {
+		info.StartCursor = cursorAt(0)
+		info.EndCursor = cursorAt(edges - 1)
+	}
+	return info
+}
+
+// --- Anime.episodeConnection ---
+
+// episodeCursor identifies an episode within its anime's episode list.
+type episodeCursor struct {
+	AnimeID   int `json:"a"`
+	EpisodeID int `json:"e"`
+}
+
+var animeEpisodeEdgeType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "AnimeEpisodeEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: animeEpisodeType},
+		},
+	},
+)
+
+var animeEpisodeConnectionType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "AnimeEpisodeConnection",
+		Fields: graphql.Fields{
+			"edges":      &graphql.Field{Type: graphql.NewList(animeEpisodeEdgeType)},
+			"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+			"totalCount": &graphql.Field{Type: graphql.Int, Description: "Number of episodes in the list"},
+		},
+	},
+)
+
+var episodeConnectionField = &graphql.Field{
+	Type:        animeEpisodeConnectionType,
+	Description: "Page through the anime's episodes in list order (Relay cursor connection)",
+	Args:        connectionArgsConfig(),
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		anime, ok := params.Source.(Anime)
+		if !ok {
+			return nil, nil
+		}
+		args, err := parseConnectionArgs(params.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		episodes := anime.EpisodeList
+		locate := func(cursor string) (int, int, error) {
+			var c episodeCursor
+			if err := decodeCursor(cursor, &c); err != nil {
+				return 0, 0, err
+			}
+			if c.AnimeID != anime.ID {
+				return 0, 0, fmt.Errorf("cursor belongs to a different anime")
+			}
+			idx := findAnimeEpisode(episodes, c.EpisodeID)
+			if idx < 0 {
+				return 0, 0, fmt.Errorf("cursor refers to an episode that no longer exists")
+			}
+			return idx + 1, idx, nil
+		}
+		start, end, err := sliceWindow(len(episodes), args, locate)
+		if err != nil {
+			return nil, err
+		}
+
+		conn := AnimeEpisodeConnection{Edges: []AnimeEpisodeEdge{}, TotalCount: len(episodes)}
+		for _, ep := range episodes[start:end] {
+			cursor := encodeCursor(episodeCursor{AnimeID: anime.ID, EpisodeID: ep.ID})
+			conn.Edges = append(conn.Edges, AnimeEpisodeEdge{Cursor: cursor, Node: ep})
+		}
+		conn.PageInfo = pageInfo(len(conn.Edges), start, end, len(episodes),
+			func(i int) string { return conn.Edges[i].Cursor })
+		return conn, nil
+	},
+}
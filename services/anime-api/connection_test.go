@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// newConnectionFixtures adds three anime to the test repository, giving IDs
+// 1 to 5 in the title order Akira (3), Cowboy Bebop (4), Ergo Proxy (2),
+// Monster (1), Paprika (5), and three episodes to Monster.
+func newConnectionFixtures(t *testing.T) {
+	t.Helper()
+	newTestRepository(t)
+	for _, a := range []Anime{
+		{Title: "Akira", Genre: "Sci-Fi", Episodes: 1},
+		{Title: "Cowboy Bebop", Genre: "Sci-Fi", Episodes: 26},
+		{Title: "Paprika", Genre: "Sci-Fi", Episodes: 1},
+	} {
+		if _, err := animeRepo.Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, title := range []string{"Herr Dr. Tenma", "Downfall", "Murder Case"} {
+		q := fmt.Sprintf(`mutation { addAnimeEpisode(animeId: 1, input: {title: %q}) { id } }`, title)
+		if res := executeQuery(editorContext, q, schema); len(res.Errors) > 0 {
+			t.Fatal(res.Errors)
+		}
+	}
+}
+
+type testConnection struct {
+	Edges []struct {
+		Cursor string
+		Node   struct{ ID int }
+	}
+	PageInfo   PageInfo
+	TotalCount int
+}
+
+func (c testConnection) ids() []int {
+	ids := make([]int, len(c.Edges))
+	for i, e := range c.Edges {
+		ids[i] = e.Node.ID
+	}
+	return ids
+}
+
+const connectionSelection = `edges { cursor node { id } } pageInfo { hasNextPage hasPreviousPage startCursor endCursor } totalCount`
+
+// animePage runs animeConnection with the given arguments.
+func animePage(t *testing.T, args string) (testConnection, error) {
+	t.Helper()
+	res := executeQuery(context.Background(), `{ animeConnection(`+args+`) { `+connectionSelection+` } }`, schema)
+	if len(res.Errors) > 0 {
+		return testConnection{}, res.Errors[0]
+	}
+	var data struct{ AnimeConnection testConnection }
+	body, _ := json.Marshal(res.Data)
+	if err := json.Unmarshal(body, &data); err != nil {
+		t.Fatal(err)
+	}
+	return data.AnimeConnection, nil
+}
+
+func mustPage(t *testing.T, args string) testConnection {
+	t.Helper()
+	page, err := animePage(t, args)
+	if err != nil {
+		t.Fatalf("animeConnection(%s): %v", args, err)
+	}
+	return page
+}
+
+func TestAnimeConnectionForward(t *testing.T) {
+	newConnectionFixtures(t)
+
+	var seen []int
+	args := `first: 2, orderBy: {field: TITLE}`
+	for pages := 0; ; pages++ {
+		page := mustPage(t, args)
+		if page.TotalCount != 5 {
+			t.Errorf("totalCount = %d, want 5", page.TotalCount)
+		}
+		if page.PageInfo.HasPreviousPage != (pages > 0) {
+			t.Errorf("page %d: hasPreviousPage = %v", pages, page.PageInfo.HasPreviousPage)
+		}
+		seen = append(seen, page.ids()...)
+		if !page.PageInfo.HasNextPage {
+			break
+		}
+		if pages > 3 {
+			t.Fatal("paging does not terminate")
+		}
+		args = fmt.Sprintf(`first: 2, after: %q, orderBy: {field: TITLE}`, page.PageInfo.EndCursor)
+	}
+	if got := fmt.Sprint(seen); got != "[3 4 2 1 5]" {
+		t.Errorf("anime by title = %s, want [3 4 2 1 5]", got)
+	}
+
+	desc := mustPage(t, `first: 2, orderBy: {field: EPISODES, direction: DESC}`)
+	if got := fmt.Sprint(desc.ids()); got != "[1 4]" {
+		t.Errorf("anime by episodes descending = %s, want [1 4]", got)
+	}
+	filtered := mustPage(t, `filter: {genre: "science fiction", titleContains: "A"}`)
+	if got := fmt.Sprint(filtered.ids()); got != "[3 5]" || filtered.TotalCount != 2 {
+		t.Errorf("filtered anime = %s (totalCount %d), want [3 5]", got, filtered.TotalCount)
+	}
+}
+
+func TestAnimeConnectionBackward(t *testing.T) {
+	newConnectionFixtures(t)
+
+	page := mustPage(t, `last: 2`)
+	if got := fmt.Sprint(page.ids()); got != "[4 5]" || !page.PageInfo.HasPreviousPage || page.PageInfo.HasNextPage {
+		t.Fatalf("last: 2 = %s %+v", got, page.PageInfo)
+	}
+	page = mustPage(t, fmt.Sprintf(`last: 2, before: %q`, page.PageInfo.StartCursor))
+	if got := fmt.Sprint(page.ids()); got != "[2 3]" || !page.PageInfo.HasPreviousPage || !page.PageInfo.HasNextPage {
+		t.Fatalf("second page backward = %s %+v", got, page.PageInfo)
+	}
+	page = mustPage(t, fmt.Sprintf(`last: 2, before: %q`, page.PageInfo.StartCursor))
+	if got := fmt.Sprint(page.ids()); got != "[1]" || page.PageInfo.HasPreviousPage {
+		t.Fatalf("first page backward = %s %+v", got, page.PageInfo)
+	}
+
+	// after and before together bound the window on both sides
+	all := mustPage(t, `first: 100`)
+	window := mustPage(t, fmt.Sprintf(`after: %q, before: %q`, all.Edges[0].Cursor, all.Edges[4].Cursor))
+	if got := fmt.Sprint(window.ids()); got != "[2 3 4]" {
+		t.Errorf("window between anime 1 and 5 = %s, want [2 3 4]", got)
+	}
+}
+
+func TestAnimeConnectionBoundaries(t *testing.T) {
+	newConnectionFixtures(t)
+	all := mustPage(t, `first: 100`)
+	first, last := all.Edges[0].Cursor, all.Edges[4].Cursor
+
+	for _, c := range []struct {
+		args      string
+		ids       string
+		next, prv bool
+	}{
+		{fmt.Sprintf(`after: %q`, last), "[]", false, true},
+		{fmt.Sprintf(`before: %q`, first), "[]", true, false},
+		{fmt.Sprintf(`after: %q, before: %q`, last, first), "[]", false, true},
+		{`first: 0`, "[]", true, false},
+		{`first: 100`, "[1 2 3 4 5]", false, false},
+		{`first: 2, last: 1`, "[2]", true, true},
+	} {
+		page := mustPage(t, c.args)
+		if got := fmt.Sprint(page.ids()); got != c.ids || page.PageInfo.HasNextPage != c.next || page.PageInfo.HasPreviousPage != c.prv {
+			t.Errorf("%s: %s %+v, want %s next=%v previous=%v", c.args, got, page.PageInfo, c.ids, c.next, c.prv)
+		}
+		if len(page.Edges) == 0 && (page.PageInfo.StartCursor != "" || page.PageInfo.EndCursor != "") {
+			t.Errorf("%s: cursors set on an empty page: %+v", c.args, page.PageInfo)
+		}
+	}
+
+	// Keyset cursors stay valid when the anime they point at is deleted
+	cursor := all.Edges[2].Cursor
+	if _, err := animeRepo.Delete(3); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(mustPage(t, fmt.Sprintf(`after: %q`, cursor)).ids()); got != "[4 5]" {
+		t.Errorf("after the cursor of a deleted anime = %s, want [4 5]", got)
+	}
+}
+
+func TestAnimeConnectionInvalidArguments(t *testing.T) {
+	newConnectionFixtures(t)
+	byTitle := mustPage(t, `first: 1, orderBy: {field: TITLE}`).PageInfo.EndCursor
+	notJSON := base64.RawURLEncoding.EncodeToString([]byte("not json"))
+
+	for _, args := range []string{
+		`after: "not a cursor!"`,
+		fmt.Sprintf(`after: %q`, notJSON),
+		fmt.Sprintf(`before: %q`, byTitle), // issued for another ordering
+		fmt.Sprintf(`after: %q, orderBy: {field: TITLE, direction: DESC}`, byTitle),
+		`first: -1`,
+		`last: 101`,
+		`filter: {genre: "polka"}`,
+	} {
+		if _, err := animePage(t, args); err == nil {
+			t.Errorf("animeConnection(%s) succeeded", args)
+		}
+	}
+}
+
+func TestEpisodeConnection(t *testing.T) {
+	newConnectionFixtures(t)
+
+	episodePage := func(animeID int, args string) (testConnection, error) {
+		t.Helper()
+		q := fmt.Sprintf(`{ anime(id: %d) { episodeConnection(%s) { %s } } }`, animeID, args, connectionSelection)
+		res := executeQuery(context.Background(), q, schema)
+		if len(res.Errors) > 0 {
+			return testConnection{}, res.Errors[0]
+		}
+		var data struct {
+			Anime struct{ EpisodeConnection testConnection }
+		}
+		body, _ := json.Marshal(res.Data)
+		json.Unmarshal(body, &data)
+		return data.Anime.EpisodeConnection, nil
+	}
+
+	page, err := episodePage(1, `first: 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(page.ids()); got != "[1 2]" || !page.PageInfo.HasNextPage || page.TotalCount != 3 {
+		t.Fatalf("first: 2 = %s %+v", got, page.PageInfo)
+	}
+	second := page.PageInfo.EndCursor
+	page, err = episodePage(1, fmt.Sprintf(`first: 2, after: %q`, second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(page.ids()); got != "[3]" || page.PageInfo.HasNextPage || !page.PageInfo.HasPreviousPage {
+		t.Errorf("first: 2 after episode 2 = %s %+v", got, page.PageInfo)
+	}
+	page, _ = episodePage(1, fmt.Sprintf(`last: 5, before: %q`, second))
+	if got := fmt.Sprint(page.ids()); got != "[1]" || page.PageInfo.HasPreviousPage {
+		t.Errorf("before episode 2 = %s %+v", got, page.PageInfo)
+	}
+	if page, _ := episodePage(2, `first: 10`); len(page.Edges) != 0 || page.PageInfo.HasNextPage {
+		t.Errorf("episodes of an anime without episodes = %+v", page)
+	}
+
+	// Episode cursors only apply to their own anime and existing episodes
+	if _, err := episodePage(2, fmt.Sprintf(`after: %q`, second)); err == nil {
+		t.Error("cursor of another anime accepted")
+	}
+	executeQuery(editorContext, `mutation { removeAnimeEpisode(animeId: 1, episodeId: 2) { id } }`, schema)
+	if _, err := episodePage(1, fmt.Sprintf(`after: %q`, second)); err == nil {
+		t.Error("cursor of a removed episode accepted")
+	}
+}
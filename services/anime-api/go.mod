@@ -3,6 +3,16 @@ module github.com/mbenabdallah/anime-api
 go 1.24.2
 
 require (
+	github.com/gorilla/websocket v1.5.3
 	github.com/graphql-go/graphql v0.8.1
 	github.com/graphql-go/handler v0.2.4
+	github.com/mbenabdallah/shared v0.0.0
 )
+
+require (
+	go.etcd.io/bbolt v1.4.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/mbenabdallah/shared => ../shared
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/genre"
+)
+
+// GraphQL AnimeInput Type (all fields optional; only provided fields are updated)
+var animeInputType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "AnimeInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+			"genre": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Legacy genre string; replaces the genre list",
+			},
+			"genres": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "Genre slugs, names or aliases; takes precedence over genre",
+			},
+			"episodes": &graphql.InputObjectFieldConfig{
+				Type:        graphql.Int,
+				Description: "Total episode count; cannot be lower than the length of episodeList",
+			},
+			"coverUrl": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+// GraphQL AnimeEpisodeInput Type
+var animeEpisodeInputType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "AnimeEpisodeInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"watchUrl": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+// requireRole wraps the resolvers of fields so they fail unless the request
+// was authenticated with role (see auth.Check).
+func requireRole(role string, fields graphql.Fields) graphql.Fields {
+	for name, field := range fields {
+		resolve := field.Resolve
+		field.Resolve = func(params graphql.ResolveParams) (interface{}, error) {
+			if err := auth.Check(params.Context, role); err != nil {
+				log.Printf("Rejected %s mutation: %v", name, err)
+				return nil, err
+			}
+			return resolve(params)
+		}
+	}
+	return fields
+}
+
+// findAnimeEpisode returns the index of the episode with the given ID, or -1.
+func findAnimeEpisode(episodes []AnimeEpisode, episodeID int) int {
+	for i, ep := range episodes {
+		if ep.ID == episodeID {
+			return i
+		}
+	}
+	return -1
+}
+
+// stringList converts a GraphQL list argument to a genre list.
+func stringList(arg interface{}) genre.List {
+	values, _ := arg.([]interface{})
+	list := make(genre.List, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// episodeFromInput converts an AnimeEpisodeInput argument to an AnimeEpisode.
+func episodeFromInput(input map[string]interface{}) (AnimeEpisode, error) {
+	title, _ := input["title"].(string)
+	if strings.TrimSpace(title) == "" {
+		return AnimeEpisode{}, fmt.Errorf("episode title is required")
+	}
+	watchURL, _ := input["watchUrl"].(string)
+	return AnimeEpisode{Title: title, WatchURL: watchURL}, nil
+}
+
+var updateAnimeField = &graphql.Field{
+	Type:        animeType,
+	Description: "Update the fields of an existing anime",
+	Args: graphql.FieldConfigArgument{
+		"id": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"input": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(animeInputType),
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		log.Printf("Resolving updateAnime mutation with args: %v", params.Args)
+		id, _ := params.Args["id"].(int)
+		input, _ := params.Args["input"].(map[string]interface{})
+
+		updated, err := animeRepo.Update(id, func(a *Anime) error {
+			if title, ok := input["title"].(string); ok {
+				if strings.TrimSpace(title) == "" {
+					return fmt.Errorf("title cannot be empty")
+				}
+				a.Title = title
+			}
+			if genres, ok := input["genres"]; ok && genres != nil {
+				a.Genres = stringList(genres)
+			} else if legacyGenre, ok := input["genre"].(string); ok {
+				a.Genres, a.Genre = nil, legacyGenre
+			}
+			if episodes, ok := input["episodes"].(int); ok {
+				if episodes < len(a.EpisodeList) {
+					return fmt.Errorf("episodes (%d) cannot be lower than the number of listed episodes (%d)", episodes, len(a.EpisodeList))
+				}
+				a.Episodes = episodes
+			}
+			if coverURL, ok := input["coverUrl"].(string); ok {
+				a.CoverURL = coverURL
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		animeEvents.Publish(topicAnimeUpdated, updated)
+		return updated, nil
+	},
+}
+
+var deleteAnimeField = &graphql.Field{
+	Type:        animeType,
+	Description: "Delete an anime and return it",
+	Args: graphql.FieldConfigArgument{
+		"id": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		log.Printf("Resolving deleteAnime mutation with args: %v", params.Args)
+		id, _ := params.Args["id"].(int)
+		deleted, err := animeRepo.Delete(id)
+		if err != nil {
+			return nil, err
+		}
+		// Reviews can no longer be posted, so purge the existing ones
+		if _, err := reviewRepo.DeleteItem(id); err != nil {
+			log.Printf("Error deleting reviews of anime %d: %v", id, err)
+		}
+		animeEvents.Publish(topicAnimeDeleted, deleted)
+		return deleted, nil
+	},
+}
+
+var addAnimeEpisodeField = &graphql.Field{
+	Type:        animeEpisodeType,
+	Description: "Append an episode to an anime; the episode ID is assigned by the server",
+	Args: graphql.FieldConfigArgument{
+		"animeId": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"input": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(animeEpisodeInputType),
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		log.Printf("Resolving addAnimeEpisode mutation with args: %v", params.Args)
+		animeID, _ := params.Args["animeId"].(int)
+		input, _ := params.Args["input"].(map[string]interface{})
+		ep, err := episodeFromInput(input)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := animeRepo.Update(animeID, func(a *Anime) error {
+			var err error
+			if ep.ID, err = animeRepo.nextEpisodeID(a); err != nil {
+				return err
+			}
+			a.EpisodeList = append(a.EpisodeList, ep)
+			// Episodes is the total count, which may exceed the listed
+			// episodes for a show that is still being catalogued.
+			if a.Episodes < len(a.EpisodeList) {
+				a.Episodes = len(a.EpisodeList)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		animeEvents.Publish(topicEpisodeAdded, EpisodeAddedEvent{Anime: updated, Episode: ep})
+		animeEvents.Publish(topicAnimeUpdated, updated)
+		return ep, nil
+	},
+}
+
+var updateAnimeEpisodeField = &graphql.Field{
+	Type:        animeEpisodeType,
+	Description: "Replace the title and watch URL of an episode",
+	Args: graphql.FieldConfigArgument{
+		"animeId": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"episodeId": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"input": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(animeEpisodeInputType),
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		log.Printf("Resolving updateAnimeEpisode mutation with args: %v", params.Args)
+		animeID, _ := params.Args["animeId"].(int)
+		episodeID, _ := params.Args["episodeId"].(int)
+		input, _ := params.Args["input"].(map[string]interface{})
+		ep, err := episodeFromInput(input)
+		if err != nil {
+			return nil, err
+		}
+		ep.ID = episodeID
+
+		updated, err := animeRepo.Update(animeID, func(a *Anime) error {
+			idx := findAnimeEpisode(a.EpisodeList, episodeID)
+			if idx < 0 {
+				return fmt.Errorf("episode with id %d not found in anime %d", episodeID, animeID)
+			}
+			a.EpisodeList[idx] = ep
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		animeEvents.Publish(topicAnimeUpdated, updated)
+		return ep, nil
+	},
+}
+
+var removeAnimeEpisodeField = &graphql.Field{
+	Type:        animeType,
+	Description: "Remove an episode from an anime and return the updated anime",
+	Args: graphql.FieldConfigArgument{
+		"animeId": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"episodeId": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		log.Printf("Resolving removeAnimeEpisode mutation with args: %v", params.Args)
+		animeID, _ := params.Args["animeId"].(int)
+		episodeID, _ := params.Args["episodeId"].(int)
+
+		updated, err := animeRepo.Update(animeID, func(a *Anime) error {
+			idx := findAnimeEpisode(a.EpisodeList, episodeID)
+			if idx < 0 {
+				return fmt.Errorf("episode with id %d not found in anime %d", episodeID, animeID)
+			}
+			if _, err := animeRepo.recordEpisodeIDs(a); err != nil {
+				return err
+			}
+			// A fully listed anime stays fully listed; otherwise the total
+			// count is left alone.
+			if a.Episodes == len(a.EpisodeList) {
+				a.Episodes--
+			}
+			a.EpisodeList = append(a.EpisodeList[:idx], a.EpisodeList[idx+1:]...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := reviewRepo.DeleteEpisode(animeID, episodeID); err != nil {
+			log.Printf("Error deleting reviews of episode %d of anime %d: %v", episodeID, animeID, err)
+		}
+		animeEvents.Publish(topicAnimeUpdated, updated)
+		return updated, nil
+	},
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+)
+
+func TestCatalogueMutationsRequireEditor(t *testing.T) {
+	viewer := auth.NewContext(context.Background(), &auth.Claims{Subject: "viewer"})
+
+	for _, q := range []string{
+		`mutation { addAnime(title: "Mushishi", genre: "Fantasy", episodes: 26) { id } }`,
+		`mutation { updateAnime(id: 1, input: {title: "Monster (2004)"}) { id } }`,
+		`mutation { deleteAnime(id: 2) { id } }`,
+		`mutation { addAnimeEpisode(animeId: 1, input: {title: "Herr Dr. Tenma"}) { id } }`,
+		`mutation { updateAnimeEpisode(animeId: 1, episodeId: 1, input: {title: "Dr. Tenma"}) { id } }`,
+		`mutation { removeAnimeEpisode(animeId: 1, episodeId: 1) { id } }`,
+	} {
+		newTestRepository(t)
+		executeQuery(editorContext, `mutation { addAnimeEpisode(animeId: 1, input: {title: "Pilot"}) { id } }`, schema)
+		before, _ := animeRepo.List()
+
+		for name, ctx := range map[string]context.Context{"anonymous": context.Background(), "viewer": viewer} {
+			if res := executeQuery(ctx, q, schema); len(res.Errors) == 0 {
+				t.Errorf("%s: %s succeeded", name, q)
+			}
+		}
+		if after, _ := animeRepo.List(); fmt.Sprint(after) != fmt.Sprint(before) {
+			t.Errorf("rejected %s changed the catalogue", q)
+		}
+		if res := executeQuery(editorContext, q, schema); len(res.Errors) > 0 {
+			t.Errorf("editor: %s: %v", q, res.Errors)
+		}
+	}
+}
+
+func TestMutationsPublishEvents(t *testing.T) {
+	newTestRepository(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	added := animeEvents.Subscribe(ctx, topicAnimeAdded)
+	updated := animeEvents.Subscribe(ctx, topicAnimeUpdated)
+	deleted := animeEvents.Subscribe(ctx, topicAnimeDeleted)
+
+	expect := func(events chan interface{}, topic string, id int) {
+		t.Helper()
+		select {
+		case payload := <-events:
+			if a, _ := payload.(Anime); a.ID != id {
+				t.Errorf("%s event for anime %d, want %d", topic, a.ID, id)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("no %s event", topic)
+		}
+	}
+
+	executeQuery(editorContext, `mutation { addAnime(title: "Mushishi", genre: "Fantasy", episodes: 26) { id } }`, schema)
+	expect(added, topicAnimeAdded, 3)
+	executeQuery(editorContext, `mutation { updateAnime(id: 3, input: {episodes: 46}) { id } }`, schema)
+	expect(updated, topicAnimeUpdated, 3)
+	executeQuery(editorContext, `mutation { deleteAnime(id: 3) { id } }`, schema)
+	expect(deleted, topicAnimeDeleted, 3)
+
+	// A failed mutation publishes nothing
+	executeQuery(editorContext, `mutation { deleteAnime(id: 3) { id } }`, schema)
+	select {
+	case payload := <-deleted:
+		t.Errorf("deleting a missing anime published %v", payload)
+	default:
+	}
+}
+
+func TestEpisodeMutations(t *testing.T) {
+	newTestRepository(t)
+
+	for _, q := range []string{
+		`mutation { addAnimeEpisode(animeId: 2, input: {title: "Pulse of the Awakening"}) { id } }`,
+		`mutation { addAnimeEpisode(animeId: 2, input: {title: "Confession"}) { id } }`,
+		`mutation { updateAnimeEpisode(animeId: 2, episodeId: 2, input: {title: "Confession", watchUrl: "https://example.com/ep2"}) { id } }`,
+		`mutation { removeAnimeEpisode(animeId: 2, episodeId: 1) { id } }`,
+	} {
+		if res := executeQuery(editorContext, q, schema); len(res.Errors) > 0 {
+			t.Fatalf("%s: %v", q, res.Errors)
+		}
+	}
+	for _, q := range []string{
+		`mutation { addAnimeEpisode(animeId: 2, input: {title: " "}) { id } }`,
+		`mutation { addAnimeEpisode(animeId: 9, input: {title: "Missing"}) { id } }`,
+		`mutation { updateAnimeEpisode(animeId: 2, episodeId: 1, input: {title: "Removed"}) { id } }`,
+		`mutation { removeAnimeEpisode(animeId: 2, episodeId: 1) { id } }`,
+		`mutation { updateAnime(id: 2, input: {title: ""}) { id } }`,
+		`mutation { updateAnime(id: 2, input: {episodes: 0}) { id } }`,
+	} {
+		if res := executeQuery(editorContext, q, schema); len(res.Errors) == 0 {
+			t.Errorf("%s succeeded", q)
+		}
+	}
+
+	a, _, _ := animeRepo.Get(2)
+	if len(a.EpisodeList) != 1 || a.EpisodeList[0].ID != 2 || a.EpisodeList[0].WatchURL != "https://example.com/ep2" || a.Episodes != 23 {
+		t.Errorf("anime after episode mutations = %+v", a)
+	}
+}
+
+func TestEpisodeIDsAreNotReused(t *testing.T) {
+	newTestRepository(t)
+	// A fixture episode whose ID was never handed out by the repository
+	if _, err := animeRepo.Update(2, func(a *Anime) error {
+		a.EpisodeList = []AnimeEpisode{{ID: 1, Title: "Pulse of the Awakening"}, {ID: 5, Title: "Confession"}}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	addEpisode := func(animeID int, title string) int {
+		t.Helper()
+		var data struct {
+			AddAnimeEpisode struct{ ID int }
+		}
+		res := executeQuery(editorContext, fmt.Sprintf(`mutation { addAnimeEpisode(animeId: %d, input: {title: %q}) { id } }`, animeID, title), schema)
+		if len(res.Errors) > 0 {
+			t.Fatal(res.Errors)
+		}
+		body, _ := json.Marshal(res.Data)
+		json.Unmarshal(body, &data)
+		return data.AddAnimeEpisode.ID
+	}
+	remove := func(animeID, episodeID int) {
+		t.Helper()
+		if res := executeQuery(editorContext, fmt.Sprintf(`mutation { removeAnimeEpisode(animeId: %d, episodeId: %d) { id } }`, animeID, episodeID), schema); len(res.Errors) > 0 {
+			t.Fatal(res.Errors)
+		}
+	}
+
+	remove(2, 5)
+	if id := addEpisode(2, "Ophelia"); id != 6 {
+		t.Errorf("episode added after removing fixture episode 5 got ID %d, want 6", id)
+	}
+	remove(2, 6)
+	if id := addEpisode(2, "Ophelia"); id != 7 {
+		t.Errorf("episode added after removing the last episode got ID %d, want 7", id)
+	}
+
+	// Each anime counts on its own, and a deleted anime's count goes with it
+	if id := addEpisode(1, "Herr Dr. Tenma"); id != 1 {
+		t.Errorf("first episode of anime 1 got ID %d, want 1", id)
+	}
+	executeQuery(editorContext, `mutation { deleteAnime(id: 2) { id } }`, schema)
+	if _, ok, _ := animeRepo.episodeIDs.Get(2); ok {
+		t.Error("episode ID counter of a deleted anime kept")
+	}
+}
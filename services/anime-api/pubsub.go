@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Subscription topics published by the mutations
+const (
+	topicAnimeAdded   = "animeAdded"
+	topicAnimeUpdated = "animeUpdated"
+	topicAnimeDeleted = "animeDeleted"
+	topicEpisodeAdded = "episodeAdded"
+)
+
+// subscriberBuffer is how many undelivered events a subscriber may queue
+// before further events to it are dropped.
+const subscriberBuffer = 16
+
+// pubSub is an in-process publish/subscribe hub used to fan mutation events
+// out to GraphQL subscriptions.
+type pubSub struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan interface{}]struct{}
+}
+
+// Event hub shared by the mutations and the subscription resolvers
+var animeEvents = newPubSub()
+
+func newPubSub() *pubSub {
+	return &pubSub{subs: make(map[string]map[chan interface{}]struct{})}
+}
+
+// Subscribe returns a channel receiving every payload published to topic
+// until ctx is cancelled, at which point the channel is closed.
+func (ps *pubSub) Subscribe(ctx context.Context, topic string) chan interface{} {
+	ch := make(chan interface{}, subscriberBuffer)
+
+	ps.mu.Lock()
+	if ps.subs[topic] == nil {
+		ps.subs[topic] = make(map[chan interface{}]struct{})
+	}
+	ps.subs[topic][ch] = struct{}{}
+	ps.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		ps.mu.Lock()
+		delete(ps.subs[topic], ch)
+		ps.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// Publish delivers payload to all current subscribers of topic without
+// blocking; a subscriber whose buffer is full misses the event.
+func (ps *pubSub) Publish(topic string, payload interface{}) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	for ch := range ps.subs[topic] {
+		select {
+		case ch <- payload:
+		default:
+			log.Printf("Dropping %s event for slow subscriber", topic)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mbenabdallah/shared/genre"
+	"github.com/mbenabdallah/shared/reviews"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// animeRepository is the concurrency-safe access point for anime data used
+// by the GraphQL resolvers. Single store calls are already atomic, but
+// operations spanning several calls (allocating an ID and inserting, reading
+// and rewriting a record) must not interleave with each other, so they run
+// under the write lock while plain reads share the read lock.
+type animeRepository struct {
+	mu    sync.RWMutex
+	store storage.Store[Anime]
+	// Highest episode ID assigned so far, per anime ID. Episode IDs are never
+	// reused, so progress and reviews of a removed episode cannot attach to
+	// a later one.
+	episodeIDs storage.Store[int]
+}
+
+// Repository used by the resolvers, set up in main
+var animeRepo *animeRepository
+
+func newAnimeRepository(store storage.Store[Anime], episodeIDs storage.Store[int]) *animeRepository {
+	return &animeRepository{store: store, episodeIDs: episodeIDs}
+}
+
+// List returns all anime ordered by ID.
+func (r *animeRepository) List() ([]Anime, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.store.List()
+}
+
+// Get returns the anime with the given ID and whether it exists.
+func (r *animeRepository) Get(id int) (Anime, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.store.Get(id)
+}
+
+// Add assigns the next free ID to a and stores it.
+func (r *animeRepository) Add(a Anime) (Anime, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := genre.Canonicalize(a.genreFields()); err != nil {
+		return Anime{}, err
+	}
+	id, err := r.store.NextID()
+	if err != nil {
+		return Anime{}, err
+	}
+	a.ID = id
+	if a.EpisodeList == nil {
+		a.EpisodeList = []AnimeEpisode{}
+	}
+	if err := r.store.Put(a.ID, a); err != nil {
+		return Anime{}, err
+	}
+	return a, nil
+}
+
+// errAnimeNotFound is returned by repository operations on a missing anime.
+type errAnimeNotFound int
+
+func (e errAnimeNotFound) Error() string {
+	return fmt.Sprintf("anime with id %d not found", int(e))
+}
+
+// Update loads the anime with the given ID, applies mutate to it and stores
+// the result, all under the write lock. If mutate returns an error nothing
+// is written.
+func (r *animeRepository) Update(id int, mutate func(a *Anime) error) (Anime, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, exists, err := r.store.Get(id)
+	if err != nil {
+		return Anime{}, err
+	}
+	if !exists {
+		return Anime{}, errAnimeNotFound(id)
+	}
+	if err := mutate(&a); err != nil {
+		return Anime{}, err
+	}
+	a.ID = id // The ID is server-assigned and never changes
+	if err := genre.Canonicalize(a.genreFields()); err != nil {
+		return Anime{}, err
+	}
+	if err := r.store.Put(id, a); err != nil {
+		return Anime{}, err
+	}
+	return a, nil
+}
+
+// Delete removes the anime with the given ID and returns it.
+func (r *animeRepository) Delete(id int) (Anime, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, exists, err := r.store.Get(id)
+	if err != nil {
+		return Anime{}, err
+	}
+	if !exists {
+		return Anime{}, errAnimeNotFound(id)
+	}
+	if _, err := r.store.Delete(id); err != nil {
+		return Anime{}, err
+	}
+	if _, err := r.episodeIDs.Delete(id); err != nil { // anime IDs are never reused
+		return Anime{}, err
+	}
+	return a, nil
+}
+
+// recordEpisodeIDs raises the highest episode ID recorded for a to cover its
+// listed episodes, which may come from fixtures, and returns it. It is called
+// before an episode is removed so its ID stays taken. It must run inside an
+// Update.
+func (r *animeRepository) recordEpisodeIDs(a *Anime) (int, error) {
+	last, _, err := r.episodeIDs.Get(a.ID)
+	if err != nil {
+		return 0, err
+	}
+	highest := last
+	for _, ep := range a.EpisodeList {
+		highest = max(highest, ep.ID)
+	}
+	if highest > last {
+		err = r.episodeIDs.Put(a.ID, highest)
+	}
+	return highest, err
+}
+
+// nextEpisodeID reserves the ID to assign to a newly added episode of a. It
+// must run inside an Update.
+func (r *animeRepository) nextEpisodeID(a *Anime) (int, error) {
+	last, err := r.recordEpisodeIDs(a)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.episodeIDs.Put(a.ID, last+1); err != nil {
+		return 0, err
+	}
+	return last + 1, nil
+}
+
+// Reviewing runs fn under the read lock after checking that the anime, and
+// the episode if t names one, exists. Deletes take the write lock, so fn
+// cannot store a review of an anime that is being deleted.
+func (r *animeRepository) Reviewing(t reviews.Target, fn func() error) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, exists, err := r.store.Get(t.ItemID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errAnimeNotFound(t.ItemID)
+	}
+	if t.EpisodeID != 0 && findAnimeEpisode(a.EpisodeList, t.EpisodeID) < 0 {
+		return fmt.Errorf("episode with id %d not found in anime %d", t.EpisodeID, t.ItemID)
+	}
+	return fn()
+}
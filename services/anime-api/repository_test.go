@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/reviews"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// editorContext authenticates test mutations as an editor.
+var editorContext = auth.NewContext(context.Background(), &auth.Claims{Subject: "test", Roles: []string{auth.RoleEditor}})
+
+// newTestRepository installs fresh in-memory repositories holding two anime
+// and no reviews.
+func newTestRepository(t *testing.T) {
+	t.Helper()
+	backend := storage.NewMemoryBackend()
+	store, err := storage.New[Anime](backend, "anime")
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	for _, a := range []Anime{
+		{ID: 1, Title: "Monster", Genre: "Drama", Episodes: 74, EpisodeList: []AnimeEpisode{}},
+		{ID: 2, Title: "Ergo Proxy", Genre: "Mystery", Episodes: 23, EpisodeList: []AnimeEpisode{}},
+	} {
+		if err := store.Put(a.ID, a); err != nil {
+			t.Fatalf("seeding store: %v", err)
+		}
+	}
+	episodeIDs, err := storage.New[int](backend, "anime_episode_ids")
+	if err != nil {
+		t.Fatalf("opening episode ID store: %v", err)
+	}
+	animeRepo = newAnimeRepository(store, episodeIDs)
+	if reviewRepo, err = reviews.Open(backend); err != nil {
+		t.Fatalf("opening review store: %v", err)
+	}
+}
+
+// TestConcurrentAddAnimeAndQuery runs addAnime mutations alongside anime and
+// animeList queries. Run with -race to detect unsynchronized access.
+func TestConcurrentAddAnimeAndQuery(t *testing.T) {
+	newTestRepository(t)
+
+	const writers, readers, perWorker = 8, 8, 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, (writers+readers)*perWorker)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				q := fmt.Sprintf(`mutation { addAnime(title: "Title %d-%d", genre: "Action", episodes: 12) { id } }`, w, i)
+				if res := executeQuery(editorContext, q, schema); len(res.Errors) > 0 {
+					errs <- fmt.Errorf("addAnime: %v", res.Errors)
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if res := executeQuery(context.Background(), `{ anime(id: 1) { id title } }`, schema); len(res.Errors) > 0 {
+					errs <- fmt.Errorf("anime: %v", res.Errors)
+				}
+				if res := executeQuery(context.Background(), `{ animeList { id title } }`, schema); len(res.Errors) > 0 {
+					errs <- fmt.Errorf("animeList: %v", res.Errors)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	all, err := animeRepo.List()
+	if err != nil {
+		t.Fatalf("listing anime: %v", err)
+	}
+	if want := 2 + writers*perWorker; len(all) != want {
+		t.Fatalf("got %d anime, want %d (lost writes)", len(all), want)
+	}
+	seen := make(map[int]bool, len(all))
+	for _, a := range all {
+		if seen[a.ID] {
+			t.Fatalf("duplicate anime ID %d", a.ID)
+		}
+		seen[a.ID] = true
+	}
+}
+
+func TestMutationsRequireEditor(t *testing.T) {
+	newTestRepository(t)
+	viewer := auth.NewContext(context.Background(), &auth.Claims{Subject: "viewer"})
+
+	for _, c := range []struct {
+		name string
+		ctx  context.Context
+		ok   bool
+	}{
+		{"anonymous", context.Background(), false},
+		{"viewer", viewer, false},
+		{"editor", editorContext, true},
+	} {
+		res := executeQuery(c.ctx, `mutation { updateAnime(id: 1, input: {coverUrl: "x"}) { id } }`, schema)
+		if ok := len(res.Errors) == 0; ok != c.ok {
+			t.Errorf("%s: updateAnime errors %v, want success %v", c.name, res.Errors, c.ok)
+		}
+	}
+	if res := executeQuery(context.Background(), `{ anime(id: 1) { coverUrl } }`, schema); len(res.Errors) > 0 {
+		t.Errorf("anonymous query: %v", res.Errors)
+	}
+}
+
+func TestReviews(t *testing.T) {
+	newTestRepository(t)
+	alice := auth.NewContext(context.Background(), &auth.Claims{Subject: "alice"})
+	bob := auth.NewContext(context.Background(), &auth.Claims{Subject: "bob"})
+
+	if res := executeQuery(context.Background(), `mutation { postReview(animeId: 1, rating: 7) { id } }`, schema); len(res.Errors) == 0 {
+		t.Error("anonymous postReview succeeded")
+	}
+	for _, c := range []struct {
+		ctx context.Context
+		q   string
+	}{
+		{alice, `mutation { postReview(animeId: 1, rating: 9, text: "Masterpiece") { id } }`},
+		{bob, `mutation { postReview(animeId: 1, rating: 6) { id } }`},
+	} {
+		if res := executeQuery(c.ctx, c.q, schema); len(res.Errors) > 0 {
+			t.Fatalf("postReview: %v", res.Errors)
+		}
+	}
+	for _, q := range []string{
+		`mutation { postReview(animeId: 1, rating: 10) { id } }`,              // already reviewed
+		`mutation { postReview(animeId: 1, rating: 11) { id } }`,              // out of range
+		`mutation { postReview(animeId: 9, rating: 5) { id } }`,               // unknown anime
+		`mutation { postReview(animeId: 1, episodeId: 3, rating: 5) { id } }`, // unknown episode
+	} {
+		if res := executeQuery(alice, q, schema); len(res.Errors) == 0 {
+			t.Errorf("%s succeeded", q)
+		}
+	}
+
+	res := executeQuery(context.Background(), `{ anime(id: 1) { averageRating reviewCount reviews { userId episodeId } } }`, schema)
+	if len(res.Errors) > 0 {
+		t.Fatalf("anime query: %v", res.Errors)
+	}
+	got := fmt.Sprint(res.Data)
+	if want := "map[anime:map[averageRating:7.5 reviewCount:2 reviews:[map[episodeId:<nil> userId:bob] map[episodeId:<nil> userId:alice]]]]"; got != want {
+		t.Errorf("anime reviews = %s, want %s", got, want)
+	}
+
+	// Deleting the anime deletes its reviews
+	executeQuery(editorContext, `mutation { deleteAnime(id: 1) { id } }`, schema)
+	if summary, _ := reviewRepo.Summary(reviews.Item(1)); summary.Count != 0 {
+		t.Errorf("reviews left after deleteAnime: %+v", summary)
+	}
+}
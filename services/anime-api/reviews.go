@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/graphql-go/graphql"
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/reviews"
+)
+
+// --- Reviews ---
+
+// Reviews of anime and their episodes, set up in main. Any signed-in user
+// may review; each user has one review per anime and per episode.
+var reviewRepo *reviews.Repository
+
+// GraphQL Review Type
+var reviewType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "Review",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+			"animeId": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					review, _ := p.Source.(reviews.Review)
+					return review.ItemID, nil
+				},
+			},
+			"episodeId": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "The reviewed episode, null for a review of the whole anime",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if review, _ := p.Source.(reviews.Review); review.EpisodeID != 0 {
+						return review.EpisodeID, nil
+					}
+					return nil, nil
+				},
+			},
+			"userId": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"rating": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: fmt.Sprintf("Rating from %d to %d", reviews.MinRating, reviews.MaxRating),
+			},
+			"text": &graphql.Field{
+				Type: graphql.String,
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+			},
+			"updatedAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+			},
+		},
+	},
+)
+
+// reviewTargetArgs are the arguments naming what a review is about.
+func reviewTargetArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"animeId": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"episodeId": &graphql.ArgumentConfig{
+			Type:        graphql.Int,
+			Description: "Review an episode instead of the whole anime",
+		},
+	}
+}
+
+// reviewTarget reads the animeId and episodeId arguments.
+func reviewTarget(args map[string]interface{}) reviews.Target {
+	animeID, _ := args["animeId"].(int)
+	episodeID, _ := args["episodeId"].(int)
+	return reviews.Episode(animeID, episodeID)
+}
+
+// sourceTarget returns the review target of an Anime field: the anime, or one
+// of its episodes when the field's episodeId argument is given.
+func sourceTarget(p graphql.ResolveParams) reviews.Target {
+	anime, _ := p.Source.(Anime)
+	episodeID, _ := p.Args["episodeId"].(int)
+	return reviews.Episode(anime.ID, episodeID)
+}
+
+// episodeArg selects an episode in the review fields of Anime.
+var episodeArg = graphql.FieldConfigArgument{
+	"episodeId": &graphql.ArgumentConfig{
+		Type:        graphql.Int,
+		Description: "Report on an episode instead of the whole anime",
+	},
+}
+
+var averageRatingField = &graphql.Field{
+	Type:        graphql.Float,
+	Description: "Average rating of the reviews, null without reviews",
+	Args:        episodeArg,
+	Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		summary, err := reviewRepo.Summary(sourceTarget(p))
+		if err != nil || summary.Count == 0 {
+			return nil, err
+		}
+		return *summary.Average(), nil
+	},
+}
+
+var reviewCountField = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.Int),
+	Description: "Number of reviews",
+	Args:        episodeArg,
+	Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		summary, err := reviewRepo.Summary(sourceTarget(p))
+		return summary.Count, err
+	},
+}
+
+var reviewsField = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reviewType))),
+	Description: "Reviews, newest first",
+	Args:        episodeArg,
+	Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return reviewRepo.List(sourceTarget(p))
+	},
+}
+
+// requireUser wraps the resolvers of fields so they fail unless the request
+// was authenticated.
+func requireUser(fields graphql.Fields) graphql.Fields {
+	for name, field := range fields {
+		resolve := field.Resolve
+		field.Resolve = func(params graphql.ResolveParams) (interface{}, error) {
+			if _, ok := auth.FromContext(params.Context); !ok {
+				log.Printf("Rejected %s mutation: %v", name, auth.ErrUnauthenticated)
+				return nil, auth.ErrUnauthenticated
+			}
+			return resolve(params)
+		}
+	}
+	return fields
+}
+
+// withFields returns fields with more added.
+func withFields(fields, more graphql.Fields) graphql.Fields {
+	for name, field := range more {
+		fields[name] = field
+	}
+	return fields
+}
+
+// reviewInputArgs are the arguments of postReview and updateReview.
+func reviewInputArgs() graphql.FieldConfigArgument {
+	args := reviewTargetArgs()
+	args["rating"] = &graphql.ArgumentConfig{
+		Type: graphql.NewNonNull(graphql.Int),
+	}
+	args["text"] = &graphql.ArgumentConfig{
+		Type: graphql.String,
+	}
+	return args
+}
+
+// writeReview runs a review change for the authenticated user once the
+// anime (or episode) is known to exist.
+func writeReview(params graphql.ResolveParams, change func(user string, t reviews.Target) (interface{}, error)) (interface{}, error) {
+	claims, _ := auth.FromContext(params.Context)
+	target := reviewTarget(params.Args)
+	var result interface{}
+	err := animeRepo.Reviewing(target, func() (err error) {
+		result, err = change(claims.Subject, target)
+		return err
+	})
+	switch {
+	case errors.Is(err, reviews.ErrExists):
+		return nil, fmt.Errorf("you have already reviewed this; use updateReview to change your review")
+	case errors.Is(err, reviews.ErrNotFound):
+		return nil, fmt.Errorf("you have not reviewed this")
+	case err != nil:
+		return nil, err
+	}
+	return result, nil
+}
+
+// Review mutations, open to every signed-in user
+var reviewMutations = requireUser(graphql.Fields{
+	"postReview": &graphql.Field{
+		Type:        reviewType,
+		Description: "Review an anime or one of its episodes; a user reviews each at most once",
+		Args:        reviewInputArgs(),
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			log.Printf("Resolving postReview mutation with args: %v", params.Args)
+			rating, _ := params.Args["rating"].(int)
+			text, _ := params.Args["text"].(string)
+			return writeReview(params, func(user string, t reviews.Target) (interface{}, error) {
+				return reviewRepo.Post(user, t, rating, text)
+			})
+		},
+	},
+	"updateReview": &graphql.Field{
+		Type:        reviewType,
+		Description: "Replace the rating and text of your review",
+		Args:        reviewInputArgs(),
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			log.Printf("Resolving updateReview mutation with args: %v", params.Args)
+			rating, _ := params.Args["rating"].(int)
+			text, _ := params.Args["text"].(string)
+			return writeReview(params, func(user string, t reviews.Target) (interface{}, error) {
+				return reviewRepo.Update(user, t, rating, text)
+			})
+		},
+	},
+	"deleteReview": &graphql.Field{
+		Type:        graphql.Boolean,
+		Description: "Delete your review",
+		Args:        reviewTargetArgs(),
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			log.Printf("Resolving deleteReview mutation with args: %v", params.Args)
+			return writeReview(params, func(user string, t reviews.Target) (interface{}, error) {
+				return true, reviewRepo.Delete(user, t)
+			})
+		},
+	},
+})
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+
+	"github.com/graphql-go/graphql"
+)
+
+// EpisodeAddedEvent is the payload of the episodeAdded subscription.
+type EpisodeAddedEvent struct {
+	Anime   Anime        `json:"anime"`
+	Episode AnimeEpisode `json:"episode"`
+}
+
+var episodeAddedEventType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "EpisodeAddedEvent",
+		Fields: graphql.Fields{
+			"anime":   &graphql.Field{Type: animeType},
+			"episode": &graphql.Field{Type: animeEpisodeType},
+		},
+	},
+)
+
+// subscribeTopic returns a Subscribe function streaming topic events,
+// keeping only those accepted by keep (nil keeps everything).
+func subscribeTopic(topic string, keep func(params graphql.ResolveParams, payload interface{}) bool) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		log.Printf("Starting %s subscription with args: %v", topic, params.Args)
+		events := animeEvents.Subscribe(params.Context, topic)
+		if keep == nil {
+			return events, nil
+		}
+		filtered := make(chan interface{})
+		go func() {
+			defer close(filtered)
+			for payload := range events {
+				if !keep(params, payload) {
+					continue
+				}
+				select {
+				case filtered <- payload:
+				case <-params.Context.Done():
+					return
+				}
+			}
+		}()
+		return filtered, nil
+	}
+}
+
+// resolveEvent returns the published payload as the field value.
+func resolveEvent(params graphql.ResolveParams) (interface{}, error) {
+	return params.Source, nil
+}
+
+// GraphQL Root Subscription
+var rootSubscription = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "RootSubscription",
+		Fields: graphql.Fields{
+			"animeAdded": &graphql.Field{
+				Type:        animeType,
+				Description: "Emitted when an anime is added",
+				Subscribe:   subscribeTopic(topicAnimeAdded, nil),
+				Resolve:     resolveEvent,
+			},
+			"animeUpdated": &graphql.Field{
+				Type:        animeType,
+				Description: "Emitted when an anime or its episode list changes, optionally for a single anime",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Subscribe: subscribeTopic(topicAnimeUpdated, func(params graphql.ResolveParams, payload interface{}) bool {
+					id, ok := params.Args["id"].(int)
+					anime, _ := payload.(Anime)
+					return !ok || anime.ID == id
+				}),
+				Resolve: resolveEvent,
+			},
+			"animeDeleted": &graphql.Field{
+				Type:        animeType,
+				Description: "Emitted with the deleted anime when an anime is deleted",
+				Subscribe:   subscribeTopic(topicAnimeDeleted, nil),
+				Resolve:     resolveEvent,
+			},
+			"episodeAdded": &graphql.Field{
+				Type:        episodeAddedEventType,
+				Description: "Emitted when an episode is added, optionally for a single anime",
+				Args: graphql.FieldConfigArgument{
+					"animeId": &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Subscribe: subscribeTopic(topicEpisodeAdded, func(params graphql.ResolveParams, payload interface{}) bool {
+					id, ok := params.Args["animeId"].(int)
+					event, _ := payload.(EpisodeAddedEvent)
+					return !ok || event.Anime.ID == id
+				}),
+				Resolve: resolveEvent,
+			},
+		},
+	},
+)
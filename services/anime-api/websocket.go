@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/mbenabdallah/shared/auth"
+)
+
+// --- GraphQL over WebSocket (graphql-transport-ws protocol) ---
+//
+// See https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+
+const graphqlTransportWS = "graphql-transport-ws"
+
+// connectionInitTimeout is how long a client may wait before sending connection_init.
+var connectionInitTimeout = 3 * time.Second
+
+// Message types of the graphql-transport-ws protocol
+const (
+	msgConnectionInit = "connection_init"
+	msgConnectionAck  = "connection_ack"
+	msgPing           = "ping"
+	msgPong           = "pong"
+	msgSubscribe      = "subscribe"
+	msgNext           = "next"
+	msgError          = "error"
+	msgComplete       = "complete"
+)
+
+// Close codes defined by the protocol
+const (
+	closeBadRequest         = 4400
+	closeUnauthorized       = 4401
+	closeForbidden          = 4403
+	closeInitTimeout        = 4408
+	closeSubscriberExists   = 4409
+	closeTooManyInitRequest = 4429
+)
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// initPayload is the connection_init payload. Browsers cannot set headers on
+// WebSocket requests, so clients may pass their bearer token here instead.
+type initPayload struct {
+	Authorization string `json:"authorization"`
+}
+
+type subscribePayload struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// wsVerifier checks tokens sent in connection_init payloads.
+var wsVerifier *auth.Verifier
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{graphqlTransportWS},
+}
+
+// wsConnection is a single graphql-transport-ws client connection.
+type wsConnection struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex // gorilla/websocket allows only one concurrent writer
+
+	mu           sync.Mutex
+	acknowledged bool
+	claims       *auth.Claims // from connection_init, if it carried a token
+	operations   map[string]context.CancelFunc
+}
+
+// serveGraphQLWS upgrades the request and runs the protocol until the client disconnects.
+func serveGraphQLWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	if conn.Subprotocol() != graphqlTransportWS {
+		log.Printf("Rejecting WebSocket client without the %s subprotocol", graphqlTransportWS)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseProtocolError, "Subprotocol not acceptable"), time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
+	log.Printf("GraphQL WebSocket connection opened from %s", r.RemoteAddr)
+
+	c := &wsConnection{conn: conn, operations: make(map[string]context.CancelFunc)}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer func() {
+		cancel() // Stops every running operation
+		conn.Close()
+		log.Printf("GraphQL WebSocket connection from %s closed", r.RemoteAddr)
+	}()
+
+	initTimer := time.AfterFunc(connectionInitTimeout, func() {
+		c.mu.Lock()
+		acked := c.acknowledged
+		c.mu.Unlock()
+		if !acked {
+			c.close(closeInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok && !websocket.IsUnexpectedCloseError(err) {
+				c.close(closeBadRequest, "Invalid message received")
+			}
+			return
+		}
+		if !c.handleMessage(ctx, msg) {
+			return
+		}
+	}
+}
+
+// handleMessage processes one client message and reports whether the
+// connection should stay open.
+func (c *wsConnection) handleMessage(ctx context.Context, msg wsMessage) bool {
+	switch msg.Type {
+	case msgConnectionInit:
+		c.mu.Lock()
+		already := c.acknowledged
+		c.acknowledged = true
+		c.mu.Unlock()
+		if already {
+			c.close(closeTooManyInitRequest, "Too many initialisation requests")
+			return false
+		}
+		var init initPayload
+		if len(msg.Payload) > 0 {
+			json.Unmarshal(msg.Payload, &init)
+		}
+		if init.Authorization != "" {
+			token, _ := auth.BearerToken(init.Authorization)
+			claims, err := wsVerifier.Verify(token)
+			if err != nil {
+				log.Printf("Rejecting GraphQL WebSocket connection: %v", err)
+				c.close(closeForbidden, "Forbidden")
+				return false
+			}
+			c.mu.Lock()
+			c.claims = claims
+			c.mu.Unlock()
+		}
+		c.send(wsMessage{Type: msgConnectionAck})
+
+	case msgPing:
+		c.send(wsMessage{Type: msgPong})
+
+	case msgPong:
+		// Nothing to do
+
+	case msgSubscribe:
+		c.mu.Lock()
+		acked := c.acknowledged
+		_, exists := c.operations[msg.ID]
+		c.mu.Unlock()
+		if !acked {
+			c.close(closeUnauthorized, "Unauthorized")
+			return false
+		}
+		if msg.ID == "" {
+			c.close(closeBadRequest, "Subscribe message requires an id")
+			return false
+		}
+		if exists {
+			c.close(closeSubscriberExists, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+			return false
+		}
+		var payload subscribePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.Query == "" {
+			c.close(closeBadRequest, "Invalid subscribe payload")
+			return false
+		}
+		c.startOperation(ctx, msg.ID, payload)
+
+	case msgComplete:
+		c.mu.Lock()
+		if stop, ok := c.operations[msg.ID]; ok {
+			stop()
+			delete(c.operations, msg.ID)
+		}
+		c.mu.Unlock()
+
+	default:
+		c.close(closeBadRequest, fmt.Sprintf("Unexpected message type %q", msg.Type))
+		return false
+	}
+	return true
+}
+
+// startOperation executes a subscribe request. Subscriptions stream a next
+// message per event; queries and mutations produce a single result.
+func (c *wsConnection) startOperation(parent context.Context, id string, payload subscribePayload) {
+	opType, err := operationType(payload.Query, payload.OperationName)
+	if err != nil {
+		c.sendErrors(id, gqlerrors.FormatErrors(err))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	c.mu.Lock()
+	c.operations[id] = cancel
+	if c.claims != nil {
+		ctx = auth.NewContext(ctx, c.claims)
+	}
+	c.mu.Unlock()
+
+	params := graphql.Params{
+		Schema:         schema,
+		RequestString:  payload.Query,
+		VariableValues: payload.Variables,
+		OperationName:  payload.OperationName,
+		Context:        ctx,
+	}
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			_, stillActive := c.operations[id]
+			delete(c.operations, id)
+			c.mu.Unlock()
+			// A complete from the client needs no reply
+			if stillActive && ctx.Err() == nil {
+				c.send(wsMessage{ID: id, Type: msgComplete})
+			}
+			cancel()
+		}()
+
+		if opType != ast.OperationTypeSubscription {
+			c.sendResult(id, graphql.Do(params))
+			return
+		}
+
+		c.streamResults(ctx, id, graphql.Subscribe(params))
+	}()
+}
+
+// streamResults sends a next message per subscription result until the
+// stream ends or ctx is cancelled. An error message terminates the operation,
+// so no result is sent after one.
+func (c *wsConnection) streamResults(ctx context.Context, id string, results chan *graphql.Result) {
+	defer func() {
+		// Drain so the executor goroutine can observe the cancellation and exit
+		go func() {
+			for range results {
+			}
+		}()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case res, ok := <-results:
+			if !ok || !c.sendResult(id, res) {
+				return
+			}
+		}
+	}
+}
+
+// sendResult forwards an execution result, using an error message for
+// results that carry errors but no data (e.g. validation failures). It
+// reports whether the operation is still running.
+func (c *wsConnection) sendResult(id string, res *graphql.Result) bool {
+	if res.Data == nil && len(res.Errors) > 0 {
+		c.sendErrors(id, res.Errors)
+		return false
+	}
+	body, _ := json.Marshal(res)
+	c.send(wsMessage{ID: id, Type: msgNext, Payload: body})
+	return true
+}
+
+func (c *wsConnection) sendErrors(id string, errs []gqlerrors.FormattedError) {
+	body, _ := json.Marshal(errs)
+	c.send(wsMessage{ID: id, Type: msgError, Payload: body})
+	c.mu.Lock()
+	delete(c.operations, id)
+	c.mu.Unlock()
+}
+
+func (c *wsConnection) send(msg wsMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Printf("Error writing WebSocket message: %v", err)
+	}
+}
+
+func (c *wsConnection) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	c.conn.Close()
+}
+
+// operationType returns the type of the operation that will be executed.
+func operationType(query, operationName string) (string, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query)})})
+	if err != nil {
+		return "", err
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation, nil
+		}
+	}
+	return "", fmt.Errorf("operation %q not found in document", operationName)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/mbenabdallah/shared/auth"
+)
+
+var testKey = auth.SigningKey{ID: "test", Algorithm: auth.HS256, Secret: []byte("0123456789abcdef0123456789abcdef")}
+
+// newWSServer serves graphql-transport-ws over the test repository, with
+// connection_init tokens verified against testKey.
+func newWSServer(t *testing.T) string {
+	t.Helper()
+	newTestRepository(t)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	jwks, _ := json.Marshal(auth.JWKS{Keys: []auth.JWK{{
+		Kty: "oct", Kid: testKey.ID, K: base64.RawURLEncoding.EncodeToString(testKey.Secret),
+	}}})
+	if err := os.WriteFile(jwksFile, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if wsVerifier, err = auth.NewVerifier(auth.Config{JWKSFile: jwksFile}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(serveGraphQLWS))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dialWS(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: []string{graphqlTransportWS}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendWS(t *testing.T, conn *websocket.Conn, msg string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readWS returns the next message, failing the test on close or timeout.
+func readWS(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg wsMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	return msg
+}
+
+// expectClose reads until the server closes the connection with code.
+func expectClose(t *testing.T, conn *websocket.Conn, code int) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != code {
+			t.Fatalf("connection ended with %v, want close code %d", err, code)
+		}
+		return
+	}
+}
+
+// initWS completes the connection handshake.
+func initWS(t *testing.T, conn *websocket.Conn, payload string) {
+	t.Helper()
+	sendWS(t, conn, `{"type":"connection_init","payload":`+payload+`}`)
+	if msg := readWS(t, conn); msg.Type != msgConnectionAck {
+		t.Fatalf("got %q, want connection_ack", msg.Type)
+	}
+}
+
+// waitForSubscribers waits until topic has n subscribers.
+func waitForSubscribers(t *testing.T, topic string, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		animeEvents.mu.RLock()
+		got := len(animeEvents.subs[topic])
+		animeEvents.mu.RUnlock()
+		if got == n {
+			return
+		}
+	}
+	t.Fatalf("%s does not reach %d subscribers", topic, n)
+}
+
+func TestWSPingAndRepeatedInit(t *testing.T) {
+	conn := dialWS(t, newWSServer(t))
+	initWS(t, conn, `{}`)
+	sendWS(t, conn, `{"type":"ping"}`)
+	if msg := readWS(t, conn); msg.Type != msgPong {
+		t.Errorf("got %q, want pong", msg.Type)
+	}
+	sendWS(t, conn, `{"type":"connection_init"}`)
+	expectClose(t, conn, closeTooManyInitRequest)
+}
+
+func TestWSSubscription(t *testing.T) {
+	url := newWSServer(t)
+	conn := dialWS(t, url)
+	initWS(t, conn, `{}`)
+
+	sendWS(t, conn, `{"id":"1","type":"subscribe","payload":{"query":"subscription { animeAdded { id title } }"}}`)
+	waitForSubscribers(t, topicAnimeAdded, 1)
+	executeQuery(editorContext, `mutation { addAnime(title: "Mushishi", genre: "Fantasy", episodes: 26) { id } }`, schema)
+
+	msg := readWS(t, conn)
+	if msg.ID != "1" || msg.Type != msgNext || string(msg.Payload) != `{"data":{"animeAdded":{"id":3,"title":"Mushishi"}}}` {
+		t.Fatalf("got %s %s %s, want the added anime", msg.ID, msg.Type, msg.Payload)
+	}
+
+	// Queries over the socket answer once and complete
+	sendWS(t, conn, `{"id":"2","type":"subscribe","payload":{"query":"{ anime(id: 3) { title } }"}}`)
+	if msg := readWS(t, conn); msg.ID != "2" || msg.Type != msgNext {
+		t.Errorf("query: got %s %s, want next", msg.ID, msg.Type)
+	}
+	if msg := readWS(t, conn); msg.ID != "2" || msg.Type != msgComplete {
+		t.Errorf("query: got %s %s, want complete", msg.ID, msg.Type)
+	}
+
+	// After the client completes, no more events are sent for the ID
+	sendWS(t, conn, `{"id":"1","type":"complete"}`)
+	waitForSubscribers(t, topicAnimeAdded, 0)
+	executeQuery(editorContext, `mutation { addAnime(title: "Kaiba", genre: "Sci-Fi", episodes: 12) { id } }`, schema)
+	sendWS(t, conn, `{"type":"ping"}`)
+	if msg := readWS(t, conn); msg.Type != msgPong {
+		t.Errorf("got %s %s after complete, want pong", msg.ID, msg.Type)
+	}
+
+	// An invalid operation fails with an error message and no complete
+	sendWS(t, conn, `{"id":"3","type":"subscribe","payload":{"query":"subscription { animeRemoved { id } }"}}`)
+	if msg := readWS(t, conn); msg.ID != "3" || msg.Type != msgError {
+		t.Errorf("invalid subscription: got %s %s, want error", msg.ID, msg.Type)
+	}
+	sendWS(t, conn, `{"type":"ping"}`)
+	if msg := readWS(t, conn); msg.Type != msgPong {
+		t.Errorf("got %s %s after error, want pong", msg.ID, msg.Type)
+	}
+}
+
+func TestWSProtocolViolations(t *testing.T) {
+	url := newWSServer(t)
+	subscribe := `{"id":"1","type":"subscribe","payload":{"query":"subscription { animeAdded { id } }"}}`
+
+	conn := dialWS(t, url)
+	sendWS(t, conn, subscribe)
+	expectClose(t, conn, closeUnauthorized)
+
+	conn = dialWS(t, url)
+	initWS(t, conn, `{}`)
+	sendWS(t, conn, subscribe)
+	sendWS(t, conn, subscribe)
+	expectClose(t, conn, closeSubscriberExists)
+
+	conn = dialWS(t, url)
+	initWS(t, conn, `{}`)
+	sendWS(t, conn, `{"type":"subscribe","payload":{"query":"{ animeList { id } }"}}`)
+	expectClose(t, conn, closeBadRequest)
+
+	conn = dialWS(t, url)
+	sendWS(t, conn, `{"type":"unknown"}`)
+	expectClose(t, conn, closeBadRequest)
+
+	conn = dialWS(t, url)
+	sendWS(t, conn, `{"type":"connection_init","payload":{"authorization":"Bearer not-a-token"}}`)
+	expectClose(t, conn, closeForbidden)
+}
+
+func TestWSInitTimeout(t *testing.T) {
+	defer func(d time.Duration) { connectionInitTimeout = d }(connectionInitTimeout)
+	connectionInitTimeout = 50 * time.Millisecond
+
+	conn := dialWS(t, newWSServer(t))
+	expectClose(t, conn, closeInitTimeout)
+}
+
+func TestWSMutationsUseInitToken(t *testing.T) {
+	url := newWSServer(t)
+	token, err := testKey.Sign(auth.Claims{Subject: "alice", Roles: []string{auth.RoleEditor}, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutation := `{"id":"1","type":"subscribe","payload":{"query":"mutation { addAnime(title: \"Mushishi\", episodes: 26) { id } }"}}`
+
+	anonymous := dialWS(t, url)
+	initWS(t, anonymous, `{}`)
+	sendWS(t, anonymous, mutation)
+	if msg := readWS(t, anonymous); msg.Type != msgNext || !strings.Contains(string(msg.Payload), "authentication required") {
+		t.Errorf("anonymous mutation: got %s %s", msg.Type, msg.Payload)
+	}
+
+	editor := dialWS(t, url)
+	initWS(t, editor, `{"authorization":"Bearer `+token+`"}`)
+	sendWS(t, editor, mutation)
+	if msg := readWS(t, editor); msg.Type != msgNext || string(msg.Payload) != `{"data":{"addAnime":{"id":3}}}` {
+		t.Errorf("editor mutation: got %s %s", msg.Type, msg.Payload)
+	}
+}
+
+// TestWSErrorEndsSubscription feeds a result stream directly: once a result
+// is sent as an error message, later results of the operation are dropped.
+func TestWSErrorEndsSubscription(t *testing.T) {
+	serverConns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		serverConns <- conn
+	}))
+	defer srv.Close()
+	client := dialWS(t, "ws"+strings.TrimPrefix(srv.URL, "http"))
+	c := &wsConnection{conn: <-serverConns, operations: map[string]context.CancelFunc{"1": func() {}}}
+	defer c.conn.Close()
+
+	results := make(chan *graphql.Result, 3)
+	results <- &graphql.Result{Data: map[string]interface{}{"animeAdded": nil}}
+	results <- &graphql.Result{Errors: []gqlerrors.FormattedError{{Message: "boom"}}}
+	results <- &graphql.Result{Data: map[string]interface{}{"animeAdded": nil}}
+	c.streamResults(context.Background(), "1", results)
+	c.send(wsMessage{Type: msgPong})
+
+	for _, want := range []string{msgNext, msgError, msgPong} {
+		if msg := readWS(t, client); msg.Type != want {
+			t.Fatalf("got %s %s, want %s", msg.Type, msg.Payload, want)
+		}
+	}
+	if _, active := c.operations["1"]; active {
+		t.Error("operation still registered after its error")
+	}
+}
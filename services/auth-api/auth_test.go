@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// newTestServer serves the auth routes over in-memory stores and returns the
+// server and the path of the published JWKS file.
+func newTestServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	backend := storage.NewMemoryBackend()
+	userStore, err := storage.New[User](backend, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenStore, err := storage.New[RefreshToken](backend, "refresh_tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyStore, err := storage.New[SigningKeyRecord](backend, "signing_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userRepo, err = newUserRepository(userStore); err != nil {
+		t.Fatal(err)
+	}
+	if tokenRepo, err = newTokenRepository(tokenStore); err != nil {
+		t.Fatal(err)
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if keys, err = newKeyring(keyStore, jwksFile, tokens.Issuer, 24*time.Hour, tokens.RefreshTTL); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, jwksFile
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, token, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// login registers alice and logs her in.
+func login(t *testing.T, srv *httptest.Server) tokenResponse {
+	t.Helper()
+	if code := do(t, srv, "POST", "/api/auth/register", "", `{"username":"Alice","password":"correct horse"}`, nil); code != http.StatusCreated {
+		t.Fatalf("register: got %d, want 201", code)
+	}
+	var resp tokenResponse
+	if code := do(t, srv, "POST", "/api/auth/login", "", `{"username":"alice","password":"correct horse"}`, &resp); code != http.StatusOK {
+		t.Fatalf("login: got %d, want 200", code)
+	}
+	return resp
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	srv, jwksFile := newTestServer(t)
+	resp := login(t, srv)
+
+	if code := do(t, srv, "POST", "/api/auth/register", "", `{"username":"alice","password":"another one"}`, nil); code != http.StatusConflict {
+		t.Errorf("duplicate register: got %d, want 409", code)
+	}
+	for _, body := range []string{`{"username":"al","password":"long enough"}`, `{"username":"bob","password":"short"}`} {
+		if code := do(t, srv, "POST", "/api/auth/register", "", body, nil); code != http.StatusBadRequest {
+			t.Errorf("register %s: got %d, want 400", body, code)
+		}
+	}
+	for _, body := range []string{`{"username":"alice","password":"wrong password"}`, `{"username":"nobody","password":"correct horse"}`} {
+		if code := do(t, srv, "POST", "/api/auth/login", "", body, nil); code != http.StatusUnauthorized {
+			t.Errorf("login %s: got %d, want 401", body, code)
+		}
+	}
+
+	var me userView
+	if code := do(t, srv, "GET", "/api/auth/me", resp.AccessToken, "", &me); code != http.StatusOK || me.Username != "alice" {
+		t.Fatalf("me: got %d %+v", code, me)
+	}
+	if code := do(t, srv, "GET", "/api/auth/me", resp.RefreshToken, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("me with refresh token: got %d, want 401", code)
+	}
+
+	// Other services verify access tokens offline against the published file
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: jwksFile, Issuer: tokens.Issuer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := verifier.Verify(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("Verify(access token): %v", err)
+	}
+	if claims.Username != "alice" || claims.HasRole(auth.RoleEditor) {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := verifier.Verify(resp.RefreshToken); err == nil {
+		t.Error("refresh token accepted as access token")
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	srv, _ := newTestServer(t)
+	first := login(t, srv)
+
+	var second tokenResponse
+	if code := do(t, srv, "POST", "/api/auth/refresh", "", `{"refreshToken":"`+first.RefreshToken+`"}`, &second); code != http.StatusOK {
+		t.Fatalf("refresh: got %d, want 200", code)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Replaying the first token revokes the family, including the second token
+	if code := do(t, srv, "POST", "/api/auth/refresh", "", `{"refreshToken":"`+first.RefreshToken+`"}`, nil); code != http.StatusUnauthorized {
+		t.Errorf("reused refresh: got %d, want 401", code)
+	}
+	if code := do(t, srv, "POST", "/api/auth/refresh", "", `{"refreshToken":"`+second.RefreshToken+`"}`, nil); code != http.StatusUnauthorized {
+		t.Errorf("refresh after reuse: got %d, want 401", code)
+	}
+	if code := do(t, srv, "POST", "/api/auth/refresh", "", `{"refreshToken":"`+first.AccessToken+`"}`, nil); code != http.StatusUnauthorized {
+		t.Errorf("refresh with access token: got %d, want 401", code)
+	}
+}
+
+func TestRefreshWithExpiredAccessToken(t *testing.T) {
+	defer func(ttl time.Duration) { tokens.AccessTTL = ttl }(tokens.AccessTTL)
+	tokens.AccessTTL = -time.Minute
+	srv, _ := newTestServer(t)
+	expired := login(t, srv)
+
+	// Clients commonly keep sending the stale access token while refreshing
+	if code := do(t, srv, "GET", "/api/auth/me", expired.AccessToken, "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("me with expired token: got %d, want 401", code)
+	}
+	var refreshed tokenResponse
+	if code := do(t, srv, "POST", "/api/auth/refresh", expired.AccessToken, `{"refreshToken":"`+expired.RefreshToken+`"}`, &refreshed); code != http.StatusOK {
+		t.Fatalf("refresh with expired bearer token: got %d, want 200", code)
+	}
+	if code := do(t, srv, "POST", "/api/auth/logout", expired.AccessToken, `{"refreshToken":"`+refreshed.RefreshToken+`"}`, nil); code != http.StatusNoContent {
+		t.Errorf("logout with expired bearer token: got %d, want 204", code)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	srv, _ := newTestServer(t)
+	resp := login(t, srv)
+
+	if code := do(t, srv, "POST", "/api/auth/logout", "", `{"refreshToken":"`+resp.RefreshToken+`"}`, nil); code != http.StatusNoContent {
+		t.Fatalf("logout: got %d, want 204", code)
+	}
+	if code := do(t, srv, "POST", "/api/auth/refresh", "", `{"refreshToken":"`+resp.RefreshToken+`"}`, nil); code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: got %d, want 401", code)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	srv, jwksFile := newTestServer(t)
+	before := login(t, srv)
+
+	now := time.Now().UTC()
+	if err := keys.rotate(now.Add(25 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var set auth.JWKS
+	if code := do(t, srv, "GET", "/.well-known/jwks.json", "", "", &set); code != http.StatusOK || len(set.Keys) != 2 {
+		t.Fatalf("jwks after rotation: got %d with %d keys, want 2 keys", code, len(set.Keys))
+	}
+	var after tokenResponse
+	if code := do(t, srv, "POST", "/api/auth/refresh", "", `{"refreshToken":"`+before.RefreshToken+`"}`, &after); code != http.StatusOK {
+		t.Fatalf("refresh with token of the previous key: got %d, want 200", code)
+	}
+
+	// The previous key is retired once its tokens have expired
+	if err := keys.rotate(now.Add(25*time.Hour + tokens.RefreshTTL + time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(before.AccessToken); err == nil {
+		t.Error("token of a retired key accepted")
+	}
+	if _, err := verifier.Verify(after.AccessToken); err != nil {
+		t.Errorf("token of the current key rejected: %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/mbenabdallah/auth-api
+
+go 1.24.2
+
+require (
+	github.com/mbenabdallah/shared v0.0.0
+	golang.org/x/crypto v0.33.0
+)
+
+require (
+	go.etcd.io/bbolt v1.4.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/mbenabdallah/shared => ../shared
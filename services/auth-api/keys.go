@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// --- Signing Keys ---
+//
+// Tokens are signed with RS256. The newest key signs; older keys stay in the
+// published key set until every token they signed has expired, then they are
+// deleted. Only public keys leave the service: through /.well-known/jwks.json
+// and the JWKS file the other services verify tokens against.
+
+// rsaKeyBits is the size of generated signing keys.
+const rsaKeyBits = 2048
+
+// SigningKeyRecord is a stored signing key.
+type SigningKeyRecord struct {
+	ID         int       `json:"id"`
+	Kid        string    `json:"kid"`
+	PrivateKey string    `json:"privateKey"` // PKCS #1 PEM
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type signingKey struct {
+	auth.SigningKey
+	createdAt time.Time
+	recordID  int
+}
+
+// keyring holds the signing keys and publishes their public halves.
+type keyring struct {
+	mu       sync.RWMutex
+	store    storage.Store[SigningKeyRecord]
+	keys     []signingKey // oldest first; the last one signs
+	jwksFile string
+	verifier *auth.Verifier // reads jwksFile, to check refresh tokens
+	maxAge   time.Duration  // how long a key signs before it is replaced
+	retain   time.Duration  // how long a replaced key is still published
+}
+
+// Keyring used by the handlers, set up in main
+var keys *keyring
+
+func newKeyring(store storage.Store[SigningKeyRecord], jwksFile, issuer string, maxAge, retain time.Duration) (*keyring, error) {
+	records, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	k := &keyring{store: store, jwksFile: jwksFile, maxAge: maxAge, retain: retain}
+	for _, rec := range records {
+		block, _ := pem.Decode([]byte(rec.PrivateKey))
+		if block == nil {
+			return nil, fmt.Errorf("signing key %q: invalid PEM", rec.Kid)
+		}
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", rec.Kid, err)
+		}
+		k.keys = append(k.keys, signingKey{
+			SigningKey: auth.SigningKey{ID: rec.Kid, Algorithm: auth.RS256, Private: private},
+			createdAt:  rec.CreatedAt,
+			recordID:   rec.ID,
+		})
+	}
+	sort.Slice(k.keys, func(i, j int) bool { return k.keys[i].createdAt.Before(k.keys[j].createdAt) })
+
+	if err := k.rotate(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if k.verifier, err = auth.NewVerifier(auth.Config{JWKSFile: jwksFile, Issuer: issuer}); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// rotate adds a key if the signing key is older than maxAge (or missing),
+// drops keys that no longer verify any live token, and publishes the result.
+func (k *keyring) rotate(now time.Time) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	changed := false
+	if len(k.keys) == 0 || now.Sub(k.keys[len(k.keys)-1].createdAt) >= k.maxAge {
+		key, err := k.generate(now)
+		if err != nil {
+			return err
+		}
+		k.keys = append(k.keys, key)
+		changed = true
+		log.Printf("Generated signing key %s", key.ID)
+	}
+	// A key stops signing when its successor is created; the tokens it
+	// signed last expire retain later.
+	kept := k.keys[:0]
+	for i, key := range k.keys {
+		if i < len(k.keys)-1 && now.Sub(k.keys[i+1].createdAt) > k.retain {
+			if _, err := k.store.Delete(key.recordID); err != nil {
+				return err
+			}
+			changed = true
+			log.Printf("Retired signing key %s", key.ID)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	k.keys = kept
+
+	if _, err := os.Stat(k.jwksFile); !changed && err == nil {
+		return nil
+	}
+	if err := k.publish(); err != nil {
+		return err
+	}
+	if k.verifier != nil {
+		return k.verifier.Reload()
+	}
+	return nil
+}
+
+func (k *keyring) generate(now time.Time) (signingKey, error) {
+	private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return signingKey{}, err
+	}
+	id, err := k.store.NextID()
+	if err != nil {
+		return signingKey{}, err
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	kid := now.Format("20060102") + "-" + hex.EncodeToString(suffix)
+	rec := SigningKeyRecord{
+		ID:         id,
+		Kid:        kid,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)})),
+		CreatedAt:  now,
+	}
+	if err := k.store.Put(id, rec); err != nil {
+		return signingKey{}, err
+	}
+	return signingKey{
+		SigningKey: auth.SigningKey{ID: kid, Algorithm: auth.RS256, Private: private},
+		createdAt:  now,
+		recordID:   id,
+	}, nil
+}
+
+// publish writes the public key set to jwksFile. The file is replaced
+// atomically so readers never see a partial document. The caller holds k.mu.
+func (k *keyring) publish() error {
+	data, err := json.MarshalIndent(k.publicKeys(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(k.jwksFile), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(k.jwksFile), ".jwks-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), k.jwksFile)
+}
+
+func (k *keyring) publicKeys() auth.JWKS {
+	set := auth.JWKS{Keys: make([]auth.JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		set.Keys = append(set.Keys, key.PublicJWK())
+	}
+	return set
+}
+
+// JWKS returns the published key set.
+func (k *keyring) JWKS() auth.JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.publicKeys()
+}
+
+// Sign signs claims with the current key.
+func (k *keyring) Sign(claims auth.Claims) (string, error) {
+	k.mu.RLock()
+	key := k.keys[len(k.keys)-1]
+	k.mu.RUnlock()
+	return key.Sign(claims)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/seed"
+	"github.com/mbenabdallah/shared/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// --- Request Types ---
+
+// credentials is the body of register and login requests.
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// refreshRequest is the body of refresh and logout requests.
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// --- Request Helpers ---
+
+// decodeBody decodes a JSON request body into v. It writes the error
+// response and returns false if the body is invalid.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeJSON encodes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeTokens returns issued tokens, which must not be cached.
+func writeTokens(w http.ResponseWriter, resp tokenResponse) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// --- Handler Functions ---
+
+// registerHandler handles POST /api/auth/register. New users have no roles;
+// editors are provisioned through the users fixture.
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req credentials
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	username, err := normalizeUsername(req.Username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkPasswordStrength(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	u, err := userRepo.Create(username, hash, nil)
+	if errors.Is(err, errUsernameTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating user %q: %v", username, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, u.view())
+	log.Printf("Handled POST /api/auth/register request (user %d)", u.ID)
+}
+
+// loginHandler handles POST /api/auth/login. Unknown users and wrong
+// passwords get the same response.
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req credentials
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	username, err := normalizeUsername(req.Username)
+	var u User
+	found := false
+	if err == nil {
+		if u, found, err = userRepo.ByName(username); err != nil {
+			log.Printf("Error loading user %q: %v", username, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	hash := dummyHash
+	if found {
+		hash = []byte(u.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil || !found {
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := issue(u, "", time.Now().UTC())
+	if err != nil {
+		log.Printf("Error issuing tokens for user %d: %v", u.ID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeTokens(w, resp)
+	log.Printf("Handled POST /api/auth/login request (user %d)", u.ID)
+}
+
+// refreshHandler handles POST /api/auth/refresh: the refresh token is
+// redeemed for a new access token and a new refresh token.
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req refreshRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	claims, err := keys.verifier.VerifyRefresh(req.RefreshToken)
+	if err != nil {
+		log.Printf("Rejected refresh token: %v", err)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	u, found, err := userForSubject(claims.Subject)
+	if err != nil {
+		log.Printf("Error loading user %q: %v", claims.Subject, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := issue(u, claims.ID, time.Now().UTC())
+	if errors.Is(err, errTokenUnknown) || errors.Is(err, errTokenReused) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Error refreshing tokens for user %d: %v", u.ID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeTokens(w, resp)
+	log.Printf("Handled POST /api/auth/refresh request (user %d)", u.ID)
+}
+
+// logoutHandler handles POST /api/auth/logout: the refresh token and every
+// token rotated from the same login are revoked. Access tokens already
+// issued stay valid until they expire.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req refreshRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	claims, err := keys.verifier.VerifyRefresh(req.RefreshToken)
+	if err != nil {
+		log.Printf("Rejected refresh token: %v", err)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if _, err := tokenRepo.Revoke(claims.ID, time.Now().UTC()); err != nil {
+		log.Printf("Error revoking refresh token %s: %v", claims.ID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Handled POST /api/auth/logout request")
+}
+
+// meHandler handles GET /api/auth/me, returning the user of the access token.
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := auth.RequestUser(w, r)
+	if !ok {
+		return
+	}
+	u, found, err := userForSubject(claims.Subject)
+	if err != nil {
+		log.Printf("Error loading user %q: %v", claims.Subject, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, u.view())
+	log.Printf("Handled GET /api/auth/me request (user %d)", u.ID)
+}
+
+// jwksHandler handles GET /.well-known/jwks.json, the public keys tokens are
+// verified with.
+func jwksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, keys.JWKS())
+	log.Printf("Handled GET /.well-known/jwks.json request")
+}
+
+// userForSubject looks up the user named by a token's sub claim.
+func userForSubject(sub string) (User, bool, error) {
+	id, err := strconv.Atoi(sub)
+	if err != nil || id <= 0 {
+		return User{}, false, nil
+	}
+	return userRepo.Get(id)
+}
+
+// --- Main Function ---
+
+// envOr returns the environment variable key, or def when it is unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envDuration parses the duration in environment variable key, or def when
+// it is unset.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Fatalf("Invalid %s %q", key, v)
+	}
+	return d
+}
+
+// housekeepingInterval is how often signing keys are rotated and expired
+// refresh tokens pruned.
+const housekeepingInterval = time.Hour
+
+func housekeeping() {
+	for now := range time.Tick(housekeepingInterval) {
+		if err := keys.rotate(now.UTC()); err != nil {
+			log.Printf("Error rotating signing keys: %v", err)
+		}
+		if n, err := tokenRepo.Prune(now.UTC()); err != nil {
+			log.Printf("Error pruning refresh tokens: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d expired refresh tokens", n)
+		}
+	}
+}
+
+// registerRoutes adds the API routes to mux. Only /api/auth/me reads the
+// access token; the token endpoints take their credentials from the body, so
+// a client may still send its expired access token while refreshing it.
+func registerRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/auth/register", registerHandler)
+	mux.HandleFunc("/api/auth/login", loginHandler)
+	mux.HandleFunc("/api/auth/refresh", refreshHandler)
+	mux.HandleFunc("/api/auth/logout", logoutHandler)
+	mux.Handle("/api/auth/me", keys.verifier.Authenticate(http.HandlerFunc(meHandler)))
+	mux.HandleFunc("/.well-known/jwks.json", jwksHandler)
+}
+
+func main() {
+	tokens = tokenConfig{
+		Issuer:     envOr("TOKEN_ISSUER", tokens.Issuer),
+		AccessTTL:  envDuration("ACCESS_TOKEN_TTL", tokens.AccessTTL),
+		RefreshTTL: envDuration("REFRESH_TOKEN_TTL", tokens.RefreshTTL),
+	}
+	keyMaxAge := envDuration("SIGNING_KEY_MAX_AGE", 30*24*time.Hour)
+
+	backend, err := storage.Open(storage.ConfigFromEnv("data/auth.db"))
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer backend.Close()
+	userStore, err := storage.New[User](backend, "users")
+	if err != nil {
+		log.Fatalf("Failed to open user store: %v", err)
+	}
+	tokenStore, err := storage.New[RefreshToken](backend, "refresh_tokens")
+	if err != nil {
+		log.Fatalf("Failed to open refresh token store: %v", err)
+	}
+	keyStore, err := storage.New[SigningKeyRecord](backend, "signing_keys")
+	if err != nil {
+		log.Fatalf("Failed to open signing key store: %v", err)
+	}
+
+	if _, err := seed.Apply(userStore, seed.OptionsFromEnv("seed"), "users",
+		func(u User) int { return u.ID }, validateUser); err != nil {
+		log.Fatalf("Failed to seed user store: %v", err)
+	}
+	if userRepo, err = newUserRepository(userStore); err != nil {
+		log.Fatalf("Failed to load users: %v", err)
+	}
+	if tokenRepo, err = newTokenRepository(tokenStore); err != nil {
+		log.Fatalf("Failed to load refresh tokens: %v", err)
+	}
+	// A replaced key stays published as long as tokens it signed may live
+	if keys, err = newKeyring(keyStore, envOr("JWKS_FILE", "data/jwks.json"), tokens.Issuer, keyMaxAge, tokens.RefreshTTL); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	go housekeeping()
+
+	registerRoutes(http.DefaultServeMux)
+
+	// Basic health check endpoint
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "Auth API is running. Log in at /api/auth/login; public keys at /.well-known/jwks.json")
+	})
+
+	port := "8086"
+	fmt.Printf("Auth API starting on port %s...\n", port)
+	log.Printf("Auth API starting on port %s...", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// --- Refresh Tokens ---
+//
+// Refresh tokens are single use. Redeeming one revokes it and issues a
+// successor in the same family; a login starts a new family. Presenting a
+// revoked token means it leaked (or its successor did), so the whole family
+// is revoked and the user has to log in again.
+
+// RefreshToken records an issued refresh token. TokenID is the token's jti.
+type RefreshToken struct {
+	ID         int        `json:"id"`
+	TokenID    string     `json:"tokenId"`
+	UserID     int        `json:"userId"`
+	Family     string     `json:"family"`
+	IssuedAt   time.Time  `json:"issuedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	ReplacedBy string     `json:"replacedBy,omitempty"`
+}
+
+var (
+	errTokenUnknown = errors.New("refresh token is unknown or expired")
+	errTokenReused  = errors.New("refresh token was already used; its family has been revoked")
+)
+
+// tokenRepository guards the refresh token store and indexes it by jti.
+type tokenRepository struct {
+	mu        sync.Mutex
+	store     storage.Store[RefreshToken]
+	byTokenID map[string]int
+}
+
+// Repository used by the handlers, set up in main
+var tokenRepo *tokenRepository
+
+func newTokenRepository(store storage.Store[RefreshToken]) (*tokenRepository, error) {
+	tokens, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	r := &tokenRepository{store: store, byTokenID: make(map[string]int, len(tokens))}
+	for _, t := range tokens {
+		r.byTokenID[t.TokenID] = t.ID
+	}
+	return r, nil
+}
+
+// Add records a newly issued token.
+func (r *tokenRepository) Add(t RefreshToken) (RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.add(t)
+}
+
+func (r *tokenRepository) add(t RefreshToken) (RefreshToken, error) {
+	id, err := r.store.NextID()
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	t.ID = id
+	if err := r.store.Put(id, t); err != nil {
+		return RefreshToken{}, err
+	}
+	r.byTokenID[t.TokenID] = id
+	return t, nil
+}
+
+// Rotate redeems the token with jti tokenID: it is revoked and next, which
+// joins its family, is recorded in its place.
+func (r *tokenRepository) Rotate(tokenID string, next RefreshToken, now time.Time) (RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok, err := r.lookup(tokenID)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	if !ok || !now.Before(old.ExpiresAt) {
+		return RefreshToken{}, errTokenUnknown
+	}
+	if old.RevokedAt != nil {
+		if err := r.revokeFamily(old.Family, now); err != nil {
+			return RefreshToken{}, err
+		}
+		log.Printf("Refresh token %s of user %d reused; revoked family %s", tokenID, old.UserID, old.Family)
+		return RefreshToken{}, errTokenReused
+	}
+
+	old.RevokedAt = &now
+	old.ReplacedBy = next.TokenID
+	if err := r.store.Put(old.ID, old); err != nil {
+		return RefreshToken{}, err
+	}
+	next.UserID, next.Family = old.UserID, old.Family
+	return r.add(next)
+}
+
+// Revoke revokes the family of the token with jti tokenID. It reports
+// whether the token was known.
+func (r *tokenRepository) Revoke(tokenID string, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok, err := r.lookup(tokenID)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, r.revokeFamily(t.Family, now)
+}
+
+// Prune deletes tokens that have expired.
+func (r *tokenRepository) Prune(now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens, err := r.store.List()
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, t := range tokens {
+		if now.Before(t.ExpiresAt) {
+			continue
+		}
+		if _, err := r.store.Delete(t.ID); err != nil {
+			return pruned, err
+		}
+		delete(r.byTokenID, t.TokenID)
+		pruned++
+	}
+	return pruned, nil
+}
+
+func (r *tokenRepository) lookup(tokenID string) (RefreshToken, bool, error) {
+	id, ok := r.byTokenID[tokenID]
+	if !ok {
+		return RefreshToken{}, false, nil
+	}
+	return r.store.Get(id)
+}
+
+// revokeFamily revokes every live token of a family. The caller holds r.mu.
+func (r *tokenRepository) revokeFamily(family string, now time.Time) error {
+	tokens, err := r.store.List()
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.Family != family || t.RevokedAt != nil {
+			continue
+		}
+		t.RevokedAt = &now
+		if err := r.store.Put(t.ID, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Issuing ---
+
+// tokenConfig holds the issuer name and token lifetimes.
+type tokenConfig struct {
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// Token settings, set up in main
+var tokens = tokenConfig{Issuer: "auth-api", AccessTTL: 15 * time.Minute, RefreshTTL: 30 * 24 * time.Hour}
+
+// tokenResponse is the body returned by login and refresh.
+type tokenResponse struct {
+	AccessToken      string `json:"accessToken"`
+	TokenType        string `json:"tokenType"`
+	ExpiresIn        int    `json:"expiresIn"` // seconds
+	RefreshToken     string `json:"refreshToken"`
+	RefreshExpiresIn int    `json:"refreshExpiresIn"` // seconds
+}
+
+// newTokenID returns a random jti.
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// issue signs an access token and a refresh token for u. With an empty
+// previous the refresh token starts a new family (a login); otherwise it
+// rotates the refresh token with jti previous and joins its family.
+func issue(u User, previous string, now time.Time) (tokenResponse, error) {
+	access := auth.Claims{
+		Subject:   strconv.Itoa(u.ID),
+		Username:  u.Username,
+		Roles:     u.Roles,
+		Issuer:    tokens.Issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(tokens.AccessTTL).Unix(),
+		ID:        newTokenID(),
+	}
+	refresh := auth.Claims{
+		Subject:   access.Subject,
+		TokenUse:  auth.TokenUseRefresh,
+		Issuer:    tokens.Issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(tokens.RefreshTTL).Unix(),
+		ID:        newTokenID(),
+	}
+
+	record := RefreshToken{
+		TokenID:   refresh.ID,
+		UserID:    u.ID,
+		Family:    refresh.ID,
+		IssuedAt:  now,
+		ExpiresAt: time.Unix(refresh.ExpiresAt, 0).UTC(),
+	}
+	var err error
+	if previous == "" {
+		_, err = tokenRepo.Add(record)
+	} else {
+		_, err = tokenRepo.Rotate(previous, record, now)
+	}
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	resp := tokenResponse{
+		TokenType:        "Bearer",
+		ExpiresIn:        int(tokens.AccessTTL / time.Second),
+		RefreshExpiresIn: int(tokens.RefreshTTL / time.Second),
+	}
+	if resp.AccessToken, err = keys.Sign(access); err != nil {
+		return tokenResponse{}, err
+	}
+	if resp.RefreshToken, err = keys.Sign(refresh); err != nil {
+		return tokenResponse{}, err
+	}
+	return resp, nil
+}
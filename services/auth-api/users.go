@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbenabdallah/shared/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// --- Users ---
+
+// User is a registered account. PasswordHash is a bcrypt hash and never
+// leaves the service (see userView).
+type User struct {
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"`
+	Roles        []string  `json:"roles"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// userView is a user as returned by the API.
+type userView struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (u User) view() userView {
+	return userView{ID: u.ID, Username: u.Username, Roles: u.Roles, CreatedAt: u.CreatedAt}
+}
+
+// Usernames are lowercase, 3 to 32 characters, and start with a letter or digit.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,31}$`)
+
+// bcrypt ignores everything after 72 bytes, so longer passwords are refused
+// rather than silently truncated.
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+// normalizeUsername lowercases a username and checks its form.
+func normalizeUsername(username string) (string, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernamePattern.MatchString(username) {
+		return "", fmt.Errorf("username must be 3-32 characters: lowercase letters, digits, '.', '_' or '-'")
+	}
+	return username, nil
+}
+
+// checkPasswordStrength enforces the password length limits.
+func checkPasswordStrength(password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// dummyHash is compared against when a login names an unknown user, so the
+// response time does not reveal which usernames exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// validateUser checks a user fixture before it is written to the store.
+func validateUser(u User) error {
+	if name, err := normalizeUsername(u.Username); err != nil {
+		return err
+	} else if name != u.Username {
+		return fmt.Errorf("username %q must be lowercase", u.Username)
+	}
+	if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+		return fmt.Errorf("user %q: passwordHash must be a bcrypt hash: %v", u.Username, err)
+	}
+	return nil
+}
+
+// --- Repository ---
+
+var errUsernameTaken = errors.New("username is already taken")
+
+// userRepository guards the user store and indexes users by username.
+type userRepository struct {
+	mu     sync.RWMutex
+	store  storage.Store[User]
+	byName map[string]int
+}
+
+// Repository used by the handlers, set up in main
+var userRepo *userRepository
+
+func newUserRepository(store storage.Store[User]) (*userRepository, error) {
+	users, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	r := &userRepository{store: store, byName: make(map[string]int, len(users))}
+	for _, u := range users {
+		if _, dup := r.byName[u.Username]; dup {
+			return nil, fmt.Errorf("duplicate username %q in the user store", u.Username)
+		}
+		r.byName[u.Username] = u.ID
+	}
+	return r, nil
+}
+
+// Create stores a new user with a normalized username and returns it.
+func (r *userRepository) Create(username, passwordHash string, roles []string) (User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, taken := r.byName[username]; taken {
+		return User{}, errUsernameTaken
+	}
+	id, err := r.store.NextID()
+	if err != nil {
+		return User{}, err
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	u := User{ID: id, Username: username, PasswordHash: passwordHash, Roles: roles, CreatedAt: time.Now().UTC()}
+	if err := r.store.Put(id, u); err != nil {
+		return User{}, err
+	}
+	r.byName[username] = id
+	return u, nil
+}
+
+// ByName looks a user up by normalized username.
+func (r *userRepository) ByName(username string) (User, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byName[username]
+	if !ok {
+		return User{}, false, nil
+	}
+	return r.store.Get(id)
+}
+
+// Get looks a user up by ID.
+func (r *userRepository) Get(id int) (User, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.store.Get(id)
+}
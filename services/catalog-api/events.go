@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Anime Change Events ---
+//
+// anime-api publishes animeAdded, animeUpdated and animeDeleted over GraphQL
+// subscriptions (graphql-transport-ws), so anime changes reach the search
+// index without waiting for the next periodic refresh. Series and movies have no change feed and rely
+// on the refresh interval.
+
+const graphqlTransportWS = "graphql-transport-ws"
+
+// wsMessage is a graphql-transport-ws protocol message.
+type wsMessage struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// animeSubscriptions are the operations watched for changes.
+var animeSubscriptions = map[string]string{
+	"anime-added":   "subscription { animeAdded { id } }",
+	"anime-updated": "subscription { animeUpdated { id } }",
+	"anime-deleted": "subscription { animeDeleted { id } }",
+}
+
+// websocketURL turns an http(s) GraphQL endpoint into its ws(s) form.
+func websocketURL(endpoint string) string {
+	if rest, ok := strings.CutPrefix(endpoint, "https://"); ok {
+		return "wss://" + rest
+	}
+	return "ws://" + strings.TrimPrefix(endpoint, "http://")
+}
+
+// watchAnimeEvents calls onChange for every anime event until ctx is done,
+// reconnecting with exponential backoff.
+func watchAnimeEvents(ctx context.Context, endpoint string, onChange func()) {
+	backoff := time.Second
+	for {
+		connected, err := subscribeAnimeEvents(ctx, websocketURL(endpoint), onChange)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = time.Second
+			onChange() // events may have been missed while reconnecting
+		}
+		log.Printf("Anime event subscription ended: %v (retrying in %s)", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// subscribeAnimeEvents runs one subscription session. connected reports
+// whether the server acknowledged the connection.
+func subscribeAnimeEvents(ctx context.Context, wsURL string, onChange func()) (connected bool, err error) {
+	dialer := websocket.Dialer{Subprotocols: []string{graphqlTransportWS}, HandshakeTimeout: 5 * time.Second}
+	conn, _, err := dialer.DialContext(ctx, wsURL, http.Header{})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.WriteJSON(wsMessage{Type: "connection_init"}); err != nil {
+		return false, err
+	}
+	var ack wsMessage
+	if err := conn.ReadJSON(&ack); err != nil {
+		return false, err
+	}
+	if ack.Type != "connection_ack" {
+		return false, &protocolError{"expected connection_ack, got " + ack.Type}
+	}
+	for id, query := range animeSubscriptions {
+		if err := conn.WriteJSON(wsMessage{ID: id, Type: "subscribe", Payload: map[string]string{"query": query}}); err != nil {
+			return true, err
+		}
+	}
+	log.Printf("Subscribed to anime events at %s", wsURL)
+
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return true, err
+		}
+		switch msg.Type {
+		case "next":
+			onChange()
+		case "ping":
+			if err := conn.WriteJSON(wsMessage{Type: "pong"}); err != nil {
+				return true, err
+			}
+		case "error", "complete":
+			return true, &protocolError{"subscription " + msg.ID + " ended with " + msg.Type}
+		}
+	}
+}
+
+// protocolError is an unexpected graphql-transport-ws message.
+type protocolError struct{ msg string }
+
+func (e *protocolError) Error() string { return e.msg }
@@ -0,0 +1,20 @@
+module github.com/mbenabdallah/catalog-api
+
+go 1.24.2
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/mbenabdallah/movies-api v0.0.0
+	github.com/mbenabdallah/shared v0.0.0
+	golang.org/x/text v0.22.0
+)
+
+require (
+	go.etcd.io/bbolt v1.4.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
+
+replace (
+	github.com/mbenabdallah/movies-api => ../movies-api
+	github.com/mbenabdallah/shared => ../shared
+)
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// --- Inverted Index ---
+//
+// The index maps normalized terms to postings. Queries are tokenized the same
+// way; every query token is matched exactly, as a prefix of longer terms, or
+// within a small edit distance, and each kind of match contributes a
+// decreasing share of the term's TF-IDF weight.
+
+// Field weights: a title hit counts more than a genre or an episode title hit
+const (
+	titleWeight   = 3.0
+	genreWeight   = 1.5
+	episodeWeight = 1.0
+)
+
+// Share of the score kept for non-exact matches
+const (
+	prefixFactor    = 0.7
+	distance1Factor = 0.5
+	distance2Factor = 0.3
+)
+
+// Minimum query token lengths for prefix and typo-tolerant matching, so short
+// tokens do not match half of the vocabulary.
+const (
+	minPrefixLen    = 2
+	minDistance1Len = 4
+	minDistance2Len = 7
+)
+
+// Field bits recorded per posting, reported as the matched fields of a result
+const (
+	fieldTitle = 1 << iota
+	fieldGenre
+	fieldEpisode
+)
+
+var fieldNames = []struct {
+	bit  int
+	name string
+}{{fieldTitle, "title"}, {fieldGenre, "genre"}, {fieldEpisode, "episode"}}
+
+// posting records the weighted frequency of a term in one document.
+type posting struct {
+	doc    int
+	weight float64
+	fields int
+}
+
+// searchIndex is an immutable inverted index over a catalogue snapshot.
+type searchIndex struct {
+	docs     []ContentItem
+	titles   []string // normalized full titles, for the exact title boost
+	postings map[string][]posting
+	terms    []string // sorted vocabulary, for prefix scans
+}
+
+// normalizeText lowercases s and strips diacritics ("Pokémon" -> "pokemon").
+func normalizeText(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// tokenize splits text into normalized terms on anything that is not a letter or digit.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(normalizeText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// buildIndex indexes titles, genres and episode titles of the items.
+func buildIndex(items []ContentItem) *searchIndex {
+	idx := &searchIndex{docs: items, postings: make(map[string][]posting)}
+	for doc, item := range items {
+		weights := map[string]*posting{}
+		add := func(text string, weight float64, field int) {
+			for _, term := range tokenize(text) {
+				p := weights[term]
+				if p == nil {
+					p = &posting{doc: doc}
+					weights[term] = p
+				}
+				p.weight += weight
+				p.fields |= field
+			}
+		}
+		add(item.Title, titleWeight, fieldTitle)
+		for _, g := range item.Genres {
+			add(g, genreWeight, fieldGenre)
+		}
+		for _, t := range item.EpisodeTitles {
+			add(t, episodeWeight, fieldEpisode)
+		}
+		for term, p := range weights {
+			idx.postings[term] = append(idx.postings[term], *p)
+		}
+		idx.titles = append(idx.titles, strings.Join(tokenize(item.Title), " "))
+	}
+	for term := range idx.postings {
+		idx.terms = append(idx.terms, term)
+	}
+	sort.Strings(idx.terms)
+	return idx
+}
+
+// idf is the inverse document frequency of a term.
+func (idx *searchIndex) idf(term string) float64 {
+	return math.Log(1 + float64(len(idx.docs))/float64(len(idx.postings[term])))
+}
+
+// expand returns the vocabulary terms matching a query token with their score factor.
+func (idx *searchIndex) expand(token string) map[string]float64 {
+	matches := map[string]float64{}
+	if _, ok := idx.postings[token]; ok {
+		matches[token] = 1
+	}
+
+	n := len([]rune(token))
+	if n >= minPrefixLen {
+		for i := sort.SearchStrings(idx.terms, token); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], token); i++ {
+			if idx.terms[i] != token {
+				matches[idx.terms[i]] = prefixFactor
+			}
+		}
+	}
+
+	maxDist := 0
+	switch {
+	case n >= minDistance2Len:
+		maxDist = 2
+	case n >= minDistance1Len:
+		maxDist = 1
+	}
+	if maxDist > 0 {
+		for _, term := range idx.terms {
+			if _, done := matches[term]; done {
+				continue
+			}
+			switch d := editDistance(token, term, maxDist); {
+			case d > maxDist:
+			case d == 1:
+				matches[term] = distance1Factor
+			case d == 2:
+				matches[term] = distance2Factor
+			}
+		}
+	}
+	return matches
+}
+
+// searchResult is one ranked match.
+type searchResult struct {
+	Item    ContentItem `json:"item"`
+	Score   float64     `json:"score"`
+	Matches []string    `json:"matches"` // fields that matched: title, genre, episode
+}
+
+// search ranks the documents matching query. Documents matching more of the
+// query tokens rank first, then by score, then by title.
+func (idx *searchIndex) search(query string, keep func(ContentItem) bool) []searchResult {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	type hit struct {
+		score   float64
+		covered int
+		fields  int
+	}
+	hits := map[int]*hit{}
+	for _, token := range tokens {
+		// best contribution of this token per document
+		best := map[int]float64{}
+		fields := map[int]int{}
+		for term, factor := range idx.expand(token) {
+			idf := idx.idf(term)
+			for _, p := range idx.postings[term] {
+				if s := factor * p.weight * idf; s > best[p.doc] {
+					best[p.doc] = s
+				}
+				fields[p.doc] |= p.fields
+			}
+		}
+		for doc, s := range best {
+			h := hits[doc]
+			if h == nil {
+				h = &hit{}
+				hits[doc] = h
+			}
+			h.score += s
+			h.covered++
+			h.fields |= fields[doc]
+		}
+	}
+
+	type ranked struct {
+		searchResult
+		covered int
+	}
+	normalized := strings.Join(tokens, " ")
+	results := make([]ranked, 0, len(hits))
+	for doc, h := range hits {
+		item := idx.docs[doc]
+		if keep != nil && !keep(item) {
+			continue
+		}
+		score := h.score
+		switch {
+		case idx.titles[doc] == normalized:
+			score *= 2 // exact title
+		case strings.HasPrefix(idx.titles[doc], normalized):
+			score *= 1.5 // title starts with the query
+		}
+		r := searchResult{Item: item, Score: math.Round(score*1000) / 1000}
+		for _, f := range fieldNames {
+			if h.fields&f.bit != 0 {
+				r.Matches = append(r.Matches, f.name)
+			}
+		}
+		results = append(results, ranked{searchResult: r, covered: h.covered})
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		ra, rb := results[a], results[b]
+		if ra.covered != rb.covered {
+			return ra.covered > rb.covered
+		}
+		if ra.Score != rb.Score {
+			return ra.Score > rb.Score
+		}
+		if ta, tb := strings.ToLower(ra.Item.Title), strings.ToLower(rb.Item.Title); ta != tb {
+			return ta < tb
+		}
+		return ra.Item.Ref < rb.Item.Ref
+	})
+	out := make([]searchResult, len(results))
+	for i, r := range results {
+		out[i] = r.searchResult
+	}
+	return out
+}
+
+// editDistance returns the optimal string alignment distance between a and b
+// (insertions, deletions, substitutions and adjacent transpositions), or
+// limit+1 as soon as the distance is known to exceed limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	prevMin := 0
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		// a transposition can reach back two rows, so both must exceed the limit
+		if rowMin > limit && prevMin > limit {
+			return limit + 1
+		}
+		prevMin = rowMin
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return min(prev[len(rb)], limit+1)
+}
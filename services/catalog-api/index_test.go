@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"inception", "inception", 2, 0},
+		{"incpetion", "inception", 2, 1}, // adjacent transposition
+		{"breakin", "breaking", 2, 1},
+		{"severence", "severance", 2, 1},
+		{"invinsibel", "invincible", 2, 2},
+		{"acti", "trap", 1, 2}, // over the limit: limit+1
+		{"monster", "mystery", 2, 3},
+		{"pokemon", "pok", 2, 3}, // length difference alone exceeds the limit
+	}
+	for _, c := range cases {
+		if got := editDistance(c.a, c.b, c.limit); got != c.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", c.a, c.b, c.limit, got, c.want)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := buildIndex([]ContentItem{
+		{Kind: KindSeries, ID: 1, Ref: "series:1", Title: "Breaking Bad", Genres: []string{"crime", "drama"}},
+		{Kind: KindAnime, ID: 1, Ref: "anime:1", Title: "Monster", Genres: []string{"drama", "mystery"}, EpisodeTitles: []string{"Herr Dr. Tenma"}},
+		{Kind: KindAnime, ID: 2, Ref: "anime:2", Title: "Pokémon", Genres: []string{"adventure"}},
+		{Kind: KindMovie, ID: 1, Ref: "movie:1", Title: "A Bronx Tale", Genres: []string{"drama"}},
+	})
+
+	refs := func(results []searchResult) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Item.Ref)
+		}
+		return out
+	}
+	tests := []struct {
+		query string
+		keep  func(ContentItem) bool
+		want  []string
+	}{
+		{"breakin bad", nil, []string{"series:1"}},                 // prefix
+		{"monstre", nil, []string{"anime:1"}},                      // typo
+		{"pokemon", nil, []string{"anime:2"}},                      // diacritics
+		{"tenma", nil, []string{"anime:1"}},                        // episode title
+		{"drama", nil, []string{"movie:1", "series:1", "anime:1"}}, // genre, ties by title
+		{"drama", func(i ContentItem) bool { return i.Kind == KindMovie }, []string{"movie:1"}},
+		{"acti", nil, nil}, // too far from every term
+	}
+	for _, tt := range tests {
+		got := refs(idx.search(tt.query, tt.keep))
+		if len(got) != len(tt.want) {
+			t.Errorf("search(%q) = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("search(%q) = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	movies "github.com/mbenabdallah/movies-api/client"
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/genre"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// --- Aggregation ---
+
+// sourceStatus reports the outcome of one backend call.
+type sourceStatus struct {
+	Source     string `json:"source"`
+	Kind       string `json:"kind"`
+	OK         bool   `json:"ok"`
+	Count      int    `json:"count"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// aggregator fans out to the backends and merges their catalogues.
+type aggregator struct {
+	sources []source
+	timeout time.Duration // per-source deadline
+}
+
+// Backends queried by the catalog, configured in main
+var catalog *aggregator
+
+// collect queries the sources serving the requested kinds (all when kinds is
+// empty) concurrently. A failing source does not fail the others; its error
+// is reported in the returned statuses.
+func (a *aggregator) collect(ctx context.Context, kinds map[string]bool) ([]ContentItem, []sourceStatus) {
+	var selected []source
+	for _, src := range a.sources {
+		if len(kinds) == 0 || kinds[src.Kind()] {
+			selected = append(selected, src)
+		}
+	}
+
+	results := make([][]ContentItem, len(selected))
+	statuses := make([]sourceStatus, len(selected))
+	var wg sync.WaitGroup
+	for i, src := range selected {
+		wg.Add(1)
+		go func(i int, src source) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, a.timeout)
+			defer cancel()
+
+			start := time.Now()
+			items, err := src.Fetch(ctx)
+			status := sourceStatus{Source: src.Name(), Kind: src.Kind(), DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				log.Printf("Error fetching %s: %v", src.Name(), err)
+				status.Error = err.Error()
+			} else {
+				status.OK = true
+				status.Count = len(items)
+				results[i] = items
+			}
+			statuses[i] = status
+		}(i, src)
+	}
+	wg.Wait()
+
+	items := []ContentItem{}
+	for _, r := range results {
+		items = append(items, r...)
+	}
+	// Merged listing: by title, then kind and ID so the order is total
+	sort.Slice(items, func(i, j int) bool {
+		ti, tj := strings.ToLower(items[i].Title), strings.ToLower(items[j].Title)
+		if ti != tj {
+			return ti < tj
+		}
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind < items[j].Kind
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, statuses
+}
+
+// lookup resolves refs through the backends serving their kinds, queried
+// concurrently. It returns the items found by ref and the refs a backend
+// reported as unknown; refs of a failing backend are in neither.
+func (a *aggregator) lookup(ctx context.Context, refs []string) (map[string]ContentItem, []string, []sourceStatus) {
+	ids := map[string][]int{}
+	for _, ref := range refs {
+		if kind, id, err := parseRef(ref); err == nil {
+			ids[kind] = append(ids[kind], id)
+		}
+	}
+	var selected []source
+	for _, src := range a.sources {
+		if len(ids[src.Kind()]) > 0 {
+			selected = append(selected, src)
+		}
+	}
+
+	results := make([]map[int]ContentItem, len(selected))
+	statuses := make([]sourceStatus, len(selected))
+	var wg sync.WaitGroup
+	for i, src := range selected {
+		wg.Add(1)
+		go func(i int, src source) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, a.timeout)
+			defer cancel()
+
+			start := time.Now()
+			found, err := src.Lookup(ctx, ids[src.Kind()])
+			status := sourceStatus{Source: src.Name(), Kind: src.Kind(), DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				log.Printf("Error looking up %s items: %v", src.Name(), err)
+				status.Error = err.Error()
+			} else {
+				status.OK = true
+				status.Count = len(found)
+				results[i] = found
+			}
+			statuses[i] = status
+		}(i, src)
+	}
+	wg.Wait()
+
+	items := map[string]ContentItem{}
+	var missing []string
+	for i, src := range selected {
+		if !statuses[i].OK {
+			continue
+		}
+		for _, id := range ids[src.Kind()] {
+			if item, ok := results[i][id]; ok {
+				items[item.Ref] = item
+			} else {
+				missing = append(missing, contentRef(src.Kind(), id))
+			}
+		}
+	}
+	return items, missing, statuses
+}
+
+// --- Handlers ---
+
+// catalogResponse is the body of GET /api/catalog.
+type catalogResponse struct {
+	Items   []ContentItem  `json:"items"`
+	Sources []sourceStatus `json:"sources"`
+	Partial bool           `json:"partial"` // true when at least one source failed
+}
+
+var validKinds = map[string]bool{KindSeries: true, KindAnime: true, KindMovie: true}
+
+// parseKinds reads the comma-separated "kind" query parameter.
+func parseKinds(raw string) (map[string]bool, error) {
+	kinds := map[string]bool{}
+	for _, k := range strings.Split(raw, ",") {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" {
+			continue
+		}
+		if !validKinds[k] {
+			return nil, fmt.Errorf("kind must be a comma-separated list of series, anime, movie")
+		}
+		kinds[k] = true
+	}
+	return kinds, nil
+}
+
+// catalogHandler handles GET /api/catalog
+func catalogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	kinds, err := parseKinds(r.URL.Query().Get("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var slug string
+	if name := strings.TrimSpace(r.URL.Query().Get("genre")); name != "" {
+		g, ok := genre.Lookup(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown genre %q", name), http.StatusBadRequest)
+			return
+		}
+		slug = g.Slug
+	}
+
+	items, statuses := catalog.collect(r.Context(), kinds)
+	if slug != "" {
+		matched := make([]ContentItem, 0, len(items))
+		for _, item := range items {
+			if genre.List(item.Genres).Contains(slug) {
+				matched = append(matched, item)
+			}
+		}
+		items = matched
+	}
+	resp := catalogResponse{Items: items, Sources: statuses}
+	failed := 0
+	for _, s := range statuses {
+		if !s.OK {
+			failed++
+		}
+	}
+	resp.Partial = failed > 0
+
+	status := http.StatusOK
+	if failed > 0 && failed == len(statuses) {
+		status = http.StatusBadGateway // nothing could be fetched
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding catalog: %v", err)
+	}
+	log.Printf("Handled GET /api/catalog request (%d items, %d failed sources)", len(items), failed)
+}
+
+// --- Main Function ---
+
+// envOr returns the environment variable key, or def when it is unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func main() {
+	timeout, err := time.ParseDuration(envOr("SOURCE_TIMEOUT", "5s"))
+	if err != nil {
+		log.Fatalf("Invalid SOURCE_TIMEOUT: %v", err)
+	}
+	refreshInterval, err := time.ParseDuration(envOr("SEARCH_REFRESH_INTERVAL", "30s"))
+	if err != nil || refreshInterval <= 0 {
+		log.Fatalf("Invalid SEARCH_REFRESH_INTERVAL %q", os.Getenv("SEARCH_REFRESH_INTERVAL"))
+	}
+	httpClient := &http.Client{}
+	animeEndpoint := envOr("ANIME_API_URL", "http://localhost:8082") + "/api/anime/graphql"
+	catalog = &aggregator{
+		timeout: timeout,
+		sources: []source{
+			&seriesSource{baseURL: envOr("SERIES_API_URL", "http://localhost:8081"), client: httpClient},
+			&animeSource{endpoint: animeEndpoint, client: httpClient},
+			&moviesSource{client: movies.New(envOr("MOVIES_API_URL", "http://localhost:8083")+"/api/movies/soap",
+				movies.WithHTTPClient(httpClient), movies.WithTimeout(0))},
+		},
+	}
+
+	// Watchlists are kept here; their items stay in the backends
+	backend, err := storage.Open(storage.ConfigFromEnv("data/catalog.db"))
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer backend.Close()
+	watchlistStore, err := storage.New[Watchlist](backend, "watchlists")
+	if err != nil {
+		log.Fatalf("Failed to open watchlist store: %v", err)
+	}
+	watchlistRepo = newWatchlistRepository(watchlistStore)
+
+	// Watchlists belong to the user named by the bearer token
+	verifier, err := auth.NewVerifier(auth.ConfigFromEnv("config/jwks.json"))
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Keep the search index up to date
+	ctx := context.Background()
+	go searchEngine.run(ctx, refreshInterval)
+	go watchAnimeEvents(ctx, animeEndpoint, searchEngine.requestRefresh)
+
+	http.HandleFunc("/api/catalog", catalogHandler)
+	http.HandleFunc("/api/search", searchHandler)
+	http.HandleFunc("/api/watchlists", watchlistsHandler)
+	http.HandleFunc("/api/watchlists/{id}", watchlistHandler)
+	http.HandleFunc("/api/watchlists/{id}/items", watchlistItemsHandler)
+	http.HandleFunc("/api/watchlists/{id}/items/{ref}", watchlistItemHandler)
+
+	// Simple root handler for health check / info
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Catalog API is running. GET /api/catalog, /api/search?q= or /api/watchlists")
+	})
+
+	port := "8084"
+	fmt.Printf("Catalog API starting on port %s...\n", port)
+	log.Printf("Catalog API starting on port %s...", port)
+
+	log.Fatal(http.ListenAndServe(":"+port, verifier.Authenticate(http.DefaultServeMux)))
+}
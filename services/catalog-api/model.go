@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mbenabdallah/shared/genre"
+)
+
+// --- Common Content Model ---
+
+// Content kinds, one per backend
+const (
+	KindSeries = "series"
+	KindAnime  = "anime"
+	KindMovie  = "movie"
+)
+
+// ContentItem is the normalized form of a series, an anime or a movie.
+type ContentItem struct {
+	Kind     string         `json:"kind"`
+	ID       int            `json:"id"`  // ID within the source backend
+	Ref      string         `json:"ref"` // "<kind>:<id>", unique across backends
+	Title    string         `json:"title"`
+	Genres   []string       `json:"genres"` // canonical genre slugs
+	CoverURL string         `json:"coverUrl"`
+	Units    WatchableUnits `json:"units"`
+	Year     int            `json:"year,omitempty"` // movies only
+
+	// EpisodeTitles feed the search index; they are not part of the listing
+	EpisodeTitles []string `json:"-"`
+}
+
+// WatchableUnits describes what can be watched: the episodes of a series or
+// an anime, or the movie itself.
+type WatchableUnits struct {
+	Unit      string `json:"unit"`      // "episode" or "movie"
+	Total     int    `json:"total"`     // announced number of units
+	Available int    `json:"available"` // units that have a watch URL
+}
+
+// contentRef builds the cross-backend reference of an item.
+func contentRef(kind string, id int) string {
+	return fmt.Sprintf("%s:%d", kind, id)
+}
+
+// parseRef parses a reference of the form "<kind>:<id>".
+func parseRef(ref string) (kind string, id int, err error) {
+	kind, rawID, ok := strings.Cut(strings.TrimSpace(ref), ":")
+	if !ok || !validKinds[kind] {
+		return "", 0, fmt.Errorf("invalid ref %q: want <kind>:<id> with kind series, anime or movie", ref)
+	}
+	if id, err = strconv.Atoi(rawID); err != nil || id <= 0 {
+		return "", 0, fmt.Errorf("invalid ref %q: the ID must be a positive integer", ref)
+	}
+	return kind, id, nil
+}
+
+// itemGenres returns the canonical genre slugs of a backend record, parsing
+// the legacy genre string if the list is missing. Unknown genres are dropped.
+func itemGenres(list []string, legacy string) []string {
+	genres := genre.List(list)
+	genre.Canonicalize(&genres, &legacy)
+	return genres
+}
+
+// --- Backend Shapes ---
+
+// seriesDTO is a series as returned by series-api (GET /api/series).
+type seriesDTO struct {
+	ID            int      `json:"id"`
+	Title         string   `json:"title"`
+	Genres        []string `json:"genres"`
+	Genre         string   `json:"genre"`
+	TotalEpisodes int      `json:"totalEpisodes"`
+	CoverURL      string   `json:"coverUrl"`
+	Episodes      []struct {
+		Title    string `json:"title"`
+		WatchURL string `json:"watchUrl"`
+	} `json:"episodes"`
+}
+
+func (s seriesDTO) toItem() ContentItem {
+	available := 0
+	var titles []string
+	for _, ep := range s.Episodes {
+		if ep.WatchURL != "" {
+			available++
+		}
+		titles = append(titles, ep.Title)
+	}
+	return ContentItem{
+		Kind:     KindSeries,
+		ID:       s.ID,
+		Ref:      contentRef(KindSeries, s.ID),
+		Title:    s.Title,
+		Genres:   itemGenres(s.Genres, s.Genre),
+		CoverURL: s.CoverURL,
+		Units:    WatchableUnits{Unit: "episode", Total: max(s.TotalEpisodes, len(s.Episodes)), Available: available},
+
+		EpisodeTitles: titles,
+	}
+}
+
+// animeDTO is an anime as returned by the anime-api GraphQL animeList query.
+type animeDTO struct {
+	ID          int      `json:"id"`
+	Title       string   `json:"title"`
+	Genres      []string `json:"genres"`
+	Episodes    int      `json:"episodes"`
+	CoverURL    string   `json:"coverUrl"`
+	EpisodeList []struct {
+		Title    string `json:"title"`
+		WatchURL string `json:"watchUrl"`
+	} `json:"episodeList"`
+}
+
+func (a animeDTO) toItem() ContentItem {
+	available := 0
+	var titles []string
+	for _, ep := range a.EpisodeList {
+		if ep.WatchURL != "" {
+			available++
+		}
+		titles = append(titles, ep.Title)
+	}
+	return ContentItem{
+		Kind:     KindAnime,
+		ID:       a.ID,
+		Ref:      contentRef(KindAnime, a.ID),
+		Title:    a.Title,
+		Genres:   itemGenres(a.Genres, ""),
+		CoverURL: a.CoverURL,
+		Units:    WatchableUnits{Unit: "episode", Total: max(a.Episodes, len(a.EpisodeList)), Available: available},
+
+		EpisodeTitles: titles,
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// --- Watchlist Model ---
+
+// Limits per user and per list
+const (
+	maxWatchlists       = 50
+	maxWatchlistEntries = 500
+	maxWatchlistName    = 100 // characters
+)
+
+// Watchlist is an ordered list of titles kept by one user, e.g. "Watch
+// later" or "Favourites". Entries reference items of any backend.
+type Watchlist struct {
+	ID        int              `json:"id"`
+	UserID    string           `json:"userId"`
+	Name      string           `json:"name"`
+	Entries   []WatchlistEntry `json:"items"`
+	CreatedAt time.Time        `json:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+}
+
+// WatchlistEntry is one title of a watchlist.
+type WatchlistEntry struct {
+	Ref     string    `json:"ref"` // "<kind>:<id>", see ContentItem.Ref
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// indexOf returns the position of ref in the list, or -1.
+func (l *Watchlist) indexOf(ref string) int {
+	for i, e := range l.Entries {
+		if e.Ref == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// Errors returned by the repository and by watchlist updates
+var (
+	errWatchlistNameTaken = errors.New("a watchlist with this name already exists")
+	errTooManyWatchlists  = fmt.Errorf("a user can keep at most %d watchlists", maxWatchlists)
+	errWatchlistFull      = fmt.Errorf("a watchlist holds at most %d items", maxWatchlistEntries)
+	errAlreadyListed      = errors.New("the item is already in the watchlist")
+	errNotListed          = errors.New("the item is not in the watchlist")
+	errInvalidOrder       = errors.New("refs must name every item of the watchlist exactly once")
+)
+
+// normalizeWatchlistName trims a list name and checks its length.
+func normalizeWatchlistName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if n := len([]rune(name)); n == 0 || n > maxWatchlistName {
+		return "", fmt.Errorf("name must be 1-%d characters", maxWatchlistName)
+	}
+	return name, nil
+}
+
+// --- Repository ---
+
+// watchlistRepository is the concurrency-safe access point for watchlists.
+// Lists are only ever returned to their owner.
+type watchlistRepository struct {
+	mu    sync.Mutex
+	store storage.Store[Watchlist]
+}
+
+// Repository used by the handlers, set up in main
+var watchlistRepo *watchlistRepository
+
+func newWatchlistRepository(store storage.Store[Watchlist]) *watchlistRepository {
+	return &watchlistRepository{store: store}
+}
+
+// ForUser returns the lists of a user ordered by ID.
+func (r *watchlistRepository) ForUser(userID string) ([]Watchlist, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.forUser(userID)
+}
+
+func (r *watchlistRepository) forUser(userID string) ([]Watchlist, error) {
+	all, err := r.store.List()
+	if err != nil {
+		return nil, err
+	}
+	lists := []Watchlist{}
+	for _, l := range all {
+		if l.UserID == userID {
+			lists = append(lists, l)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ID < lists[j].ID })
+	return lists, nil
+}
+
+// nameTaken reports whether the user has another list called name (ignoring case).
+func nameTaken(lists []Watchlist, id int, name string) bool {
+	for _, l := range lists {
+		if l.ID != id && strings.EqualFold(l.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the list with the given ID if userID owns it.
+func (r *watchlistRepository) Get(userID string, id int) (Watchlist, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(userID, id)
+}
+
+func (r *watchlistRepository) get(userID string, id int) (Watchlist, bool, error) {
+	l, ok, err := r.store.Get(id)
+	if err != nil || !ok || l.UserID != userID {
+		return Watchlist{}, false, err
+	}
+	return l, true, nil
+}
+
+// Create stores a new, empty list.
+func (r *watchlistRepository) Create(userID, name string) (Watchlist, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lists, err := r.forUser(userID)
+	if err != nil {
+		return Watchlist{}, err
+	}
+	if len(lists) >= maxWatchlists {
+		return Watchlist{}, errTooManyWatchlists
+	}
+	if nameTaken(lists, 0, name) {
+		return Watchlist{}, errWatchlistNameTaken
+	}
+	id, err := r.store.NextID()
+	if err != nil {
+		return Watchlist{}, err
+	}
+	now := time.Now().UTC()
+	l := Watchlist{ID: id, UserID: userID, Name: name, Entries: []WatchlistEntry{}, CreatedAt: now, UpdatedAt: now}
+	if err := r.store.Put(id, l); err != nil {
+		return Watchlist{}, err
+	}
+	return l, nil
+}
+
+// Update applies change to a list of userID and stores the result. An error
+// from change is returned as is and nothing is stored.
+func (r *watchlistRepository) Update(userID string, id int, change func(*Watchlist) error) (Watchlist, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok, err := r.get(userID, id)
+	if err != nil || !ok {
+		return Watchlist{}, ok, err
+	}
+	name := l.Name
+	if err := change(&l); err != nil {
+		return Watchlist{}, true, err
+	}
+	if l.Name != name {
+		lists, err := r.forUser(userID)
+		if err != nil {
+			return Watchlist{}, true, err
+		}
+		if nameTaken(lists, id, l.Name) {
+			return Watchlist{}, true, errWatchlistNameTaken
+		}
+	}
+	l.UpdatedAt = time.Now().UTC()
+	if err := r.store.Put(id, l); err != nil {
+		return Watchlist{}, true, err
+	}
+	return l, true, nil
+}
+
+// Delete removes a list of userID and reports whether it existed.
+func (r *watchlistRepository) Delete(userID string, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok, err := r.get(userID, id); err != nil || !ok {
+		return false, err
+	}
+	return r.store.Delete(id)
+}
+
+// Prune removes the entries matching dangling from every list and returns
+// how many were removed.
+func (r *watchlistRepository) Prune(dangling func(WatchlistEntry) bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	all, err := r.store.List()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, l := range all {
+		kept := l.Entries[:0:0]
+		for _, e := range l.Entries {
+			if !dangling(e) {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == len(l.Entries) {
+			continue
+		}
+		removed += len(l.Entries) - len(kept)
+		l.Entries = kept
+		if err := r.store.Put(l.ID, l); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
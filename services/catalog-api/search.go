@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Search ---
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searcher keeps a search index over the merged catalogue. The index is
+// rebuilt periodically and whenever a refresh is requested (e.g. on anime
+// events); a backend that fails during a refresh keeps its previous items.
+type searcher struct {
+	mu        sync.RWMutex
+	index     *searchIndex // nil until the first refresh
+	indexedAt time.Time
+	statuses  []sourceStatus
+	lastItems map[string][]ContentItem // last successful fetch per kind
+
+	refreshCh chan struct{} // coalesces refresh requests
+}
+
+// Search index over all backends, started in main
+var searchEngine = newSearcher()
+
+func newSearcher() *searcher {
+	return &searcher{lastItems: map[string][]ContentItem{}, refreshCh: make(chan struct{}, 1)}
+}
+
+// requestRefresh schedules a rebuild without blocking; requests made while
+// one is pending are merged.
+func (s *searcher) requestRefresh() {
+	select {
+	case s.refreshCh <- struct{}{}:
+	default:
+	}
+}
+
+// run rebuilds the index now, every interval and on request until ctx is done.
+func (s *searcher) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.refreshCh:
+		}
+	}
+}
+
+// refresh fetches every backend and swaps in a new index. Watchlist entries
+// of items that are gone from a backend's listing are pruned.
+func (s *searcher) refresh(ctx context.Context) {
+	started := time.Now().UTC()
+	items, statuses := catalog.collect(ctx, nil)
+	fresh := map[string][]ContentItem{}
+	for _, item := range items {
+		fresh[item.Kind] = append(fresh[item.Kind], item)
+	}
+	if watchlistRepo != nil {
+		pruneDeleted(started, statuses, fresh)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var all []ContentItem
+	for _, st := range statuses {
+		if st.OK {
+			s.lastItems[st.Kind] = fresh[st.Kind]
+		}
+		all = append(all, s.lastItems[st.Kind]...)
+	}
+	s.index = buildIndex(all)
+	s.indexedAt = time.Now().UTC()
+	s.statuses = statuses
+	log.Printf("Rebuilt search index with %d items (%d terms)", len(all), len(s.index.terms))
+}
+
+// searchResponse is the body of GET /api/search.
+type searchResponse struct {
+	Query     string         `json:"query"`
+	Total     int            `json:"total"`
+	Results   []searchResult `json:"results"`
+	IndexedAt time.Time      `json:"indexedAt"`
+	Sources   []sourceStatus `json:"sources"` // outcome of the last refresh
+}
+
+// searchHandler handles GET /api/search?q=
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		http.Error(w, "query parameter q is required", http.StatusBadRequest)
+		return
+	}
+	kinds, err := parseKinds(q.Get("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+
+	searchEngine.mu.RLock()
+	index, resp := searchEngine.index, searchResponse{Query: query, IndexedAt: searchEngine.indexedAt, Sources: searchEngine.statuses}
+	searchEngine.mu.RUnlock()
+	if index == nil {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Search index is not ready yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	var keep func(ContentItem) bool
+	if len(kinds) > 0 {
+		keep = func(item ContentItem) bool { return kinds[item.Kind] }
+	}
+	results := index.search(query, keep)
+	resp.Total = len(results)
+	resp.Results = results[:min(limit, len(results))]
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding search results: %v", err)
+	}
+	log.Printf("Handled GET /api/search request for %q (%d results)", query, resp.Total)
+}
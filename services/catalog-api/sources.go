@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	movies "github.com/mbenabdallah/movies-api/client"
+)
+
+// --- Backend Sources ---
+
+// source fetches the full catalogue of one backend through its native protocol.
+type source interface {
+	Name() string // e.g. "series-api"
+	Kind() string // content kind served by the backend
+	Fetch(ctx context.Context) ([]ContentItem, error)
+	// Lookup fetches the items with the given IDs. IDs the backend does not
+	// know are missing from the result; an error means nothing is known.
+	Lookup(ctx context.Context, ids []int) (map[int]ContentItem, error)
+}
+
+// maxErrorBody bounds how much of an error response is quoted in errors.
+const maxErrorBody = 512
+
+// httpError reports an unexpected HTTP status with the start of the body.
+func httpError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return fmt.Errorf("unexpected HTTP status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// lookupConcurrency bounds the parallel calls of a per-item lookup.
+const lookupConcurrency = 8
+
+// errNotFound is returned by the get functions passed to lookupEach.
+var errNotFound = errors.New("not found")
+
+// lookupEach calls get for every ID, a few at a time, and collects the items
+// found. The first error other than errNotFound fails the lookup.
+func lookupEach(ctx context.Context, ids []int, get func(context.Context, int) (ContentItem, error)) (map[int]ContentItem, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	found := make(map[int]ContentItem, len(ids))
+	sem := make(chan struct{}, lookupConcurrency)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			item, err := get(ctx, id)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, errNotFound):
+			case err != nil:
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+			default:
+				found[id] = item
+			}
+		}(id)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return found, nil
+}
+
+// --- series-api (REST) ---
+
+// seriesPageSize is the page size requested from GET /api/series (its maximum).
+const seriesPageSize = 100
+
+type seriesSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (s *seriesSource) Name() string { return "series-api" }
+func (s *seriesSource) Kind() string { return KindSeries }
+
+// Fetch walks all pages of GET /api/series using the X-Next-Cursor header.
+func (s *seriesSource) Fetch(ctx context.Context) ([]ContentItem, error) {
+	items := []ContentItem{}
+	cursor := ""
+	for {
+		q := url.Values{"limit": {fmt.Sprint(seriesPageSize)}}
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+		var page []seriesDTO
+		next, err := s.fetchPage(ctx, s.baseURL+"/api/series?"+q.Encode(), &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, dto := range page {
+			items = append(items, dto.toItem())
+		}
+		if next == "" || next == cursor || len(page) == 0 {
+			return items, nil
+		}
+		cursor = next
+	}
+}
+
+// fetchPage decodes one page and returns the cursor of the next one.
+func (s *seriesSource) fetchPage(ctx context.Context, pageURL string, page *[]seriesDTO) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", httpError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(page); err != nil {
+		return "", fmt.Errorf("decoding series: %w", err)
+	}
+	return resp.Header.Get("X-Next-Cursor"), nil
+}
+
+// Lookup calls GET /api/series/{id} for each ID.
+func (s *seriesSource) Lookup(ctx context.Context, ids []int) (map[int]ContentItem, error) {
+	return lookupEach(ctx, ids, func(ctx context.Context, id int) (ContentItem, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/series/%d", s.baseURL, id), nil)
+		if err != nil {
+			return ContentItem{}, err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return ContentItem{}, err
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound:
+			return ContentItem{}, errNotFound
+		default:
+			return ContentItem{}, httpError(resp)
+		}
+		var dto seriesDTO
+		if err := json.NewDecoder(resp.Body).Decode(&dto); err != nil {
+			return ContentItem{}, fmt.Errorf("decoding series %d: %w", id, err)
+		}
+		return dto.toItem(), nil
+	})
+}
+
+// --- anime-api (GraphQL) ---
+
+// animeFields are the fields selected for a ContentItem.
+const animeFields = "id title genres episodes coverUrl episodeList { title watchUrl }"
+
+const animeListQuery = `query CatalogAnime {
+  animeList { ` + animeFields + ` }
+}`
+
+type animeSource struct {
+	endpoint string // GraphQL endpoint URL
+	client   *http.Client
+}
+
+func (s *animeSource) Name() string { return "anime-api" }
+func (s *animeSource) Kind() string { return KindAnime }
+
+// graphqlError is an entry of the errors list of a GraphQL response.
+type graphqlError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+// query posts a GraphQL query and decodes its data into data.
+func (s *animeSource) query(ctx context.Context, query string, data interface{}) ([]graphqlError, error) {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpError(resp)
+	}
+
+	result := struct {
+		Data   interface{}    `json:"data"`
+		Errors []graphqlError `json:"errors"`
+	}{Data: data}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding GraphQL response: %w", err)
+	}
+	return result.Errors, nil
+}
+
+// Fetch runs the animeList query.
+func (s *animeSource) Fetch(ctx context.Context) ([]ContentItem, error) {
+	var data struct {
+		AnimeList []animeDTO `json:"animeList"`
+	}
+	errs, err := s.query(ctx, animeListQuery, &data)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("GraphQL error: %s", errs[0].Message)
+	}
+
+	items := make([]ContentItem, 0, len(data.AnimeList))
+	for _, dto := range data.AnimeList {
+		items = append(items, dto.toItem())
+	}
+	return items, nil
+}
+
+// Lookup runs one query selecting anime(id:) once per ID under the alias
+// a<id>. anime-api reports an unknown ID as a "not found" error on its alias.
+func (s *animeSource) Lookup(ctx context.Context, ids []int) (map[int]ContentItem, error) {
+	var q strings.Builder
+	q.WriteString("query CatalogAnimeLookup {")
+	for _, id := range ids {
+		fmt.Fprintf(&q, " a%d: anime(id: %d) { %s }", id, id, animeFields)
+	}
+	q.WriteString(" }")
+
+	data := map[string]*animeDTO{}
+	errs, err := s.query(ctx, q.String(), &data)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range errs {
+		if !strings.Contains(e.Message, "not found") {
+			return nil, fmt.Errorf("GraphQL error: %s", e.Message)
+		}
+	}
+
+	found := make(map[int]ContentItem, len(ids))
+	for _, dto := range data {
+		if dto != nil {
+			found[dto.ID] = dto.toItem()
+		}
+	}
+	return found, nil
+}
+
+// --- movies-api (SOAP) ---
+
+type moviesSource struct {
+	client *movies.Client
+}
+
+func (s *moviesSource) Name() string { return "movies-api" }
+func (s *moviesSource) Kind() string { return KindMovie }
+
+// Fetch calls the ListMovies operation.
+func (s *moviesSource) Fetch(ctx context.Context) ([]ContentItem, error) {
+	list, err := s.client.ListMovies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]ContentItem, 0, len(list))
+	for _, m := range list {
+		items = append(items, movieToItem(m))
+	}
+	return items, nil
+}
+
+// Lookup calls the GetMovieDetails operation for each ID.
+func (s *moviesSource) Lookup(ctx context.Context, ids []int) (map[int]ContentItem, error) {
+	return lookupEach(ctx, ids, func(ctx context.Context, id int) (ContentItem, error) {
+		m, err := s.client.GetMovieDetails(ctx, id)
+		if errors.Is(err, movies.ErrMovieNotFound) {
+			return ContentItem{}, errNotFound
+		}
+		if err != nil {
+			return ContentItem{}, err
+		}
+		return movieToItem(m), nil
+	})
+}
+
+func movieToItem(m movies.Movie) ContentItem {
+	available := 0
+	if m.WatchURL != "" {
+		available = 1
+	}
+	return ContentItem{
+		Kind:     KindMovie,
+		ID:       m.ID,
+		Ref:      contentRef(KindMovie, m.ID),
+		Title:    m.Title,
+		Genres:   itemGenres(m.Genres, m.Genre),
+		CoverURL: m.CoverURL,
+		Units:    WatchableUnits{Unit: "movie", Total: 1, Available: available},
+		Year:     m.Year,
+	}
+}
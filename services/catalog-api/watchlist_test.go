@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// fakeSource serves a fixed set of items of one kind; down makes every call fail.
+type fakeSource struct {
+	mu    sync.Mutex
+	kind  string
+	items map[int]ContentItem
+	down  bool
+}
+
+func newFakeSource(kind string, ids ...int) *fakeSource {
+	s := &fakeSource{kind: kind, items: map[int]ContentItem{}}
+	for _, id := range ids {
+		s.items[id] = ContentItem{Kind: kind, ID: id, Ref: contentRef(kind, id), Title: contentRef(kind, id)}
+	}
+	return s
+}
+
+func (s *fakeSource) Name() string { return s.kind + "-api" }
+func (s *fakeSource) Kind() string { return s.kind }
+
+func (s *fakeSource) Fetch(ctx context.Context) ([]ContentItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, errors.New("backend down")
+	}
+	var items []ContentItem
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *fakeSource) Lookup(ctx context.Context, ids []int) (map[int]ContentItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, errors.New("backend down")
+	}
+	found := map[int]ContentItem{}
+	for _, id := range ids {
+		if item, ok := s.items[id]; ok {
+			found[id] = item
+		}
+	}
+	return found, nil
+}
+
+func (s *fakeSource) remove(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+}
+
+func (s *fakeSource) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+var testKey = auth.SigningKey{ID: "test", Algorithm: auth.HS256, Secret: []byte("0123456789abcdef0123456789abcdef")}
+
+// newWatchlistServer serves the watchlist routes over an in-memory store and
+// the given sources, accepting tokens signed with testKey.
+func newWatchlistServer(t *testing.T, sources ...source) *httptest.Server {
+	t.Helper()
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	jwks, _ := json.Marshal(auth.JWKS{Keys: []auth.JWK{{
+		Kty: "oct", Kid: testKey.ID, K: base64.RawURLEncoding.EncodeToString(testKey.Secret),
+	}}})
+	if err := os.WriteFile(jwksFile, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.New[Watchlist](storage.NewMemoryBackend(), "watchlists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watchlistRepo = newWatchlistRepository(store)
+	catalog = &aggregator{timeout: time.Second, sources: sources}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/watchlists", watchlistsHandler)
+	mux.HandleFunc("/api/watchlists/{id}", watchlistHandler)
+	mux.HandleFunc("/api/watchlists/{id}/items", watchlistItemsHandler)
+	mux.HandleFunc("/api/watchlists/{id}/items/{ref}", watchlistItemHandler)
+	srv := httptest.NewServer(verifier.Authenticate(mux))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, user, path, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "" {
+		token, err := testKey.Sign(auth.Claims{Subject: user, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func refsOf(l Watchlist) string {
+	var refs []string
+	for _, e := range l.Entries {
+		refs = append(refs, e.Ref)
+	}
+	return strings.Join(refs, ",")
+}
+
+func TestWatchlistEditing(t *testing.T) {
+	srv := newWatchlistServer(t, newFakeSource(KindSeries, 1), newFakeSource(KindAnime, 2), newFakeSource(KindMovie, 1))
+
+	if code := do(t, srv, "GET", "", "/api/watchlists", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous: got %d, want 401", code)
+	}
+	var l Watchlist
+	if code := do(t, srv, "POST", "alice", "/api/watchlists", `{"name":"Favourites"}`, &l); code != http.StatusCreated {
+		t.Fatalf("create: got %d, want 201", code)
+	}
+	if code := do(t, srv, "POST", "alice", "/api/watchlists", `{"name":"favourites"}`, nil); code != http.StatusConflict {
+		t.Errorf("duplicate name: got %d, want 409", code)
+	}
+	items := "/api/watchlists/" + strconv.Itoa(l.ID) + "/items"
+
+	for _, ref := range []string{"movie:1", "series:1"} {
+		if code := do(t, srv, "POST", "alice", items, `{"ref":"`+ref+`"}`, &l); code != http.StatusCreated {
+			t.Fatalf("add %s: got %d, want 201", ref, code)
+		}
+	}
+	if code := do(t, srv, "POST", "alice", items, `{"ref":"anime:2","position":0}`, &l); code != http.StatusCreated || refsOf(l) != "anime:2,movie:1,series:1" {
+		t.Fatalf("insert at 0: got %d %s", code, refsOf(l))
+	}
+	for body, want := range map[string]int{
+		`{"ref":"movie:1"}`:  http.StatusConflict,   // already listed
+		`{"ref":"movie:9"}`:  http.StatusNotFound,   // unknown item
+		`{"ref":"books:1"}`:  http.StatusBadRequest, // unknown kind
+		`{"ref":"series:0"}`: http.StatusBadRequest,
+	} {
+		if code := do(t, srv, "POST", "alice", items, body, nil); code != want {
+			t.Errorf("add %s: got %d, want %d", body, code, want)
+		}
+	}
+
+	if code := do(t, srv, "PUT", "alice", items, `{"refs":["series:1","anime:2","movie:1"]}`, &l); code != http.StatusOK || refsOf(l) != "series:1,anime:2,movie:1" {
+		t.Fatalf("reorder: got %d %s", code, refsOf(l))
+	}
+	if code := do(t, srv, "PUT", "alice", items, `{"refs":["series:1","series:1","movie:1"]}`, nil); code != http.StatusBadRequest {
+		t.Errorf("reorder with a duplicate: got %d, want 400", code)
+	}
+	if code := do(t, srv, "DELETE", "alice", items+"/anime:2", "", nil); code != http.StatusNoContent {
+		t.Errorf("remove: got %d, want 204", code)
+	}
+	if code := do(t, srv, "DELETE", "alice", items+"/anime:2", "", nil); code != http.StatusNotFound {
+		t.Errorf("remove twice: got %d, want 404", code)
+	}
+
+	// Lists are private
+	if code := do(t, srv, "GET", "bob", "/api/watchlists/"+strconv.Itoa(l.ID), "", nil); code != http.StatusNotFound {
+		t.Errorf("other user's list: got %d, want 404", code)
+	}
+	var lists []Watchlist
+	if code := do(t, srv, "GET", "bob", "/api/watchlists", "", &lists); code != http.StatusOK || len(lists) != 0 {
+		t.Errorf("bob's lists: got %d %v", code, lists)
+	}
+}
+
+func TestWatchlistResolution(t *testing.T) {
+	series, movies := newFakeSource(KindSeries, 1, 2), newFakeSource(KindMovie, 1)
+	srv := newWatchlistServer(t, series, movies)
+
+	var l Watchlist
+	do(t, srv, "POST", "alice", "/api/watchlists", `{"name":"Watch later"}`, &l)
+	path := "/api/watchlists/" + strconv.Itoa(l.ID)
+	for _, ref := range []string{"series:1", "movie:1", "series:2"} {
+		do(t, srv, "POST", "alice", path+"/items", `{"ref":"`+ref+`"}`, nil)
+	}
+
+	// A failing backend leaves its entries unresolved but listed
+	movies.setDown(true)
+	var resolved resolvedWatchlist
+	if code := do(t, srv, "GET", "alice", path, "", &resolved); code != http.StatusOK {
+		t.Fatalf("get: got %d, want 200", code)
+	}
+	if !resolved.Partial || len(resolved.Items) != 3 || resolved.Items[1].Item != nil || resolved.Items[0].Item.Title != "series:1" {
+		t.Fatalf("partial resolution: %+v", resolved)
+	}
+
+	// A deleted item is pruned when the list is read
+	movies.setDown(false)
+	series.remove(1)
+	resolved = resolvedWatchlist{}
+	do(t, srv, "GET", "alice", path, "", &resolved)
+	if len(resolved.Items) != 2 || resolved.Items[0].Ref != "movie:1" || strings.Join(resolved.Pruned, ",") != "series:1" {
+		t.Fatalf("after deleting series:1: %+v", resolved)
+	}
+
+	// and after an index refresh, for backends that listed successfully
+	series.remove(2)
+	movies.remove(1)
+	movies.setDown(true)
+	items, statuses := catalog.collect(context.Background(), nil)
+	fresh := map[string][]ContentItem{}
+	for _, item := range items {
+		fresh[item.Kind] = append(fresh[item.Kind], item)
+	}
+	pruneDeleted(time.Now().UTC(), statuses, fresh)
+	stored, _, _ := watchlistRepo.Get("alice", l.ID)
+	if refsOf(stored) != "movie:1" {
+		t.Errorf("after refresh: %s, want movie:1", refsOf(stored))
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+)
+
+// --- Watchlists ---
+//
+// Watchlists store references ("series:1", "anime:2", "movie:1") rather than
+// copies of the items. Reading a list resolves every reference through the
+// backend that owns it; references the backend no longer knows are removed
+// from the list. The same happens after every search index refresh, for the
+// kinds whose backend returned a full listing.
+
+// resolvedEntry is a watchlist entry with the item it references. Item is
+// null when the backend holding it could not be reached.
+type resolvedEntry struct {
+	Ref     string       `json:"ref"`
+	AddedAt time.Time    `json:"addedAt"`
+	Item    *ContentItem `json:"item"`
+}
+
+// resolvedWatchlist is the body of GET /api/watchlists/{id}.
+type resolvedWatchlist struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	Items     []resolvedEntry `json:"items"`
+	Pruned    []string        `json:"pruned,omitempty"` // refs removed because their item was deleted
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Sources   []sourceStatus  `json:"sources"`
+	Partial   bool            `json:"partial"` // true when at least one source failed
+}
+
+// watchlistRequest is the body of POST /api/watchlists and PATCH /api/watchlists/{id}.
+type watchlistRequest struct {
+	Name string `json:"name"`
+}
+
+// addItemRequest is the body of POST /api/watchlists/{id}/items. Position is
+// the index the item is inserted at; by default it is appended.
+type addItemRequest struct {
+	Ref      string `json:"ref"`
+	Position *int   `json:"position"`
+}
+
+// reorderRequest is the body of PUT /api/watchlists/{id}/items.
+type reorderRequest struct {
+	Refs []string `json:"refs"`
+}
+
+// --- Request Helpers ---
+
+// requestUser returns the user a request acts for: the subject of its bearer
+// token. Anonymous requests are rejected with 401.
+func requestUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := auth.RequestUser(w, r)
+	if !ok {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// watchlistID parses the {id} path value.
+func watchlistID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid watchlist ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeJSON encodes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeWatchlistError maps repository and update errors to responses.
+func writeWatchlistError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errWatchlistNameTaken), errors.Is(err, errTooManyWatchlists),
+		errors.Is(err, errWatchlistFull), errors.Is(err, errAlreadyListed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, errNotListed):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errInvalidOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error updating watchlist: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// --- Resolution ---
+
+// resolve looks up the items of a list and prunes the entries whose item was
+// deleted. Entries added after the lookup started are never pruned.
+func resolve(r *http.Request, l Watchlist) (resolvedWatchlist, error) {
+	refs := make([]string, len(l.Entries))
+	for i, e := range l.Entries {
+		refs[i] = e.Ref
+	}
+	started := time.Now().UTC()
+	items, missing, statuses := catalog.lookup(r.Context(), refs)
+
+	var pruned []string
+	if len(missing) > 0 {
+		gone := make(map[string]bool, len(missing))
+		for _, ref := range missing {
+			gone[ref] = true
+		}
+		updated, ok, err := watchlistRepo.Update(l.UserID, l.ID, func(l *Watchlist) error {
+			pruned = nil
+			kept := l.Entries[:0:0]
+			for _, e := range l.Entries {
+				if gone[e.Ref] && e.AddedAt.Before(started) {
+					pruned = append(pruned, e.Ref)
+					continue
+				}
+				kept = append(kept, e)
+			}
+			l.Entries = kept
+			return nil
+		})
+		if err != nil {
+			return resolvedWatchlist{}, err
+		}
+		if ok {
+			l = updated
+		}
+		log.Printf("Pruned %d deleted items from watchlist %d", len(pruned), l.ID)
+	}
+
+	resp := resolvedWatchlist{
+		ID:        l.ID,
+		Name:      l.Name,
+		Items:     make([]resolvedEntry, 0, len(l.Entries)),
+		Pruned:    pruned,
+		CreatedAt: l.CreatedAt,
+		UpdatedAt: l.UpdatedAt,
+		Sources:   statuses,
+	}
+	for _, e := range l.Entries {
+		entry := resolvedEntry{Ref: e.Ref, AddedAt: e.AddedAt}
+		if item, ok := items[e.Ref]; ok {
+			entry.Item = &item
+		}
+		resp.Items = append(resp.Items, entry)
+	}
+	for _, s := range statuses {
+		if !s.OK {
+			resp.Partial = true
+		}
+	}
+	return resp, nil
+}
+
+// pruneDeleted removes references to items missing from a full listing of
+// their backend. fresh holds the items per kind of a catalog.collect that
+// started at started; entries added since then may reference items the
+// listing missed and are kept.
+func pruneDeleted(started time.Time, statuses []sourceStatus, fresh map[string][]ContentItem) {
+	listed := map[string]map[string]bool{}
+	for _, st := range statuses {
+		if !st.OK {
+			continue
+		}
+		refs := map[string]bool{}
+		for _, item := range fresh[st.Kind] {
+			refs[item.Ref] = true
+		}
+		listed[st.Kind] = refs
+	}
+	if len(listed) == 0 {
+		return
+	}
+	removed, err := watchlistRepo.Prune(func(e WatchlistEntry) bool {
+		kind, _, err := parseRef(e.Ref)
+		return err == nil && listed[kind] != nil && !listed[kind][e.Ref] && e.AddedAt.Before(started)
+	})
+	if err != nil {
+		log.Printf("Error pruning watchlists: %v", err)
+	} else if removed > 0 {
+		log.Printf("Pruned %d deleted items from watchlists", removed)
+	}
+}
+
+// --- Handler Functions ---
+
+// watchlistsHandler handles GET and POST /api/watchlists
+func watchlistsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		lists, err := watchlistRepo.ForUser(user)
+		if err != nil {
+			log.Printf("Error listing watchlists of %q: %v", user, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, lists)
+		log.Printf("Handled GET /api/watchlists request (%d lists)", len(lists))
+
+	case http.MethodPost:
+		var req watchlistRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+		name, err := normalizeWatchlistName(req.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l, err := watchlistRepo.Create(user, name)
+		if err != nil {
+			writeWatchlistError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, l)
+		log.Printf("Handled POST /api/watchlists request (list %d)", l.ID)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// watchlistHandler handles GET, PATCH and DELETE /api/watchlists/{id}. GET
+// returns the list with its items resolved.
+func watchlistHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := watchlistID(w, r)
+	if !ok {
+		return
+	}
+	notFound := fmt.Sprintf("Watchlist with ID %d not found", id)
+
+	switch r.Method {
+	case http.MethodGet:
+		l, found, err := watchlistRepo.Get(user, id)
+		if err != nil {
+			log.Printf("Error loading watchlist %d: %v", id, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, notFound, http.StatusNotFound)
+			return
+		}
+		resp, err := resolve(r, l)
+		if err != nil {
+			log.Printf("Error resolving watchlist %d: %v", id, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		log.Printf("Handled GET %s request (%d items, %d pruned)", r.URL.Path, len(resp.Items), len(resp.Pruned))
+
+	case http.MethodPatch:
+		var req watchlistRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+		name, err := normalizeWatchlistName(req.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l, found, err := watchlistRepo.Update(user, id, func(l *Watchlist) error {
+			l.Name = name
+			return nil
+		})
+		if err != nil {
+			writeWatchlistError(w, err)
+			return
+		}
+		if !found {
+			http.Error(w, notFound, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, l)
+		log.Printf("Handled PATCH %s request", r.URL.Path)
+
+	case http.MethodDelete:
+		deleted, err := watchlistRepo.Delete(user, id)
+		if err != nil {
+			log.Printf("Error deleting watchlist %d: %v", id, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, notFound, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		log.Printf("Handled DELETE %s request", r.URL.Path)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// watchlistItemsHandler handles POST (add) and PUT (reorder) on
+// /api/watchlists/{id}/items
+func watchlistItemsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := watchlistID(w, r)
+	if !ok {
+		return
+	}
+	notFound := fmt.Sprintf("Watchlist with ID %d not found", id)
+
+	var (
+		l      Watchlist
+		found  bool
+		err    error
+		status = http.StatusOK
+	)
+	switch r.Method {
+	case http.MethodPost:
+		var req addItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+		kind, contentID, perr := parseRef(req.Ref)
+		if perr != nil {
+			http.Error(w, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		ref := contentRef(kind, contentID)
+		if _, found, err = watchlistRepo.Get(user, id); err != nil || !found {
+			break
+		}
+
+		// Only existing items can be added
+		items, _, statuses := catalog.lookup(r.Context(), []string{ref})
+		if _, exists := items[ref]; !exists {
+			if len(statuses) == 1 && !statuses[0].OK {
+				http.Error(w, fmt.Sprintf("%s is unavailable: %s", statuses[0].Source, statuses[0].Error), http.StatusBadGateway)
+			} else {
+				http.Error(w, fmt.Sprintf("Item %s not found", ref), http.StatusNotFound)
+			}
+			return
+		}
+
+		l, found, err = watchlistRepo.Update(user, id, func(l *Watchlist) error {
+			if l.indexOf(ref) >= 0 {
+				return errAlreadyListed
+			}
+			if len(l.Entries) >= maxWatchlistEntries {
+				return errWatchlistFull
+			}
+			pos := len(l.Entries)
+			if req.Position != nil {
+				pos = min(max(*req.Position, 0), len(l.Entries))
+			}
+			l.Entries = append(l.Entries, WatchlistEntry{})
+			copy(l.Entries[pos+1:], l.Entries[pos:])
+			l.Entries[pos] = WatchlistEntry{Ref: ref, AddedAt: time.Now().UTC()}
+			return nil
+		})
+		status = http.StatusCreated
+
+	case http.MethodPut:
+		var req reorderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+		l, found, err = watchlistRepo.Update(user, id, func(l *Watchlist) error {
+			if len(req.Refs) != len(l.Entries) {
+				return errInvalidOrder
+			}
+			reordered := make([]WatchlistEntry, 0, len(l.Entries))
+			seen := map[int]bool{}
+			for _, ref := range req.Refs {
+				i := l.indexOf(ref)
+				if i < 0 || seen[i] {
+					return errInvalidOrder
+				}
+				seen[i] = true
+				reordered = append(reordered, l.Entries[i])
+			}
+			l.Entries = reordered
+			return nil
+		})
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeWatchlistError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, status, l)
+	log.Printf("Handled %s %s request", r.Method, r.URL.Path)
+}
+
+// watchlistItemHandler handles DELETE /api/watchlists/{id}/items/{ref}
+func watchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := watchlistID(w, r)
+	if !ok {
+		return
+	}
+	kind, contentID, err := parseRef(r.PathValue("ref"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ref := contentRef(kind, contentID)
+
+	_, found, err := watchlistRepo.Update(user, id, func(l *Watchlist) error {
+		i := l.indexOf(ref)
+		if i < 0 {
+			return errNotListed
+		}
+		l.Entries = append(l.Entries[:i], l.Entries[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		writeWatchlistError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("Watchlist with ID %d not found", id), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Handled DELETE %s request", r.URL.Path)
+}
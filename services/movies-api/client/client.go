@@ -0,0 +1,273 @@
+// Package client is a typed Go client for the movies SOAP service.
+//
+// It builds SOAP 1.1 or 1.2 envelopes, attaches an optional WS-Security
+// UsernameToken, maps SOAP faults to *Fault errors and honours context
+// cancellation and timeouts. Operations without a typed wrapper can be
+// called through Client.Call.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Namespace is the XML namespace of the movie service operations.
+	Namespace = "http://example.com/movieservice"
+
+	soap11EnvelopeNS = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12EnvelopeNS = "http://www.w3.org/2003/05/soap-envelope"
+
+	wsseNS             = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"
+	wsuNS              = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+	passwordTextType   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordText"
+	passwordDigestType = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest"
+	base64BinaryType   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary"
+)
+
+// DefaultTimeout bounds each call unless overridden with WithTimeout.
+const DefaultTimeout = 30 * time.Second
+
+// maxResponseBytes bounds the size of a response body.
+const maxResponseBytes = 10 << 20
+
+// Version selects the SOAP version of the request envelopes.
+type Version int
+
+const (
+	SOAP11 Version = iota
+	SOAP12
+)
+
+func (v Version) envelopeNS() string {
+	if v == SOAP12 {
+		return soap12EnvelopeNS
+	}
+	return soap11EnvelopeNS
+}
+
+// Client calls the movie service at a SOAP endpoint. It is safe for concurrent use.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	timeout    time.Duration
+	version    Version
+
+	username, password string
+	digest             bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests (default http.DefaultClient).
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTimeout bounds each call; zero disables the per-call timeout so only
+// the caller's context applies.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.timeout = d }
+}
+
+// WithVersion selects SOAP 1.1 (default) or SOAP 1.2 envelopes.
+func WithVersion(v Version) Option {
+	return func(c *Client) { c.version = v }
+}
+
+// WithUsernameToken attaches a WS-Security UsernameToken to every request,
+// as required by the mutating operations. With digest set the password is
+// sent as a PasswordDigest with a fresh nonce and timestamp.
+func WithUsernameToken(username, password string, digest bool) Option {
+	return func(c *Client) {
+		c.username, c.password, c.digest = username, password, digest
+	}
+}
+
+// New returns a client for the SOAP endpoint URL, e.g.
+// "http://localhost:8083/api/movies/soap".
+func New(endpoint string, opts ...Option) *Client {
+	c := &Client{endpoint: endpoint, httpClient: http.DefaultClient, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Call invokes an operation by name. req is marshalled into the Body and
+// must carry its own XMLName in the service namespace (the "mov:" prefix is
+// declared on the envelope); resp receives the response element. Faults are
+// returned as *Fault.
+func (c *Client) Call(ctx context.Context, operation string, req, resp interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	body, err := c.envelope(req)
+	if err != nil {
+		return fmt.Errorf("movies client: building %s request: %w", operation, err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("movies client: %w", err)
+	}
+	action := Namespace + "/" + operation
+	if c.version == SOAP12 {
+		httpReq.Header.Set("Content-Type", fmt.Sprintf("application/soap+xml; charset=utf-8; action=%q", action))
+	} else {
+		httpReq.Header.Set("Content-Type", "text/xml; charset=utf-8")
+		httpReq.Header.Set("SOAPAction", fmt.Sprintf("%q", action))
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("movies client: %s: %w", operation, err)
+	}
+	defer httpResp.Body.Close()
+
+	return decodeResponse(io.LimitReader(httpResp.Body, maxResponseBytes), httpResp.StatusCode, resp)
+}
+
+// envelope renders the request envelope around payload.
+func (c *Client) envelope(payload interface{}) ([]byte, error) {
+	inner, err := xml.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	header, err := c.securityHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<soapenv:Envelope xmlns:soapenv=%q xmlns:mov=%q>`, c.version.envelopeNS(), Namespace)
+	fmt.Fprintf(&b, `<soapenv:Header>%s</soapenv:Header>`, header)
+	b.WriteString(`<soapenv:Body>`)
+	b.Write(inner)
+	b.WriteString(`</soapenv:Body></soapenv:Envelope>`)
+	return b.Bytes(), nil
+}
+
+// securityHeader renders the wsse:Security block, or "" without credentials.
+func (c *Client) securityHeader() (string, error) {
+	if c.username == "" {
+		return "", nil
+	}
+	type password struct {
+		Type  string `xml:"Type,attr"`
+		Value string `xml:",chardata"`
+	}
+	type nonce struct {
+		EncodingType string `xml:"EncodingType,attr"`
+		Value        string `xml:",chardata"`
+	}
+	token := struct {
+		XMLName  xml.Name `xml:"wsse:UsernameToken"`
+		Username string   `xml:"wsse:Username"`
+		Password password `xml:"wsse:Password"`
+		Nonce    *nonce   `xml:"wsse:Nonce,omitempty"`
+		Created  string   `xml:"wsu:Created,omitempty"`
+	}{Username: c.username, Password: password{Type: passwordTextType, Value: c.password}}
+
+	if c.digest {
+		raw := make([]byte, 16)
+		if _, err := rand.Read(raw); err != nil {
+			return "", err
+		}
+		created := time.Now().UTC().Format(time.RFC3339)
+		h := sha1.New()
+		h.Write(raw)
+		h.Write([]byte(created))
+		h.Write([]byte(c.password))
+
+		token.Password = password{Type: passwordDigestType, Value: base64.StdEncoding.EncodeToString(h.Sum(nil))}
+		token.Nonce = &nonce{EncodingType: base64BinaryType, Value: base64.StdEncoding.EncodeToString(raw)}
+		token.Created = created
+	}
+
+	out, err := xml.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`<wsse:Security soapenv:mustUnderstand="1" xmlns:wsse=%q xmlns:wsu=%q>%s</wsse:Security>`, wsseNS, wsuNS, out), nil
+}
+
+// decodeResponse reads a response envelope of either version into resp,
+// or returns the *Fault it carries.
+func decodeResponse(r io.Reader, status int, resp interface{}) error {
+	dec := xml.NewDecoder(r)
+	var envNS string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("movies client: unexpected HTTP %d response: %w", status, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case start.Name.Local == "Envelope" && envNS == "":
+			if start.Name.Space != soap11EnvelopeNS && start.Name.Space != soap12EnvelopeNS {
+				return fmt.Errorf("movies client: HTTP %d response is not a SOAP envelope", status)
+			}
+			envNS = start.Name.Space
+		case start.Name.Space == envNS && start.Name.Local == "Header":
+			if err := dec.Skip(); err != nil {
+				return fmt.Errorf("movies client: malformed response: %w", err)
+			}
+		case start.Name.Space == envNS && start.Name.Local == "Body":
+			return decodeBody(dec, envNS, status, resp)
+		default:
+			return fmt.Errorf("movies client: unexpected element <%s> in HTTP %d response", start.Name.Local, status)
+		}
+	}
+}
+
+// decodeBody decodes the single child of the response Body.
+func decodeBody(dec *xml.Decoder, envNS string, status int, resp interface{}) error {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("movies client: malformed response: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == envNS && t.Name.Local == "Fault" {
+				var raw rawFault
+				if err := dec.DecodeElement(&raw, &t); err != nil {
+					return fmt.Errorf("movies client: malformed fault: %w", err)
+				}
+				return raw.toFault(envNS, status)
+			}
+			if err := dec.DecodeElement(resp, &t); err != nil {
+				return fmt.Errorf("movies client: decoding <%s>: %w", t.Name.Local, err)
+			}
+			return nil
+		case xml.EndElement:
+			return fmt.Errorf("movies client: HTTP %d response has an empty Body", status)
+		}
+	}
+}
+
+// localName strips the prefix of a qualified fault code value.
+func localName(qname string) string {
+	qname = strings.TrimSpace(qname)
+	if i := strings.LastIndex(qname, ":"); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
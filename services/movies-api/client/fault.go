@@ -0,0 +1,96 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors matched by errors.Is against a *Fault.
+var (
+	// ErrMovieNotFound matches faults carrying a MovieNotFoundFault detail.
+	ErrMovieNotFound = errors.New("movie not found")
+	// ErrAuthenticationFailed matches wsse:FailedAuthentication faults.
+	ErrAuthenticationFailed = errors.New("authentication failed")
+)
+
+// Fault is a SOAP fault returned by the service. Codes are local names
+// without prefix; SOAP 1.2 codes are reported in their 1.2 spelling
+// (Sender, Receiver).
+type Fault struct {
+	HTTPStatus int
+	Code       string // e.g. "Client", "Sender", "Server", "FailedAuthentication"
+	Subcode    string // SOAP 1.2 only, e.g. "FailedAuthentication"
+	Reason     string
+	// Detail is the raw XML content of the fault detail, if any
+	Detail []byte
+	// MissingID is set when the detail is a MovieNotFoundFault
+	MissingID int
+}
+
+func (f *Fault) Error() string {
+	code := f.Code
+	if f.Subcode != "" {
+		code += "/" + f.Subcode
+	}
+	return fmt.Sprintf("movies service fault %s: %s", code, f.Reason)
+}
+
+// Is lets errors.Is match the sentinel errors of this package.
+func (f *Fault) Is(target error) bool {
+	switch target {
+	case ErrMovieNotFound:
+		return f.MissingID != 0
+	case ErrAuthenticationFailed:
+		return f.Code == "FailedAuthentication" || f.Subcode == "FailedAuthentication"
+	}
+	return false
+}
+
+// rawFault decodes the fault structures of both SOAP versions.
+type rawFault struct {
+	// SOAP 1.1
+	FaultCode   string     `xml:"faultcode"`
+	FaultString string     `xml:"faultstring"`
+	Detail11    *rawDetail `xml:"detail"`
+	// SOAP 1.2
+	Code struct {
+		Value   string `xml:"Value"`
+		Subcode struct {
+			Value string `xml:"Value"`
+		} `xml:"Subcode"`
+	} `xml:"Code"`
+	Reason struct {
+		Text []string `xml:"Text"`
+	} `xml:"Reason"`
+	Detail12 *rawDetail `xml:"Detail"`
+}
+
+type rawDetail struct {
+	Inner    []byte `xml:",innerxml"`
+	NotFound *struct {
+		ID int `xml:"ID"`
+	} `xml:"http://example.com/movieservice MovieNotFoundFault"`
+}
+
+func (r rawFault) toFault(envNS string, status int) *Fault {
+	f := &Fault{HTTPStatus: status}
+	detail := r.Detail11
+	if envNS == soap12EnvelopeNS {
+		f.Code = localName(r.Code.Value)
+		f.Subcode = localName(r.Code.Subcode.Value)
+		if len(r.Reason.Text) > 0 {
+			f.Reason = r.Reason.Text[0]
+		}
+		detail = r.Detail12
+	} else {
+		f.Code = localName(r.FaultCode)
+		f.Reason = r.FaultString
+	}
+	if detail != nil {
+		f.Detail = detail.Inner
+		if detail.NotFound != nil {
+			f.MissingID = detail.NotFound.ID
+		}
+	}
+	return f
+}
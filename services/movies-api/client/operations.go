@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"encoding/xml"
+)
+
+// Movie is a movie as returned by the service.
+type Movie struct {
+	ID       int      `xml:"ID"`
+	Title    string   `xml:"Title"`
+	Genre    string   `xml:"Genre"`        // display form of Genres, e.g. "Crime, Drama"
+	Genres   []string `xml:"Genres>Genre"` // genre slugs, e.g. "crime"
+	Year     int      `xml:"Year"`
+	CoverURL string   `xml:"CoverURL"`
+	WatchURL string   `xml:"WatchURL"`
+	// AverageRating is the mean of the user reviews (1-10), nil without reviews
+	AverageRating *float64 `xml:"AverageRating"`
+	ReviewCount   int      `xml:"ReviewCount"`
+}
+
+// MovieInput holds the client-supplied fields of AddMovie and UpdateMovie.
+// Genres takes slugs, names or aliases; Genre is the legacy free-form string
+// and is only used when Genres is empty.
+type MovieInput struct {
+	Title    string   `xml:"Title"`
+	Genre    string   `xml:"Genre,omitempty"`
+	Genres   []string `xml:"Genres>Genre,omitempty"`
+	Year     int      `xml:"Year"`
+	CoverURL string   `xml:"CoverURL"`
+	WatchURL string   `xml:"WatchURL"`
+}
+
+// SearchCriteria are the optional SearchMovies criteria; zero values are omitted.
+type SearchCriteria struct {
+	Title     string `xml:"Title,omitempty"`
+	Genre     string `xml:"Genre,omitempty"` // slug, name or alias
+	YearFrom  int    `xml:"YearFrom,omitempty"`
+	YearTo    int    `xml:"YearTo,omitempty"`
+	SortBy    string `xml:"SortBy,omitempty"`    // ID, Title, Genre or Year
+	SortOrder string `xml:"SortOrder,omitempty"` // ASC or DESC
+	Page      int    `xml:"Page,omitempty"`
+	PageSize  int    `xml:"PageSize,omitempty"`
+}
+
+// SearchResult is one page of SearchMovies results.
+type SearchResult struct {
+	TotalCount int     `xml:"TotalCount"`
+	Page       int     `xml:"Page"`
+	PageSize   int     `xml:"PageSize"`
+	Movies     []Movie `xml:"Movies>Movie"`
+}
+
+// ListMovies returns all movies in ID order.
+func (c *Client) ListMovies(ctx context.Context) ([]Movie, error) {
+	req := struct {
+		XMLName xml.Name `xml:"mov:ListMoviesRequest"`
+	}{}
+	var resp struct {
+		Movies []Movie `xml:"Movies>Movie"`
+	}
+	if err := c.Call(ctx, "ListMovies", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Movies, nil
+}
+
+// GetMovieDetails returns the movie with the given ID. A missing movie is
+// reported as a *Fault matching ErrMovieNotFound.
+func (c *Client) GetMovieDetails(ctx context.Context, id int) (Movie, error) {
+	req := struct {
+		XMLName xml.Name `xml:"mov:GetMovieDetailsRequest"`
+		ID      int      `xml:"ID"`
+	}{ID: id}
+	var resp struct {
+		Movie Movie `xml:"Movie"`
+	}
+	if err := c.Call(ctx, "GetMovieDetails", req, &resp); err != nil {
+		return Movie{}, err
+	}
+	return resp.Movie, nil
+}
+
+// SearchMovies returns one page of movies matching the criteria.
+func (c *Client) SearchMovies(ctx context.Context, criteria SearchCriteria) (SearchResult, error) {
+	req := struct {
+		XMLName xml.Name `xml:"mov:SearchMoviesRequest"`
+		SearchCriteria
+	}{SearchCriteria: criteria}
+	var resp SearchResult
+	if err := c.Call(ctx, "SearchMovies", req, &resp); err != nil {
+		return SearchResult{}, err
+	}
+	return resp, nil
+}
+
+// AddMovie stores a new movie and returns it with its server-assigned ID.
+// It requires WithUsernameToken.
+func (c *Client) AddMovie(ctx context.Context, movie MovieInput) (Movie, error) {
+	req := struct {
+		XMLName xml.Name   `xml:"mov:AddMovieRequest"`
+		Movie   MovieInput `xml:"Movie"`
+	}{Movie: movie}
+	var resp struct {
+		Movie Movie `xml:"Movie"`
+	}
+	if err := c.Call(ctx, "AddMovie", req, &resp); err != nil {
+		return Movie{}, err
+	}
+	return resp.Movie, nil
+}
+
+// UpdateMovie replaces the fields of an existing movie. It requires WithUsernameToken.
+func (c *Client) UpdateMovie(ctx context.Context, id int, movie MovieInput) (Movie, error) {
+	req := struct {
+		XMLName xml.Name   `xml:"mov:UpdateMovieRequest"`
+		ID      int        `xml:"ID"`
+		Movie   MovieInput `xml:"Movie"`
+	}{ID: id, Movie: movie}
+	var resp struct {
+		Movie Movie `xml:"Movie"`
+	}
+	if err := c.Call(ctx, "UpdateMovie", req, &resp); err != nil {
+		return Movie{}, err
+	}
+	return resp.Movie, nil
+}
+
+// DeleteMovie deletes a movie. It requires WithUsernameToken.
+func (c *Client) DeleteMovie(ctx context.Context, id int) error {
+	req := struct {
+		XMLName xml.Name `xml:"mov:DeleteMovieRequest"`
+		ID      int      `xml:"ID"`
+	}{ID: id}
+	var resp struct {
+		ID int `xml:"ID"`
+	}
+	return c.Call(ctx, "DeleteMovie", req, &resp)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mbenabdallah/movies-api/client"
+)
+
+// newTestClient starts an httptest server hosting soapHandler.
+func newTestClient(t *testing.T, opts ...client.Option) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(soapHandler))
+	t.Cleanup(srv.Close)
+	return client.New(srv.URL+"/api/movies/soap", opts...)
+}
+
+func TestClientReadOperations(t *testing.T) {
+	for _, version := range []client.Version{client.SOAP11, client.SOAP12} {
+		c := newTestClient(t, client.WithVersion(version))
+		ctx := context.Background()
+
+		movies, err := c.ListMovies(ctx)
+		if err != nil {
+			t.Fatalf("ListMovies: %v", err)
+		}
+		if len(movies) != 2 || movies[0].Title != "Inception" {
+			t.Errorf("ListMovies = %+v", movies)
+		}
+
+		movie, err := c.GetMovieDetails(ctx, 2)
+		if err != nil || movie.Title != "The Dark Knight" || movie.Year != 2008 {
+			t.Errorf("GetMovieDetails(2) = %+v, %v", movie, err)
+		}
+
+		result, err := c.SearchMovies(ctx, client.SearchCriteria{SortBy: "Year", PageSize: 1})
+		if err != nil || result.TotalCount != 2 || len(result.Movies) != 1 || result.Movies[0].ID != 2 {
+			t.Errorf("SearchMovies = %+v, %v", result, err)
+		}
+
+		_, err = c.GetMovieDetails(ctx, 99)
+		var fault *client.Fault
+		if !errors.As(err, &fault) || !errors.Is(err, client.ErrMovieNotFound) || fault.MissingID != 99 {
+			t.Errorf("GetMovieDetails(99) error = %v, want a MovieNotFound fault", err)
+		}
+		if version == client.SOAP12 && (fault.Code != "Sender" || fault.HTTPStatus != http.StatusBadRequest) {
+			t.Errorf("SOAP 1.2 fault = %+v, want Sender with HTTP 400", fault)
+		}
+		if version == client.SOAP11 && (fault.Code != "Client" || fault.HTTPStatus != http.StatusInternalServerError) {
+			t.Errorf("SOAP 1.1 fault = %+v, want Client with HTTP 500", fault)
+		}
+	}
+}
+
+func TestClientWriteOperations(t *testing.T) {
+	ctx := context.Background()
+
+	anonymous := newTestClient(t)
+	if _, err := anonymous.AddMovie(ctx, client.MovieInput{Title: "Heat", Year: 1995}); !errors.Is(err, client.ErrAuthenticationFailed) {
+		t.Fatalf("AddMovie without credentials: err = %v, want ErrAuthenticationFailed", err)
+	}
+
+	for _, digest := range []bool{false, true} {
+		c := newTestClient(t, client.WithUsernameToken("editor", "editor-secret", digest), client.WithVersion(client.SOAP12))
+
+		added, err := c.AddMovie(ctx, client.MovieInput{Title: "Heat", Genre: "Crime", Year: 1995})
+		if err != nil || added.ID == 0 {
+			t.Fatalf("AddMovie (digest=%v) = %+v, %v", digest, added, err)
+		}
+		updated, err := c.UpdateMovie(ctx, added.ID, client.MovieInput{Title: "Heat", Genres: []string{"crime", "Drama"}, Year: 1995})
+		if err != nil || updated.Genre != "Crime, Drama" || !reflect.DeepEqual(updated.Genres, []string{"crime", "drama"}) {
+			t.Errorf("UpdateMovie = %+v, %v", updated, err)
+		}
+		if err := c.DeleteMovie(ctx, added.ID); err != nil {
+			t.Errorf("DeleteMovie: %v", err)
+		}
+		if err := c.DeleteMovie(ctx, added.ID); !errors.Is(err, client.ErrMovieNotFound) {
+			t.Errorf("second DeleteMovie: err = %v, want ErrMovieNotFound", err)
+		}
+	}
+}
+
+func TestClientCancellationAndTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() { close(release); srv.Close() })
+
+	c := client.New(srv.URL, client.WithTimeout(50*time.Millisecond))
+	if _, err := c.ListMovies(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timeout: err = %v, want context.DeadlineExceeded", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.New(srv.URL).ListMovies(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: err = %v, want context.Canceled", err)
+	}
+}
@@ -2,45 +2,72 @@ package main
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"strconv"
+	"os"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/genre"
+	"github.com/mbenabdallah/shared/reviews"
+	"github.com/mbenabdallah/shared/seed"
+	"github.com/mbenabdallah/shared/storage"
 )
 
 // Movie struct definition
 type Movie struct {
-	XMLName  xml.Name `xml:"Movie"` // Used for XML marshalling
-	ID       int      `xml:"ID"`
-	Title    string   `xml:"Title"`
-	Genre    string   `xml:"Genre"`
-	Year     int      `xml:"Year"`
-	CoverURL string   `xml:"CoverURL"`
-	WatchURL string   `xml:"WatchURL"`
+	XMLName  xml.Name   `xml:"Movie" json:"-"` // Used for XML marshalling
+	ID       int        `xml:"ID" json:"id"`
+	Title    string     `xml:"Title" json:"title"`
+	Genre    string     `xml:"Genre" json:"genre"`         // Legacy display form of Genres, accepted on input
+	Genres   genre.List `xml:"Genres>Genre" json:"genres"` // Canonical genre slugs
+	Year     int        `xml:"Year" json:"year"`
+	CoverURL string     `xml:"CoverURL" json:"coverUrl"` // URL to cover image
+	WatchURL string     `xml:"WatchURL" json:"watchUrl"` // URL to watch the movie
 }
 
-// In-memory data store
-var movieStore = make(map[int]Movie)
+// MovieView is a movie as returned by the operations: the stored record plus
+// the average rating and count of its reviews (see withRatings). These fields
+// are read-only and never stored with the movie.
+type MovieView struct {
+	Movie
+	AverageRating *float64 `xml:"AverageRating,omitempty" json:"averageRating"` // absent (null) without reviews
+	ReviewCount   int      `xml:"ReviewCount" json:"reviewCount"`
+}
+
+// Data store, selected by STORAGE_BACKEND (memory or bolt) at startup
+var movieStore storage.Store[Movie]
 
 var storeMutex = &sync.RWMutex{}
 
-// Initialize with sample data
-func init() {
-	movieStore[1] = Movie{ID: 1, Title: "A Bronx Tale", Genre: "Drama", Year: 1993,
-	 CoverURL: "https://www.browardcenter.org/assets/img/edp_BronxTale_2122_955x500-f30235f38f.jpg",
-		WatchURL: "https://ia803103.us.archive.org/32/items/A.Bronx.Tale.1993.720p.BluRay.ENG.x264.HuNTRiNiTY/A.Bronx.Tale.1993.720p.BluRay.ENG.x264.HuN-TRiNiTY.mp4"}
-	movieStore[2] = Movie{ID: 2, Title: "Spirited Away", Genre: "Adventure, Animation, Family", Year: 2001,
-		CoverURL: "https://sysfilessacbe149174fee.blob.core.windows.net/public-container/clients/worthingtheatres/files/e990fc99-41ef-4a4d-ab89-170b390ebb9c.jpg",
-		WatchURL: "https://dn721609.ca.archive.org/0/items/ag_spirited-away/%5Banimegrimoire%5D%20Spirited%20Away%20%5BBD720p%5D%5BF295CDAB%5D.mp4"}
+// genreFields returns the genre list and its legacy string form for the genre registry helpers
+func (m *Movie) genreFields() (*genre.List, *string) {
+	return &m.Genres, &m.Genre
+}
+
+// validateMovie checks a movie fixture before it is written to the store
+func validateMovie(m Movie) error {
+	if strings.TrimSpace(m.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	if err := genre.Canonicalize(m.genreFields()); err != nil {
+		return err
+	}
+	if m.Year < 1888 || m.Year > 2100 {
+		return fmt.Errorf("year %d is out of range", m.Year)
+	}
+	return nil
 }
 
 // --- Simplified SOAP Structure Definitions ---
 
-const soapEnvelopeStart = `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:mov="http://example.com/movieservice">
-   <soapenv:Header/>
+// soapEnvelopeStart takes the envelope namespace and the rendered Header element.
+const soapEnvelopeStart = `<soapenv:Envelope xmlns:soapenv="%s" xmlns:mov="http://example.com/movieservice">
+   %s
    <soapenv:Body>`
 const soapEnvelopeEnd = `
    </soapenv:Body>
@@ -55,100 +82,184 @@ type SoapRequest struct {
 // --- ListMovies Operation ---
 
 type ListMoviesRequest struct {
-	XMLName xml.Name `xml:"mov:ListMoviesRequest"`
+	XMLName xml.Name `xml:"http://example.com/movieservice ListMoviesRequest"`
 	// No parameters for list all
 }
 
 type ListMoviesResponse struct {
-	XMLName xml.Name `xml:"mov:ListMoviesResponse"`
-	Movies  []Movie  `xml:"Movies>Movie"`
+	XMLName xml.Name    `xml:"mov:ListMoviesResponse"`
+	Movies  []MovieView `xml:"Movies>Movie"`
 }
 
 // --- GetMovieDetails Operation ---
 
 type GetMovieDetailsRequest struct {
-	XMLName xml.Name `xml:"mov:GetMovieDetailsRequest"`
+	XMLName xml.Name `xml:"http://example.com/movieservice GetMovieDetailsRequest"`
 	ID      int      `xml:"ID"`
 }
 
 type GetMovieDetailsResponse struct {
-	XMLName xml.Name `xml:"mov:GetMovieDetailsResponse"`
-	Movie   Movie    `xml:"Movie"`
+	XMLName xml.Name  `xml:"mov:GetMovieDetailsResponse"`
+	Movie   MovieView `xml:"Movie"`
+}
+
+// --- AddMovie, UpdateMovie and DeleteMovie Operations ---
+
+// MovieInput holds the client-supplied fields of a movie; IDs are assigned by the server.
+// Genres may be given as a Genres list or, for older clients, as a single Genre string.
+type MovieInput struct {
+	Title    string   `xml:"Title"`
+	Genre    string   `xml:"Genre,omitempty"`
+	Genres   []string `xml:"Genres>Genre,omitempty"`
+	Year     int      `xml:"Year"`
+	CoverURL string   `xml:"CoverURL"`
+	WatchURL string   `xml:"WatchURL"`
+}
+
+// toMovie builds the stored movie for id from the input, resolving its genres
+// against the registry.
+func (in MovieInput) toMovie(id int) (Movie, error) {
+	movie := Movie{
+		ID:       id,
+		Title:    strings.TrimSpace(in.Title),
+		Genre:    in.Genre,
+		Genres:   in.Genres,
+		Year:     in.Year,
+		CoverURL: in.CoverURL,
+		WatchURL: in.WatchURL,
+	}
+	err := genre.Canonicalize(movie.genreFields())
+	return movie, err
+}
+
+type AddMovieRequest struct {
+	XMLName xml.Name   `xml:"http://example.com/movieservice AddMovieRequest"`
+	Movie   MovieInput `xml:"Movie"`
+}
+
+type AddMovieResponse struct {
+	XMLName xml.Name  `xml:"mov:AddMovieResponse"`
+	Movie   MovieView `xml:"Movie"`
+}
+
+type UpdateMovieRequest struct {
+	XMLName xml.Name   `xml:"http://example.com/movieservice UpdateMovieRequest"`
+	ID      int        `xml:"ID"`
+	Movie   MovieInput `xml:"Movie"`
+}
+
+type UpdateMovieResponse struct {
+	XMLName xml.Name  `xml:"mov:UpdateMovieResponse"`
+	Movie   MovieView `xml:"Movie"`
+}
+
+type DeleteMovieRequest struct {
+	XMLName xml.Name `xml:"http://example.com/movieservice DeleteMovieRequest"`
+	ID      int      `xml:"ID"`
+}
+
+type DeleteMovieResponse struct {
+	XMLName xml.Name `xml:"mov:DeleteMovieResponse"`
+	ID      int      `xml:"ID"`
 }
 
-// --- SOAP Fault (Error) Structure ---
+// --- SOAP Fault (Error) Structures ---
 
+// MovieNotFoundFault is the fault detail returned when a movie ID does not exist.
+type MovieNotFoundFault struct {
+	XMLName xml.Name `xml:"mov:MovieNotFoundFault"`
+	ID      int      `xml:"ID"`
+}
+
+// movieNotFound returns a Client fault carrying a MovieNotFoundFault detail.
+func movieNotFound(id int) error {
+	return &soapFaultError{
+		Code:   "Client",
+		Reason: fmt.Sprintf("movie with ID %d not found", id),
+		Detail: MovieNotFoundFault{ID: id},
+	}
+}
+
+// SoapFault is a SOAP 1.1 fault.
 type SoapFault struct {
-	XMLName     xml.Name `xml:"soapenv:Fault"`
-	FaultCode   string   `xml:"faultcode"`
-	FaultString string   `xml:"faultstring"`
+	XMLName     xml.Name     `xml:"soapenv:Fault"`
+	Namespaces  []xml.Attr   `xml:",any,attr"` // declares the prefix of a qualified fault code
+	FaultCode   string       `xml:"faultcode"`
+	FaultString string       `xml:"faultstring"`
+	Detail      *FaultDetail `xml:"detail,omitempty"`
+}
+
+// Soap12Fault is a SOAP 1.2 fault.
+type Soap12Fault struct {
+	XMLName    xml.Name     `xml:"soapenv:Fault"`
+	Namespaces []xml.Attr   `xml:",any,attr"` // declares the prefix of a qualified subcode
+	Code       Soap12Code   `xml:"soapenv:Code"`
+	Reason     Soap12Reason `xml:"soapenv:Reason"`
+	Detail     *FaultDetail `xml:"soapenv:Detail,omitempty"`
+}
+
+type Soap12Code struct {
+	Value   string      `xml:"soapenv:Value"`
+	Subcode *Soap12Code `xml:"soapenv:Subcode,omitempty"`
+}
+
+type Soap12Reason struct {
+	Text Soap12Text `xml:"soapenv:Text"`
+}
+
+type Soap12Text struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+// FaultDetail wraps the application-specific detail element of a fault.
+type FaultDetail struct {
+	Content interface{}
 }
 
 // --- Handler ---
 
+// maxRequestBytes bounds the size of a SOAP request body.
+const maxRequestBytes = 1 << 20
+
 func soapHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.URL.Query()["wsdl"]; ok && r.Method == http.MethodGet {
+		wsdlHandler(w, r)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	bodyBytes, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	req, version, err := parseSoapRequest(http.MaxBytesReader(w, r.Body, maxRequestBytes),
+		r.Header.Get("Content-Type"), r.Header.Get("SOAPAction"))
 	if err != nil {
-		log.Printf("Error reading request body: %v", err)
-		sendSoapFault(w, "Client", "Failed to read request body")
+		log.Printf("Rejected SOAP %s request: %v", version.Name, err)
+		sendSoapError(w, version, err)
 		return
 	}
-	defer r.Body.Close()
-
-	log.Printf("Received SOAP request: %s", string(bodyBytes))
-
-	// Determine the operation by inspecting the XML structure
-	// This is a simplified approach; real SOAP would use SOAPAction header or specific namespaces
-	requestBodyStr := string(bodyBytes)
-	var responsePayload interface{}
-	var responseErr error
-
-	if strings.Contains(requestBodyStr, "ListMoviesRequest") {
-		log.Println("Detected ListMoviesRequest operation")
-		responsePayload, responseErr = handleListMovies()
-	} else if strings.Contains(requestBodyStr, "GetMovieDetailsRequest") {
-		log.Println("Detected GetMovieDetailsRequest operation")
-		// Need to properly unmarshal the ID from the request body
-		var req GetMovieDetailsRequest
-		if err := xml.Unmarshal(bodyBytes, &req); err != nil {
-			// Attempt to extract ID if direct unmarshal fails (due to envelope)
-			// A more robust parser would handle the envelope properly.
-			start := strings.Index(requestBodyStr, "<ID>")
-			end := strings.Index(requestBodyStr, "</ID>")
-			if start > 0 && end > start {
-				idStr := requestBodyStr[start+4 : end]
-				id, convErr := strconv.Atoi(idStr)
-				if convErr == nil {
-					log.Printf("Extracted Movie ID: %d", id)
-					responsePayload, responseErr = handleGetMovieDetails(id)
-				} else {
-					responseErr = fmt.Errorf("invalid ID format in request")
-				}
-			} else {
-				responseErr = fmt.Errorf("could not parse GetMovieDetailsRequest ID")
-			}
-		} else {
-			responsePayload, responseErr = handleGetMovieDetails(req.ID)
+	user := ""
+	if req.Operation.Secured {
+		user, err = authorize(r.Context(), req.Security, req.Operation.Role, time.Now())
+		if err != nil {
+			sendSoapError(w, version, err)
+			return
 		}
-
-	} else {
-		log.Println("Unknown SOAP operation requested")
-		responseErr = fmt.Errorf("unknown operation")
+		log.Printf("Authenticated SOAP user %q for %s", user, req.Operation.Name)
 	}
+	log.Printf("Dispatching SOAP %s operation %s", version.Name, req.Operation.Name)
 
-	// Send Response or Fault
-	if responseErr != nil {
-		log.Printf("Error processing SOAP request: %v", responseErr)
-		sendSoapFault(w, "Server", responseErr.Error())
+	responsePayload, err := req.Operation.invoke(user, req.Payload)
+	if err != nil {
+		log.Printf("Error processing SOAP request: %v", err)
+		sendSoapError(w, version, err)
 		return
 	}
 
-	sendSoapResponse(w, responsePayload)
+	sendSoapResponse(w, version, responsePayload)
 }
 
 // --- Logic Functions ---
@@ -157,54 +268,179 @@ func handleListMovies() (ListMoviesResponse, error) {
 	storeMutex.RLock()
 	defer storeMutex.RUnlock()
 
-	movieList := make([]Movie, 0, len(movieStore))
-	for _, m := range movieStore {
-		movieList = append(movieList, m)
+	movieList, err := movieStore.List()
+	if err != nil {
+		log.Printf("Error listing movies: %v", err)
+		return ListMoviesResponse{}, fmt.Errorf("failed to list movies")
 	}
 
 	log.Printf("Returning %d movies", len(movieList))
-	return ListMoviesResponse{Movies: movieList}, nil
+	return ListMoviesResponse{Movies: withRatings(movieList...)}, nil
 }
 
 func handleGetMovieDetails(id int) (GetMovieDetailsResponse, error) {
 	storeMutex.RLock()
 	defer storeMutex.RUnlock()
 
-	movie, exists := movieStore[id]
+	movie, exists, err := movieStore.Get(id)
+	if err != nil {
+		log.Printf("Error loading movie with ID %d: %v", id, err)
+		return GetMovieDetailsResponse{}, fmt.Errorf("failed to load movie with ID %d", id)
+	}
 	if !exists {
 		log.Printf("Movie with ID %d not found", id)
-		return GetMovieDetailsResponse{}, fmt.Errorf("movie with ID %d not found", id)
+		return GetMovieDetailsResponse{}, movieNotFound(id)
 	}
 
 	log.Printf("Returning details for movie ID %d", id)
-	return GetMovieDetailsResponse{Movie: movie}, nil
+	return GetMovieDetailsResponse{Movie: withRatings(movie)[0]}, nil
+}
+
+func handleAddMovie(input MovieInput) (AddMovieResponse, error) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	movie, err := input.toMovie(0)
+	if err == nil {
+		err = validateMovie(movie)
+	}
+	if err != nil {
+		return AddMovieResponse{}, clientFault("Invalid movie: %v", err)
+	}
+
+	id, err := movieStore.NextID()
+	if err != nil {
+		log.Printf("Error allocating movie ID: %v", err)
+		return AddMovieResponse{}, fmt.Errorf("failed to add movie")
+	}
+	movie.ID = id
+	if err := movieStore.Put(id, movie); err != nil {
+		log.Printf("Error saving movie with ID %d: %v", id, err)
+		return AddMovieResponse{}, fmt.Errorf("failed to add movie")
+	}
+
+	log.Printf("Added movie with ID %d", id)
+	return AddMovieResponse{Movie: withRatings(movie)[0]}, nil
+}
+
+func handleUpdateMovie(id int, input MovieInput) (UpdateMovieResponse, error) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	_, exists, err := movieStore.Get(id)
+	if err != nil {
+		log.Printf("Error loading movie with ID %d: %v", id, err)
+		return UpdateMovieResponse{}, fmt.Errorf("failed to load movie with ID %d", id)
+	}
+	if !exists {
+		log.Printf("Movie with ID %d not found", id)
+		return UpdateMovieResponse{}, movieNotFound(id)
+	}
+
+	movie, err := input.toMovie(id)
+	if err == nil {
+		err = validateMovie(movie)
+	}
+	if err != nil {
+		return UpdateMovieResponse{}, clientFault("Invalid movie: %v", err)
+	}
+	if err := movieStore.Put(id, movie); err != nil {
+		log.Printf("Error saving movie with ID %d: %v", id, err)
+		return UpdateMovieResponse{}, fmt.Errorf("failed to update movie with ID %d", id)
+	}
+
+	log.Printf("Updated movie with ID %d", id)
+	return UpdateMovieResponse{Movie: withRatings(movie)[0]}, nil
+}
+
+func handleDeleteMovie(id int) (DeleteMovieResponse, error) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	deleted, err := movieStore.Delete(id)
+	if err != nil {
+		log.Printf("Error deleting movie with ID %d: %v", id, err)
+		return DeleteMovieResponse{}, fmt.Errorf("failed to delete movie with ID %d", id)
+	}
+	if !deleted {
+		log.Printf("Movie with ID %d not found", id)
+		return DeleteMovieResponse{}, movieNotFound(id)
+	}
+	if _, err := reviewRepo.DeleteItem(id); err != nil {
+		log.Printf("Error deleting reviews of movie with ID %d: %v", id, err)
+	}
+
+	log.Printf("Deleted movie with ID %d", id)
+	return DeleteMovieResponse{ID: id}, nil
 }
 
 // --- Helper Functions ---
 
-func sendSoapResponse(w http.ResponseWriter, payload interface{}) {
+func sendSoapResponse(w http.ResponseWriter, version soapVersion, payload interface{}) {
 	respBytes, err := xml.MarshalIndent(payload, "      ", "  ") // Indent for readability
 	if err != nil {
 		log.Printf("Error marshalling SOAP response: %v", err)
-		sendSoapFault(w, "Server", "Failed to construct response")
+		sendSoapFault(w, version, &soapFaultError{Code: "Server", Reason: "Failed to construct response"})
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8") // Or text/xml
-	// Manually wrap with Envelope
-	fmt.Fprint(w, xml.Header)
-	fmt.Fprint(w, soapEnvelopeStart)
-	fmt.Fprint(w, string(respBytes))
-	fmt.Fprint(w, soapEnvelopeEnd)
+	writeEnvelope(w, version, http.StatusOK, "<soapenv:Header/>", respBytes)
 	log.Println("Sent SOAP response successfully")
 }
 
-func sendSoapFault(w http.ResponseWriter, faultCode, faultString string) {
-	fault := SoapFault{
-		FaultCode:   faultCode,
-		FaultString: faultString,
+// sendSoapError reports err as a SOAP fault; errors that are not
+// *soapFaultError values become Server faults.
+func sendSoapError(w http.ResponseWriter, version soapVersion, err error) {
+	var fault *soapFaultError
+	if !errors.As(err, &fault) {
+		fault = &soapFaultError{Code: "Server", Reason: err.Error()}
+	}
+	sendSoapFault(w, version, fault)
+}
+
+// sendSoapFault writes a fault in the structure of the given SOAP version.
+func sendSoapFault(w http.ResponseWriter, version soapVersion, fault *soapFaultError) {
+	code := version.faultCode(fault.Code)
+	var detail *FaultDetail
+	if fault.Detail != nil {
+		detail = &FaultDetail{Content: fault.Detail}
+	}
+
+	// A qualified subcode is declared on the Fault element. SOAP 1.1 has no
+	// subcodes, so it replaces the fault code (as WS-Security prescribes).
+	var namespaces []xml.Attr
+	subcode := ""
+	if fault.Subcode.Local != "" {
+		prefix := faultCodePrefixes[fault.Subcode.Space]
+		namespaces = []xml.Attr{{Name: xml.Name{Local: "xmlns:" + prefix}, Value: fault.Subcode.Space}}
+		subcode = prefix + ":" + fault.Subcode.Local
 	}
-	faultBytes, err := xml.MarshalIndent(fault, "      ", "  ")
+
+	var body interface{}
+	if version == soap12 {
+		f := Soap12Fault{
+			Namespaces: namespaces,
+			Code:       Soap12Code{Value: "soapenv:" + code},
+			Reason:     Soap12Reason{Text: Soap12Text{Lang: "en", Value: fault.Reason}},
+			Detail:     detail,
+		}
+		if subcode != "" {
+			f.Code.Subcode = &Soap12Code{Value: subcode}
+		}
+		body = f
+	} else {
+		f := SoapFault{
+			Namespaces:  namespaces,
+			FaultCode:   "soapenv:" + code,
+			FaultString: fault.Reason,
+			Detail:      detail,
+		}
+		if subcode != "" {
+			f.FaultCode = subcode
+		}
+		body = f
+	}
+	faultBytes, err := xml.MarshalIndent(body, "      ", "  ")
 	if err != nil {
 		log.Printf("Error marshalling SOAP fault: %v", err)
 		// Fallback to plain text error if fault marshalling fails
@@ -212,20 +448,107 @@ func sendSoapFault(w http.ResponseWriter, faultCode, faultString string) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
-	w.WriteHeader(http.StatusInternalServerError) // SOAP faults usually use 500
+	writeEnvelope(w, version, version.faultStatus(fault.Code), faultHeader(version, fault), faultBytes)
+	if subcode != "" {
+		code += "/" + subcode
+	}
+	log.Printf("Sent SOAP %s Fault: Code=%s, String=%s", version.Name, code, fault.Reason)
+}
+
+// faultCodePrefixes maps the namespaces of qualified fault subcodes to prefixes.
+var faultCodePrefixes = map[string]string{wsseNS: "wsse"}
+
+// faultHeader renders the Header element of a fault envelope. SOAP 1.2
+// reports not-understood header blocks and, on VersionMismatch, the
+// supported envelope versions.
+func faultHeader(version soapVersion, fault *soapFaultError) string {
+	if version != soap12 {
+		return "<soapenv:Header/>"
+	}
+	var blocks []string
+	switch fault.Code {
+	case "MustUnderstand":
+		for _, name := range fault.NotUnderstood {
+			blocks = append(blocks, fmt.Sprintf(`<soapenv:NotUnderstood qname="nu:%s" xmlns:nu="%s"/>`, name.Local, escapeAttr(name.Space)))
+		}
+	case "VersionMismatch":
+		var supported []string
+		for _, v := range supportedVersions {
+			supported = append(supported, fmt.Sprintf(`<soapenv:SupportedEnvelope qname="env:Envelope" xmlns:env="%s"/>`, v.EnvelopeNS))
+		}
+		blocks = append(blocks, "<soapenv:Upgrade>"+strings.Join(supported, "")+"</soapenv:Upgrade>")
+	}
+	if len(blocks) == 0 {
+		return "<soapenv:Header/>"
+	}
+	return "<soapenv:Header>" + strings.Join(blocks, "") + "</soapenv:Header>"
+}
+
+// escapeAttr escapes s for use in a double-quoted XML attribute.
+func escapeAttr(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeEnvelope wraps an already marshalled body payload in an envelope of
+// the given version.
+func writeEnvelope(w http.ResponseWriter, version soapVersion, status int, header string, payload []byte) {
+	w.Header().Set("Content-Type", version.MediaType+"; charset=utf-8")
+	w.WriteHeader(status)
 	fmt.Fprint(w, xml.Header)
-	fmt.Fprint(w, soapEnvelopeStart)
-	fmt.Fprint(w, string(faultBytes))
+	fmt.Fprintf(w, soapEnvelopeStart, version.EnvelopeNS, header)
+	fmt.Fprint(w, string(payload))
 	fmt.Fprint(w, soapEnvelopeEnd)
-	log.Printf("Sent SOAP Fault: Code=%s, String=%s", faultCode, faultString)
 }
 
 // --- Main Function ---
 
 func main() {
-	http.HandleFunc("/soap", soapHandler)
-	http.HandleFunc("/api/movies/soap", soapHandler)
+	backend, err := storage.Open(storage.ConfigFromEnv("data/movies.db"))
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer backend.Close()
+	if movieStore, err = storage.New[Movie](backend, "movies"); err != nil {
+		log.Fatalf("Failed to open movie store: %v", err)
+	}
+	if _, err := seed.Apply(movieStore, seed.OptionsFromEnv("seed"), "movies",
+		func(m Movie) int { return m.ID }, validateMovie); err != nil {
+		log.Fatalf("Failed to seed movie store: %v", err)
+	}
+	// Convert legacy genre strings (fixtures, older records) to genre lists
+	if n, err := genre.Migrate(movieStore, func(m Movie) int { return m.ID }, (*Movie).genreFields); err != nil {
+		log.Fatalf("Failed to migrate movie genres: %v", err)
+	} else if n > 0 {
+		log.Printf("Migrated genres of %d movies", n)
+	}
+	if reviewRepo, err = reviews.Open(backend); err != nil {
+		log.Fatalf("Failed to open review store: %v", err)
+	}
+
+	// Users allowed to call AddMovie, UpdateMovie and DeleteMovie (and to review)
+	credentialsPath := os.Getenv("CREDENTIALS_FILE")
+	if credentialsPath == "" {
+		credentialsPath = "config/credentials.yaml"
+	}
+	if credentials, err = loadCredentials(credentialsPath); err != nil {
+		log.Fatalf("Failed to load credentials: %v", err)
+	}
+
+	// Bearer tokens are verified against the JWKS file; catalogue writes need
+	// the editor role, reviews any user
+	verifier, err := auth.NewVerifier(auth.ConfigFromEnv("config/jwks.json"))
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	http.Handle("/soap", verifier.Authenticate(http.HandlerFunc(soapHandler)))
+	http.Handle("/api/movies/soap", verifier.Authenticate(http.HandlerFunc(soapHandler)))
+
+	// JSON/REST facade over the same store
+	http.HandleFunc("/api/movies/rest/movies", listMoviesRESTHandler)
+	http.HandleFunc("/api/movies/rest/movies/{id}", getMovieRESTHandler)
 
 	// Simple root handler for health check / info
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// --- JSON/REST Facade ---
+//
+// The REST endpoints share the store and the logic functions of the SOAP
+// operations; only the transport and the error mapping differ.
+
+// listMoviesRESTHandler handles GET /api/movies/rest/movies
+func listMoviesRESTHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := handleListMovies()
+	if err != nil {
+		writeRESTError(w, err)
+		return
+	}
+	movies := resp.Movies
+	if movies == nil {
+		movies = []MovieView{} // encode an empty catalogue as [] rather than null
+	}
+	writeJSON(w, movies)
+	log.Println("Handled GET /api/movies/rest/movies request")
+}
+
+// getMovieRESTHandler handles GET /api/movies/rest/movies/{id}
+func getMovieRESTHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid movie ID format", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := handleGetMovieDetails(id)
+	if err != nil {
+		writeRESTError(w, err)
+		return
+	}
+	writeJSON(w, resp.Movie)
+	log.Printf("Handled GET /api/movies/rest/movies/%d request", id)
+}
+
+// writeRESTError maps errors of the logic functions to HTTP statuses:
+// a MovieNotFoundFault becomes 404, other client faults 400, anything else 500.
+func writeRESTError(w http.ResponseWriter, err error) {
+	var fault *soapFaultError
+	switch {
+	case !errors.As(err, &fault):
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	case fault.Detail != nil:
+		if notFound, ok := fault.Detail.(MovieNotFoundFault); ok {
+			http.Error(w, fmt.Sprintf("Movie with ID %d not found", notFound.ID), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fault.Reason, http.StatusBadRequest)
+	case fault.Code == "Client":
+		http.Error(w, fault.Reason, http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// writeJSON encodes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
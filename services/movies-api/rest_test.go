@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRESTFacade(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/movies/rest/movies", listMoviesRESTHandler)
+	mux.HandleFunc("/api/movies/rest/movies/{id}", getMovieRESTHandler)
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string // expected fragment
+	}{
+		{http.MethodGet, "/api/movies/rest/movies", 200, `"title":"Inception"`},
+		{http.MethodGet, "/api/movies/rest/movies/2", 200, `"id":2,"title":"The Dark Knight","genre":"Action, Thriller","genres":["action","thriller"],"year":2008,"coverUrl":"","watchUrl":"","averageRating":null,"reviewCount":0}`},
+		{http.MethodGet, "/api/movies/rest/movies/99", 404, "Movie with ID 99 not found"},
+		{http.MethodGet, "/api/movies/rest/movies/abc", 400, "Invalid movie ID"},
+		{http.MethodPost, "/api/movies/rest/movies", 405, "Method Not Allowed"},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != tc.status || !strings.Contains(rec.Body.String(), tc.body) {
+			t.Errorf("%s %s = %d %q, want %d containing %q", tc.method, tc.path, rec.Code, rec.Body.String(), tc.status, tc.body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/movies/rest/movies", nil))
+	var movies []Movie
+	if err := json.Unmarshal(rec.Body.Bytes(), &movies); err != nil || len(movies) != 2 {
+		t.Errorf("list decoded to %+v, %v", movies, err)
+	}
+}
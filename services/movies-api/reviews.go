@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mbenabdallah/shared/reviews"
+)
+
+// --- Reviews ---
+
+// Reviews of movies, set up in main. Any authenticated user may review; each
+// user has one review per movie.
+var reviewRepo *reviews.Repository
+
+// Review is a user's rating of a movie as returned by the service.
+type Review struct {
+	ID        int       `xml:"ID"`
+	MovieID   int       `xml:"MovieID"`
+	UserID    string    `xml:"UserID"`
+	Rating    int       `xml:"Rating"` // 1 to 10
+	Text      string    `xml:"Text,omitempty"`
+	CreatedAt time.Time `xml:"CreatedAt"`
+	UpdatedAt time.Time `xml:"UpdatedAt"`
+}
+
+func reviewFromRecord(r reviews.Review) Review {
+	return Review{ID: r.ID, MovieID: r.ItemID, UserID: r.UserID, Rating: r.Rating, Text: r.Text, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt}
+}
+
+// withRatings returns the views of movies, with the average rating and
+// review count of each.
+func withRatings(movies ...Movie) []MovieView {
+	rated := make([]MovieView, len(movies))
+	for i, m := range movies {
+		rated[i] = MovieView{Movie: m}
+		summary, err := reviewRepo.Summary(reviews.Item(m.ID))
+		if err != nil {
+			log.Printf("Error loading review summary of movie %d: %v", m.ID, err)
+			continue
+		}
+		rated[i].AverageRating, rated[i].ReviewCount = summary.Average(), summary.Count
+	}
+	return rated
+}
+
+// --- ListReviews, PostReview, UpdateReview and DeleteReview Operations ---
+
+type ListReviewsRequest struct {
+	XMLName xml.Name `xml:"http://example.com/movieservice ListReviewsRequest"`
+	MovieID int      `xml:"MovieID"`
+}
+
+type ListReviewsResponse struct {
+	XMLName       xml.Name `xml:"mov:ListReviewsResponse"`
+	AverageRating *float64 `xml:"AverageRating,omitempty"` // absent without reviews
+	ReviewCount   int      `xml:"ReviewCount"`
+	Reviews       []Review `xml:"Reviews>Review"` // newest first
+}
+
+type PostReviewRequest struct {
+	XMLName xml.Name `xml:"http://example.com/movieservice PostReviewRequest"`
+	MovieID int      `xml:"MovieID"`
+	Rating  int      `xml:"Rating"` // 1 to 10
+	Text    string   `xml:"Text,omitempty"`
+}
+
+type PostReviewResponse struct {
+	XMLName xml.Name `xml:"mov:PostReviewResponse"`
+	Review  Review   `xml:"Review"`
+}
+
+type UpdateReviewRequest struct {
+	XMLName xml.Name `xml:"http://example.com/movieservice UpdateReviewRequest"`
+	MovieID int      `xml:"MovieID"`
+	Rating  int      `xml:"Rating"` // 1 to 10
+	Text    string   `xml:"Text,omitempty"`
+}
+
+type UpdateReviewResponse struct {
+	XMLName xml.Name `xml:"mov:UpdateReviewResponse"`
+	Review  Review   `xml:"Review"`
+}
+
+type DeleteReviewRequest struct {
+	XMLName xml.Name `xml:"http://example.com/movieservice DeleteReviewRequest"`
+	MovieID int      `xml:"MovieID"`
+}
+
+type DeleteReviewResponse struct {
+	XMLName xml.Name `xml:"mov:DeleteReviewResponse"`
+	MovieID int      `xml:"MovieID"`
+}
+
+// ReviewExistsFault is the fault detail returned by PostReview when the user
+// has already reviewed the movie.
+type ReviewExistsFault struct {
+	XMLName xml.Name `xml:"mov:ReviewExistsFault"`
+	MovieID int      `xml:"MovieID"`
+}
+
+// ReviewNotFoundFault is the fault detail returned by UpdateReview and
+// DeleteReview when the user has not reviewed the movie.
+type ReviewNotFoundFault struct {
+	XMLName xml.Name `xml:"mov:ReviewNotFoundFault"`
+	MovieID int      `xml:"MovieID"`
+}
+
+// reviewFault converts a repository error to the fault of the operation.
+func reviewFault(movieID int, err error) error {
+	switch {
+	case errors.Is(err, reviews.ErrInvalid):
+		return clientFault("Invalid review: %v", err)
+	case errors.Is(err, reviews.ErrExists):
+		return &soapFaultError{
+			Code:   "Client",
+			Reason: fmt.Sprintf("you have already reviewed movie %d; use UpdateReview to change your review", movieID),
+			Detail: ReviewExistsFault{MovieID: movieID},
+		}
+	case errors.Is(err, reviews.ErrNotFound):
+		return &soapFaultError{
+			Code:   "Client",
+			Reason: fmt.Sprintf("you have not reviewed movie %d", movieID),
+			Detail: ReviewNotFoundFault{MovieID: movieID},
+		}
+	}
+	log.Printf("Error storing review of movie %d: %v", movieID, err)
+	return fmt.Errorf("failed to store review of movie %d", movieID)
+}
+
+// reviewing runs fn under the read lock of the store once the movie is known
+// to exist; DeleteMovie takes the write lock, so no review can outlive it.
+func reviewing(movieID int, fn func() error) error {
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+
+	_, exists, err := movieStore.Get(movieID)
+	if err != nil {
+		log.Printf("Error loading movie with ID %d: %v", movieID, err)
+		return fmt.Errorf("failed to load movie with ID %d", movieID)
+	}
+	if !exists {
+		return movieNotFound(movieID)
+	}
+	return fn()
+}
+
+func handleListReviews(req ListReviewsRequest) (ListReviewsResponse, error) {
+	var list []reviews.Review
+	var summary reviews.Summary
+	err := reviewing(req.MovieID, func() (err error) {
+		if list, err = reviewRepo.List(reviews.Item(req.MovieID)); err != nil {
+			return err
+		}
+		summary, err = reviewRepo.Summary(reviews.Item(req.MovieID))
+		return err
+	})
+	if err != nil {
+		return ListReviewsResponse{}, err
+	}
+
+	resp := ListReviewsResponse{AverageRating: summary.Average(), ReviewCount: summary.Count}
+	for _, r := range list {
+		resp.Reviews = append(resp.Reviews, reviewFromRecord(r))
+	}
+	log.Printf("Returning %d reviews of movie ID %d", len(resp.Reviews), req.MovieID)
+	return resp, nil
+}
+
+func handlePostReview(user string, req PostReviewRequest) (PostReviewResponse, error) {
+	var review reviews.Review
+	err := reviewing(req.MovieID, func() (err error) {
+		if review, err = reviewRepo.Post(user, reviews.Item(req.MovieID), req.Rating, req.Text); err != nil {
+			return reviewFault(req.MovieID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return PostReviewResponse{}, err
+	}
+	log.Printf("User %q reviewed movie ID %d", user, req.MovieID)
+	return PostReviewResponse{Review: reviewFromRecord(review)}, nil
+}
+
+func handleUpdateReview(user string, req UpdateReviewRequest) (UpdateReviewResponse, error) {
+	var review reviews.Review
+	err := reviewing(req.MovieID, func() (err error) {
+		if review, err = reviewRepo.Update(user, reviews.Item(req.MovieID), req.Rating, req.Text); err != nil {
+			return reviewFault(req.MovieID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return UpdateReviewResponse{}, err
+	}
+	log.Printf("User %q updated the review of movie ID %d", user, req.MovieID)
+	return UpdateReviewResponse{Review: reviewFromRecord(review)}, nil
+}
+
+func handleDeleteReview(user string, req DeleteReviewRequest) (DeleteReviewResponse, error) {
+	err := reviewing(req.MovieID, func() error {
+		if err := reviewRepo.Delete(user, reviews.Item(req.MovieID)); err != nil {
+			return reviewFault(req.MovieID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return DeleteReviewResponse{}, err
+	}
+	log.Printf("User %q deleted the review of movie ID %d", user, req.MovieID)
+	return DeleteReviewResponse{MovieID: req.MovieID}, nil
+}
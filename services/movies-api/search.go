@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/mbenabdallah/shared/genre"
+)
+
+// --- SearchMovies Operation ---
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+// SearchMoviesRequest holds the search criteria; every element is optional.
+// Title matches a case-insensitive substring, Genre a genre slug, name or
+// alias, and the year range is inclusive.
+type SearchMoviesRequest struct {
+	XMLName   xml.Name `xml:"http://example.com/movieservice SearchMoviesRequest"`
+	Title     string   `xml:"Title,omitempty"`
+	Genre     string   `xml:"Genre,omitempty"`
+	YearFrom  int      `xml:"YearFrom,omitempty"`
+	YearTo    int      `xml:"YearTo,omitempty"`
+	SortBy    string   `xml:"SortBy,omitempty"`    // ID (default), Title, Genre or Year
+	SortOrder string   `xml:"SortOrder,omitempty"` // ASC (default) or DESC
+	Page      int      `xml:"Page,omitempty"`      // 1-based, default 1
+	PageSize  int      `xml:"PageSize,omitempty"`  // default 20, at most 100
+}
+
+type SearchMoviesResponse struct {
+	XMLName    xml.Name    `xml:"mov:SearchMoviesResponse"`
+	TotalCount int         `xml:"TotalCount"`
+	Page       int         `xml:"Page"`
+	PageSize   int         `xml:"PageSize"`
+	Movies     []MovieView `xml:"Movies>Movie"`
+}
+
+// movieSortKeys compares two movies by a sort field.
+var movieSortKeys = map[string]func(a, b Movie) int{
+	"id":    func(a, b Movie) int { return 0 }, // the ID tie-breaker does the work
+	"title": func(a, b Movie) int { return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)) },
+	"genre": func(a, b Movie) int { return strings.Compare(strings.ToLower(a.Genre), strings.ToLower(b.Genre)) },
+	"year":  func(a, b Movie) int { return a.Year - b.Year },
+}
+
+// normalize validates the request and fills in defaults.
+func (req *SearchMoviesRequest) normalize() error {
+	req.Title = strings.ToLower(strings.TrimSpace(req.Title))
+	if name := strings.TrimSpace(req.Genre); name != "" {
+		g, ok := genre.Lookup(name)
+		if !ok {
+			return clientFault("Unknown genre %q", name)
+		}
+		req.Genre = g.Slug
+	}
+
+	req.SortBy = strings.ToLower(strings.TrimSpace(req.SortBy))
+	if req.SortBy == "" {
+		req.SortBy = "id"
+	}
+	if _, ok := movieSortKeys[req.SortBy]; !ok {
+		return clientFault("SortBy must be one of ID, Title, Genre, Year")
+	}
+	req.SortOrder = strings.ToUpper(strings.TrimSpace(req.SortOrder))
+	if req.SortOrder == "" {
+		req.SortOrder = "ASC"
+	}
+	if req.SortOrder != "ASC" && req.SortOrder != "DESC" {
+		return clientFault("SortOrder must be ASC or DESC")
+	}
+
+	if req.YearFrom != 0 && req.YearTo != 0 && req.YearFrom > req.YearTo {
+		return clientFault("YearFrom must not be after YearTo")
+	}
+	if req.Page < 0 || req.PageSize < 0 {
+		return clientFault("Page and PageSize must be positive")
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultSearchPageSize
+	}
+	if req.PageSize > maxSearchPageSize {
+		req.PageSize = maxSearchPageSize
+	}
+	return nil
+}
+
+// matches reports whether m satisfies the (normalized) criteria.
+func (req *SearchMoviesRequest) matches(m Movie) bool {
+	if req.Title != "" && !strings.Contains(strings.ToLower(m.Title), req.Title) {
+		return false
+	}
+	if req.Genre != "" && !m.Genres.Contains(req.Genre) {
+		return false
+	}
+	if req.YearFrom != 0 && m.Year < req.YearFrom {
+		return false
+	}
+	if req.YearTo != 0 && m.Year > req.YearTo {
+		return false
+	}
+	return true
+}
+
+func handleSearchMovies(req SearchMoviesRequest) (SearchMoviesResponse, error) {
+	if err := req.normalize(); err != nil {
+		return SearchMoviesResponse{}, err
+	}
+
+	storeMutex.RLock()
+	all, err := movieStore.List()
+	storeMutex.RUnlock()
+	if err != nil {
+		log.Printf("Error listing movies: %v", err)
+		return SearchMoviesResponse{}, fmt.Errorf("failed to search movies")
+	}
+
+	var matched []Movie
+	for _, m := range all {
+		if req.matches(m) {
+			matched = append(matched, m)
+		}
+	}
+
+	// Order by the sort field with the ID as tie-breaker, so the ordering is
+	// total and pages are deterministic.
+	compare := movieSortKeys[req.SortBy]
+	desc := req.SortOrder == "DESC"
+	sort.Slice(matched, func(i, j int) bool {
+		c := compare(matched[i], matched[j])
+		if c == 0 {
+			c = matched[i].ID - matched[j].ID
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	resp := SearchMoviesResponse{TotalCount: len(matched), Page: req.Page, PageSize: req.PageSize}
+	// Compare page numbers before multiplying: a huge Page would overflow
+	// the offset and wrap it to a negative index.
+	if pages := (len(matched) + req.PageSize - 1) / req.PageSize; req.Page-1 < pages {
+		start := (req.Page - 1) * req.PageSize
+		end := min(start+req.PageSize, len(matched))
+		resp.Movies = withRatings(matched[start:end]...)
+	}
+
+	log.Printf("Search matched %d movies, returning %d", resp.TotalCount, len(resp.Movies))
+	return resp, nil
+}
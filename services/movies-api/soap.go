@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/mbenabdallah/shared/auth"
+)
+
+// --- SOAP Envelope Decoding and Operation Dispatch ---
+
+const (
+	soap11EnvelopeNS = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12EnvelopeNS = "http://www.w3.org/2003/05/soap-envelope"
+	movieServiceNS   = "http://example.com/movieservice"
+)
+
+// soapVersion describes one of the supported SOAP versions. Responses are
+// always written in the version of the request envelope.
+type soapVersion struct {
+	Name       string // "1.1" or "1.2"
+	EnvelopeNS string
+	MediaType  string // Content-Type of messages in this version
+}
+
+var (
+	soap11 = soapVersion{Name: "1.1", EnvelopeNS: soap11EnvelopeNS, MediaType: "text/xml"}
+	soap12 = soapVersion{Name: "1.2", EnvelopeNS: soap12EnvelopeNS, MediaType: "application/soap+xml"}
+)
+
+// supportedVersions lists the versions in order of preference.
+var supportedVersions = []soapVersion{soap12, soap11}
+
+// versionByNamespace returns the version whose envelope namespace is ns.
+func versionByNamespace(ns string) (soapVersion, bool) {
+	for _, v := range supportedVersions {
+		if v.EnvelopeNS == ns {
+			return v, true
+		}
+	}
+	return soapVersion{}, false
+}
+
+// versionFromContentType guesses the version from the HTTP media type. It is
+// only used for faults raised before the envelope namespace is known.
+func versionFromContentType(contentType string) soapVersion {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == soap12.MediaType {
+		return soap12
+	}
+	return soap11
+}
+
+// action returns the operation hint of a request: SOAP 1.1 uses the
+// SOAPAction header, SOAP 1.2 the action parameter of the Content-Type
+// (falling back to SOAPAction for lenient clients).
+func (v soapVersion) action(contentType, soapAction string) string {
+	if v.Name == soap12.Name {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil && params["action"] != "" {
+			return params["action"]
+		}
+	}
+	return strings.Trim(strings.TrimSpace(soapAction), `"`)
+}
+
+// faultCode translates a SOAP 1.1 fault code into this version's vocabulary.
+func (v soapVersion) faultCode(code string) string {
+	if v.Name == soap12.Name {
+		switch code {
+		case "Client":
+			return "Sender"
+		case "Server":
+			return "Receiver"
+		}
+	}
+	return code
+}
+
+// faultStatus returns the HTTP status for a fault. The SOAP 1.1 HTTP binding
+// (SOAP 1.1 section 6.2, WS-I Basic Profile 1.1 R1126) requires 500 for every
+// fault, Client faults included, and SOAP 1.1 toolkits only parse a fault
+// from a 500 reply; the fault code and typed detail tell a Client fault from
+// a Server one. The SOAP 1.2 HTTP binding uses 400 for Sender faults.
+func (v soapVersion) faultStatus(code string) int {
+	if v.faultCode(code) == "Sender" {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// soapFaultError is an error that is reported to the client as a SOAP fault
+// with the given SOAP 1.1 fault code (Client, Server, VersionMismatch,
+// MustUnderstand); SOAP 1.2 replies translate the code.
+type soapFaultError struct {
+	Code   string
+	Reason string
+	// Subcode optionally refines Code with a qualified application code
+	// (e.g. wsse:FailedAuthentication)
+	Subcode xml.Name
+	// Detail is an optional element marshalled into the fault detail
+	Detail interface{}
+	// NotUnderstood lists the header blocks behind a MustUnderstand fault
+	NotUnderstood []xml.Name
+}
+
+func (e *soapFaultError) Error() string { return e.Reason }
+
+func clientFault(format string, args ...interface{}) error {
+	return &soapFaultError{Code: "Client", Reason: fmt.Sprintf(format, args...)}
+}
+
+// soapOperation describes one operation of the movie service.
+type soapOperation struct {
+	Name    string   // e.g. "GetMovieDetails"
+	Request xml.Name // QName of the request body element
+	Action  string   // SOAPAction URI
+	// RequestType and ResponseType describe the body payloads (used for the WSDL)
+	RequestType, ResponseType reflect.Type
+	// Faults lists the typed fault details the operation may return
+	Faults []reflect.Type
+	// Secured operations require a WS-Security UsernameToken or a bearer
+	// token with Role; an empty Role admits every authenticated user
+	Secured bool
+	Role    string
+	// decode reads the request element starting at start into a request value
+	decode func(dec *xml.Decoder, start *xml.StartElement) (interface{}, error)
+	// invoke runs the operation on a decoded request value for the
+	// authenticated user ("" for public operations)
+	invoke func(user string, req interface{}) (interface{}, error)
+}
+
+// newOperation builds a soapOperation for a typed request/response pair.
+// The request element is <Name>Request in the service namespace.
+func newOperation[Req, Resp any](name string, handle func(Req) (Resp, error)) soapOperation {
+	return newUserOperation(name, func(_ string, req Req) (Resp, error) { return handle(req) })
+}
+
+// newUserOperation is newOperation for operations acting on behalf of the
+// authenticated user, such as reviews. Combine it with withAuth or
+// withUserAuth.
+func newUserOperation[Req, Resp any](name string, handle func(user string, req Req) (Resp, error)) soapOperation {
+	return soapOperation{
+		Name:    name,
+		Request: xml.Name{Space: movieServiceNS, Local: name + "Request"},
+		Action:  movieServiceNS + "/" + name,
+		// the payload types drive the generated WSDL schema
+		RequestType:  reflect.TypeOf((*Req)(nil)).Elem(),
+		ResponseType: reflect.TypeOf((*Resp)(nil)).Elem(),
+		decode: func(dec *xml.Decoder, start *xml.StartElement) (interface{}, error) {
+			var req Req
+			if err := dec.DecodeElement(&req, start); err != nil {
+				return nil, clientFault("Invalid %sRequest: %v", name, err)
+			}
+			return req, nil
+		},
+		invoke: func(user string, req interface{}) (interface{}, error) {
+			return handle(user, req.(Req))
+		},
+	}
+}
+
+// withFaults declares the typed fault details of an operation.
+func (op soapOperation) withFaults(details ...interface{}) soapOperation {
+	for _, d := range details {
+		op.Faults = append(op.Faults, reflect.TypeOf(d))
+	}
+	return op
+}
+
+// withAuth marks an operation as requiring a WS-Security UsernameToken or a
+// bearer token of an editor.
+func (op soapOperation) withAuth() soapOperation {
+	op.Secured = true
+	op.Role = auth.RoleEditor
+	return op
+}
+
+// withUserAuth marks an operation as requiring a WS-Security UsernameToken
+// or the bearer token of any user.
+func (op soapOperation) withUserAuth() soapOperation {
+	op.Secured = true
+	op.Role = ""
+	return op
+}
+
+// soapOperations lists the operations served by soapHandler.
+var soapOperations = []soapOperation{
+	newOperation("ListMovies", func(ListMoviesRequest) (ListMoviesResponse, error) {
+		return handleListMovies()
+	}),
+	newOperation("GetMovieDetails", func(req GetMovieDetailsRequest) (GetMovieDetailsResponse, error) {
+		return handleGetMovieDetails(req.ID)
+	}).withFaults(MovieNotFoundFault{}),
+	newOperation("SearchMovies", handleSearchMovies),
+	newOperation("AddMovie", func(req AddMovieRequest) (AddMovieResponse, error) {
+		return handleAddMovie(req.Movie)
+	}).withAuth(),
+	newOperation("UpdateMovie", func(req UpdateMovieRequest) (UpdateMovieResponse, error) {
+		return handleUpdateMovie(req.ID, req.Movie)
+	}).withFaults(MovieNotFoundFault{}).withAuth(),
+	newOperation("DeleteMovie", func(req DeleteMovieRequest) (DeleteMovieResponse, error) {
+		return handleDeleteMovie(req.ID)
+	}).withFaults(MovieNotFoundFault{}).withAuth(),
+	newOperation("ListReviews", handleListReviews).withFaults(MovieNotFoundFault{}),
+	newUserOperation("PostReview", handlePostReview).withFaults(MovieNotFoundFault{}, ReviewExistsFault{}).withUserAuth(),
+	newUserOperation("UpdateReview", handleUpdateReview).withFaults(MovieNotFoundFault{}, ReviewNotFoundFault{}).withUserAuth(),
+	newUserOperation("DeleteReview", handleDeleteReview).withFaults(MovieNotFoundFault{}, ReviewNotFoundFault{}).withUserAuth(),
+}
+
+// operationByElement returns the operation whose request element is name.
+func operationByElement(name xml.Name) (soapOperation, bool) {
+	for _, op := range soapOperations {
+		if op.Request == name {
+			return op, true
+		}
+	}
+	return soapOperation{}, false
+}
+
+// operationByAction returns the operation identified by a SOAPAction value.
+// Both the full action URI and the bare operation name are accepted.
+func operationByAction(action string) (soapOperation, bool) {
+	for _, op := range soapOperations {
+		if action == op.Action || action == op.Name {
+			return op, true
+		}
+	}
+	return soapOperation{}, false
+}
+
+// soapRequest is a decoded request envelope.
+type soapRequest struct {
+	Operation soapOperation
+	Payload   interface{}
+	Security  *securityHeader // wsse:Security header block, if present
+}
+
+// headerBlock is a child element of soapenv:Header.
+type headerBlock struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content []byte     `xml:",innerxml"`
+}
+
+// mustUnderstand reports whether the header block is marked mandatory in
+// the envelope namespace envNS.
+func (h headerBlock) mustUnderstand(envNS string) bool {
+	for _, a := range h.Attrs {
+		if a.Name.Space == envNS && a.Name.Local == "mustUnderstand" {
+			v := strings.TrimSpace(a.Value)
+			return v == "1" || v == "true"
+		}
+	}
+	return false
+}
+
+// nextElement returns the next start or end element, skipping character
+// data, comments and processing instructions. DTDs are rejected.
+func nextElement(dec *xml.Decoder) (xml.Token, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement, xml.EndElement:
+			return t, nil
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) != "" {
+				return nil, clientFault("Unexpected character data in SOAP envelope")
+			}
+		case xml.Directive:
+			return nil, clientFault("DTDs are not allowed in SOAP messages")
+		}
+	}
+}
+
+// parseSoapRequest decodes a SOAP 1.1 or 1.2 envelope with namespace
+// awareness and selects the operation from the action hint (if set) or the
+// QName of the body's child element. It also returns the SOAP version the
+// reply must use. Errors are *soapFaultError values.
+func parseSoapRequest(body io.Reader, contentType, soapAction string) (*soapRequest, soapVersion, error) {
+	version := versionFromContentType(contentType)
+	dec := xml.NewDecoder(body)
+
+	tok, err := nextElement(dec)
+	if err != nil {
+		return nil, version, malformed(err)
+	}
+	env, ok := tok.(xml.StartElement)
+	if !ok || env.Name.Local != "Envelope" {
+		return nil, version, clientFault("Message is not a SOAP envelope")
+	}
+	if version, ok = versionByNamespace(env.Name.Space); !ok {
+		version = versionFromContentType(contentType)
+		return nil, version, &soapFaultError{Code: "VersionMismatch", Reason: fmt.Sprintf("Unsupported SOAP envelope namespace %q", env.Name.Space)}
+	}
+	envNS := version.EnvelopeNS
+
+	var req *soapRequest
+	var security *securityHeader
+	sawBody := false
+	for {
+		tok, err := nextElement(dec)
+		if err != nil {
+			return nil, version, malformed(err)
+		}
+		if _, ok := tok.(xml.EndElement); ok {
+			break // </Envelope>
+		}
+		start := tok.(xml.StartElement)
+		switch {
+		case start.Name.Space == envNS && start.Name.Local == "Header" && !sawBody:
+			if security, err = checkHeaders(dec, envNS); err != nil {
+				return nil, version, err
+			}
+		case start.Name.Space == envNS && start.Name.Local == "Body" && !sawBody:
+			sawBody = true
+			if req, err = decodeBody(dec, version.action(contentType, soapAction)); err != nil {
+				return nil, version, err
+			}
+		default:
+			return nil, version, clientFault("Unexpected element <%s> in SOAP envelope", start.Name.Local)
+		}
+	}
+	if !sawBody {
+		return nil, version, clientFault("SOAP envelope has no Body")
+	}
+	if _, err := nextElement(dec); err != io.EOF {
+		return nil, version, clientFault("Unexpected content after SOAP envelope")
+	}
+	req.Security = security
+	return req, version, nil
+}
+
+// checkHeaders reads the children of the Header element. The wsse:Security
+// block is decoded and returned; other mandatory header blocks are not
+// understood and produce a fault.
+func checkHeaders(dec *xml.Decoder, envNS string) (*securityHeader, error) {
+	var security *securityHeader
+	var notUnderstood []xml.Name
+	for {
+		tok, err := nextElement(dec)
+		if err != nil {
+			return nil, malformed(err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			break // </Header>
+		}
+		if start.Name.Space == wsseNS && start.Name.Local == "Security" {
+			if security != nil {
+				return nil, clientFault("Only one wsse:Security header block is allowed")
+			}
+			security = &securityHeader{}
+			if err := dec.DecodeElement(security, &start); err != nil {
+				return nil, malformed(err)
+			}
+			continue
+		}
+		var b headerBlock
+		if err := dec.DecodeElement(&b, &start); err != nil {
+			return nil, malformed(err)
+		}
+		if b.mustUnderstand(envNS) {
+			notUnderstood = append(notUnderstood, b.XMLName)
+		}
+	}
+	if len(notUnderstood) > 0 {
+		first := notUnderstood[0]
+		return nil, &soapFaultError{
+			Code:          "MustUnderstand",
+			Reason:        fmt.Sprintf("Header block {%s}%s was not understood", first.Space, first.Local),
+			NotUnderstood: notUnderstood,
+		}
+	}
+	return security, nil
+}
+
+// decodeBody reads the Body element, which must hold exactly one operation
+// element. action is the SOAPAction (1.1) or Content-Type action (1.2) hint.
+func decodeBody(dec *xml.Decoder, action string) (*soapRequest, error) {
+	tok, err := nextElement(dec)
+	if err != nil {
+		return nil, malformed(err)
+	}
+	start, ok := tok.(xml.StartElement)
+	if !ok {
+		return nil, clientFault("SOAP Body is empty")
+	}
+
+	op, known := operationByElement(start.Name)
+	if action != "" {
+		byAction, found := operationByAction(action)
+		if !found {
+			return nil, clientFault("Unknown SOAPAction %q", action)
+		}
+		if known && byAction.Name != op.Name {
+			return nil, clientFault("SOAPAction %q does not match body element {%s}%s", action, start.Name.Space, start.Name.Local)
+		}
+		op, known = byAction, true
+	}
+	if !known || op.Request != start.Name {
+		return nil, clientFault("Unknown operation element {%s}%s", start.Name.Space, start.Name.Local)
+	}
+
+	payload, err := op.decode(dec, &start)
+	if err != nil {
+		return nil, err
+	}
+	if tok, err := nextElement(dec); err != nil {
+		return nil, malformed(err)
+	} else if _, ok := tok.(xml.EndElement); !ok {
+		return nil, clientFault("SOAP Body must contain a single operation element")
+	}
+	return &soapRequest{Operation: op, Payload: payload}, nil
+}
+
+// malformed converts an XML syntax error into a Client fault.
+func malformed(err error) error {
+	var fault *soapFaultError
+	if errors.As(err, &fault) {
+		return fault
+	}
+	if err == io.EOF {
+		return clientFault("Unexpected end of SOAP message")
+	}
+	return clientFault("Malformed XML: %v", err)
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/genre"
+	"github.com/mbenabdallah/shared/reviews"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+func TestMain(m *testing.M) {
+	backend := storage.NewMemoryBackend()
+	store, err := storage.New[Movie](backend, "movies")
+	if err != nil {
+		log.Fatalf("Failed to open movie store: %v", err)
+	}
+	for _, mv := range []Movie{
+		{ID: 1, Title: "Inception", Genre: "Sci-Fi, Action", Genres: genre.List{"sci-fi", "action"}, Year: 2010},
+		{ID: 2, Title: "The Dark Knight", Genre: "Action, Thriller", Genres: genre.List{"action", "thriller"}, Year: 2008},
+	} {
+		if err := store.Put(mv.ID, mv); err != nil {
+			log.Fatalf("Failed to store movie: %v", err)
+		}
+	}
+	movieStore = store
+	if reviewRepo, err = reviews.Open(backend); err != nil {
+		log.Fatalf("Failed to open review store: %v", err)
+	}
+	credentials = &credentialStore{passwords: map[string]string{"editor": "editor-secret"}}
+	os.Exit(m.Run())
+}
+
+// testEnvelope decodes a response envelope of either version; unqualified
+// field tags match elements in any namespace.
+type testEnvelope struct {
+	XMLName xml.Name
+	Header  struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"Header"`
+	Body struct {
+		Fault *testFault `xml:"Fault"`
+		Inner string     `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+type testFault struct {
+	// SOAP 1.1
+	FaultCode   string `xml:"faultcode"`
+	FaultString string `xml:"faultstring"`
+	Detail11    *struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"detail"`
+	// SOAP 1.2
+	Code struct {
+		Value string `xml:"Value"`
+	} `xml:"Code"`
+	Reason struct {
+		Text struct {
+			Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Text"`
+	} `xml:"Reason"`
+	Detail12 *struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"Detail"`
+}
+
+func envelope(ns, header, body string) string {
+	return `<?xml version="1.0"?><env:Envelope xmlns:env="` + ns + `" xmlns:mov="` + movieServiceNS + `">` +
+		header + `<env:Body>` + body + `</env:Body></env:Envelope>`
+}
+
+// postSoap sends body with the given headers to soapHandler and decodes the reply.
+func postSoap(t *testing.T, body string, headers map[string]string) (*httptest.ResponseRecorder, testEnvelope) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/movies/soap", strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	soapHandler(rec, req)
+
+	var env testEnvelope
+	if err := xml.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("response is not XML: %v\n%s", err, rec.Body.String())
+	}
+	return rec, env
+}
+
+func TestSoapVersionsSuccess(t *testing.T) {
+	tests := []struct {
+		name      string
+		ns        string
+		headers   map[string]string
+		mediaType string
+	}{
+		{"SOAP 1.1", soap11EnvelopeNS, map[string]string{"Content-Type": "text/xml; charset=utf-8", "SOAPAction": `"` + movieServiceNS + `/GetMovieDetails"`}, "text/xml"},
+		{"SOAP 1.2", soap12EnvelopeNS, map[string]string{"Content-Type": `application/soap+xml; charset=utf-8; action="` + movieServiceNS + `/GetMovieDetails"`}, "application/soap+xml"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := envelope(tc.ns, "", `<mov:GetMovieDetailsRequest><ID>2</ID></mov:GetMovieDetailsRequest>`)
+			rec, env := postSoap(t, body, tc.headers)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200\n%s", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tc.mediaType+";") {
+				t.Errorf("Content-Type = %q, want %s", ct, tc.mediaType)
+			}
+			if env.XMLName.Space != tc.ns {
+				t.Errorf("envelope namespace = %q, want %q", env.XMLName.Space, tc.ns)
+			}
+			if env.Body.Fault != nil {
+				t.Fatalf("unexpected fault: %+v", env.Body.Fault)
+			}
+			if !strings.Contains(env.Body.Inner, "<Title>The Dark Knight</Title>") {
+				t.Errorf("body does not contain the movie:\n%s", env.Body.Inner)
+			}
+		})
+	}
+}
+
+func TestSoap11Faults(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		code    string
+	}{
+		{"not found", envelope(soap11EnvelopeNS, "", `<mov:GetMovieDetailsRequest><ID>99</ID></mov:GetMovieDetailsRequest>`), nil, "soapenv:Client"},
+		{"invalid request", envelope(soap11EnvelopeNS, "", `<mov:GetMovieDetailsRequest><ID>abc</ID></mov:GetMovieDetailsRequest>`), nil, "soapenv:Client"},
+		{"unknown operation", envelope(soap11EnvelopeNS, "", `<mov:RateMovieRequest/>`), nil, "soapenv:Client"},
+		{"malformed", `<env:Envelope xmlns:env="` + soap11EnvelopeNS + `"><env:Body>`, nil, "soapenv:Client"},
+		{"action mismatch", envelope(soap11EnvelopeNS, "", `<mov:ListMoviesRequest/>`), map[string]string{"SOAPAction": "GetMovieDetails"}, "soapenv:Client"},
+		{"must understand", envelope(soap11EnvelopeNS, `<env:Header><x:Trace xmlns:x="urn:trace" env:mustUnderstand="1"/></env:Header>`, `<mov:ListMoviesRequest/>`), nil, "soapenv:MustUnderstand"},
+		{"version mismatch", envelope("urn:not-soap", "", `<mov:ListMoviesRequest/>`), map[string]string{"Content-Type": "text/xml"}, "soapenv:VersionMismatch"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, env := postSoap(t, tc.body, tc.headers)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want 500", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/xml;") {
+				t.Errorf("Content-Type = %q, want text/xml", ct)
+			}
+			if env.XMLName.Space != soap11EnvelopeNS {
+				t.Errorf("envelope namespace = %q, want SOAP 1.1", env.XMLName.Space)
+			}
+			f := env.Body.Fault
+			if f == nil {
+				t.Fatalf("expected a fault:\n%s", rec.Body.String())
+			}
+			if f.FaultCode != tc.code {
+				t.Errorf("faultcode = %q, want %q", f.FaultCode, tc.code)
+			}
+			if f.FaultString == "" {
+				t.Error("faultstring is empty")
+			}
+			if f.Code.Value != "" || f.Reason.Text.Value != "" {
+				t.Error("SOAP 1.1 fault must not use SOAP 1.2 Code/Reason elements")
+			}
+		})
+	}
+}
+
+func TestSoap12Faults(t *testing.T) {
+	soap12CT := map[string]string{"Content-Type": "application/soap+xml; charset=utf-8"}
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		status  int
+		code    string
+		header  string // expected fragment of the response Header
+	}{
+		{"not found", envelope(soap12EnvelopeNS, "", `<mov:GetMovieDetailsRequest><ID>99</ID></mov:GetMovieDetailsRequest>`), soap12CT, 400, "soapenv:Sender", ""},
+		{"unknown operation", envelope(soap12EnvelopeNS, "", `<mov:RateMovieRequest/>`), soap12CT, 400, "soapenv:Sender", ""},
+		{"malformed", `<env:Envelope xmlns:env="` + soap12EnvelopeNS + `"><env:Body>`, soap12CT, 400, "soapenv:Sender", ""},
+		{"action mismatch", envelope(soap12EnvelopeNS, "", `<mov:ListMoviesRequest/>`), map[string]string{"Content-Type": `application/soap+xml; action="GetMovieDetails"`}, 400, "soapenv:Sender", ""},
+		{"must understand", envelope(soap12EnvelopeNS, `<env:Header><x:Trace xmlns:x="urn:trace" env:mustUnderstand="true"/></env:Header>`, `<mov:ListMoviesRequest/>`), soap12CT, 500, "soapenv:MustUnderstand", `<soapenv:NotUnderstood qname="nu:Trace" xmlns:nu="urn:trace"/>`},
+		{"version mismatch", envelope("urn:not-soap", "", `<mov:ListMoviesRequest/>`), soap12CT, 500, "soapenv:VersionMismatch", `<soapenv:SupportedEnvelope qname="env:Envelope" xmlns:env="` + soap11EnvelopeNS + `"/>`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, env := postSoap(t, tc.body, tc.headers)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d", rec.Code, tc.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/soap+xml;") {
+				t.Errorf("Content-Type = %q, want application/soap+xml", ct)
+			}
+			if env.XMLName.Space != soap12EnvelopeNS {
+				t.Errorf("envelope namespace = %q, want SOAP 1.2", env.XMLName.Space)
+			}
+			f := env.Body.Fault
+			if f == nil {
+				t.Fatalf("expected a fault:\n%s", rec.Body.String())
+			}
+			if f.Code.Value != tc.code {
+				t.Errorf("Code/Value = %q, want %q", f.Code.Value, tc.code)
+			}
+			if f.Reason.Text.Value == "" || f.Reason.Text.Lang != "en" {
+				t.Errorf("Reason/Text = %+v, want non-empty text with xml:lang=en", f.Reason.Text)
+			}
+			if f.FaultCode != "" || f.FaultString != "" {
+				t.Error("SOAP 1.2 fault must not use SOAP 1.1 faultcode/faultstring")
+			}
+			if tc.header != "" && !strings.Contains(env.Header.Inner, tc.header) {
+				t.Errorf("Header does not contain %s:\n%s", tc.header, env.Header.Inner)
+			}
+		})
+	}
+}
+
+func TestSoapFaultDetail(t *testing.T) {
+	type notFound struct {
+		XMLName xml.Name `xml:"mov:MovieNotFoundFault"`
+		ID      int      `xml:"ID"`
+	}
+	fault := &soapFaultError{Code: "Client", Reason: "movie not found", Detail: notFound{ID: 7}}
+
+	for _, version := range supportedVersions {
+		t.Run("SOAP "+version.Name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			sendSoapFault(rec, version, fault)
+
+			var env testEnvelope
+			if err := xml.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+				t.Fatalf("response is not XML: %v", err)
+			}
+			f := env.Body.Fault
+			if f == nil {
+				t.Fatalf("expected a fault:\n%s", rec.Body.String())
+			}
+			detail := f.Detail11
+			if version == soap12 {
+				detail = f.Detail12
+			}
+			if detail == nil || !strings.Contains(detail.Inner, "<ID>7</ID>") {
+				t.Errorf("fault detail missing:\n%s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSoapServerFault(t *testing.T) {
+	tests := []struct {
+		version soapVersion
+		code    string
+	}{
+		{soap11, "soapenv:Server"},
+		{soap12, "soapenv:Receiver"},
+	}
+	for _, tc := range tests {
+		t.Run("SOAP "+tc.version.Name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			sendSoapError(rec, tc.version, errors.New("failed to list movies"))
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want 500", rec.Code)
+			}
+			var env testEnvelope
+			if err := xml.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+				t.Fatalf("response is not XML: %v", err)
+			}
+			if f := env.Body.Fault; f == nil || f.FaultCode+f.Code.Value != tc.code {
+				t.Errorf("expected a %s fault:\n%s", tc.code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMovieWriteOperations(t *testing.T) {
+	auth := securityHeaderXML("editor", passwordTextType, "editor-secret", "", "")
+	post := func(body string) (*httptest.ResponseRecorder, testEnvelope) {
+		return postSoap(t, envelope(soap11EnvelopeNS, auth, body), nil)
+	}
+
+	rec, env := post(`<mov:AddMovieRequest><Movie><ID>1</ID><Title> Heat </Title><Genre>Crime</Genre><Year>1995</Year></Movie></mov:AddMovieRequest>`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("AddMovie status = %d\n%s", rec.Code, rec.Body.String())
+	}
+	var added struct {
+		Movie Movie `xml:"AddMovieResponse>Movie"`
+	}
+	if err := xml.Unmarshal([]byte("<Body>"+env.Body.Inner+"</Body>"), &added); err != nil {
+		t.Fatalf("decoding AddMovieResponse: %v", err)
+	}
+	id := added.Movie.ID
+	if id <= 2 || added.Movie.Title != "Heat" {
+		t.Fatalf("AddMovie returned %+v, want a new server-assigned ID and trimmed title", added.Movie)
+	}
+	if !strings.Contains(env.Body.Inner, "<ReviewCount>0</ReviewCount>") {
+		t.Errorf("AddMovie response lacks the review count:\n%s", env.Body.Inner)
+	}
+
+	idXML := "<ID>" + strconv.Itoa(id) + "</ID>"
+	if rec, _ := post(`<mov:UpdateMovieRequest>` + idXML + `<Movie><Title>Heat</Title><Genre>Crime Drama</Genre><Year>1995</Year></Movie></mov:UpdateMovieRequest>`); rec.Code != http.StatusOK {
+		t.Fatalf("UpdateMovie status = %d\n%s", rec.Code, rec.Body.String())
+	}
+	if movie, _, _ := movieStore.Get(id); movie.Genre != "Crime, Drama" || !reflect.DeepEqual(movie.Genres, genre.List{"crime", "drama"}) {
+		t.Errorf("stored genres = %q / %v after update with a legacy genre string", movie.Genre, movie.Genres)
+	}
+	if rec, _ := post(`<mov:UpdateMovieRequest>` + idXML + `<Movie><Title>Heat</Title><Genre>ignored</Genre><Genres><Genre>Thriller</Genre><Genre>crime</Genre></Genres><Year>1995</Year></Movie></mov:UpdateMovieRequest>`); rec.Code != http.StatusOK {
+		t.Fatalf("UpdateMovie with a genre list: status = %d\n%s", rec.Code, rec.Body.String())
+	}
+	if movie, _, _ := movieStore.Get(id); movie.Genre != "Thriller, Crime" || !reflect.DeepEqual(movie.Genres, genre.List{"thriller", "crime"}) {
+		t.Errorf("stored genres = %q / %v after update with a genre list", movie.Genre, movie.Genres)
+	}
+	if rec, _ := post(`<mov:UpdateMovieRequest>` + idXML + `<Movie><Title>Heat</Title><Genre>Heist Noir</Genre><Year>1995</Year></Movie></mov:UpdateMovieRequest>`); rec.Code == http.StatusOK {
+		t.Error("UpdateMovie accepted an unknown genre")
+	}
+	if rec, _ := post(`<mov:UpdateMovieRequest>` + idXML + `<Movie><Title>Heat</Title><Year>1700</Year></Movie></mov:UpdateMovieRequest>`); rec.Code == http.StatusOK {
+		t.Error("UpdateMovie accepted an invalid year")
+	}
+
+	if rec, _ := post(`<mov:DeleteMovieRequest>` + idXML + `</mov:DeleteMovieRequest>`); rec.Code != http.StatusOK {
+		t.Fatalf("DeleteMovie status = %d\n%s", rec.Code, rec.Body.String())
+	}
+	_, env = post(`<mov:DeleteMovieRequest>` + idXML + `</mov:DeleteMovieRequest>`)
+	f := env.Body.Fault
+	if f == nil || f.FaultCode != "soapenv:Client" || f.Detail11 == nil || !strings.Contains(f.Detail11.Inner, "MovieNotFoundFault") {
+		t.Errorf("deleting a missing movie should return a MovieNotFoundFault Client fault, got %+v", f)
+	}
+}
+
+func TestReviewOperations(t *testing.T) {
+	// post sends body as the bearer user (anonymously when user is empty)
+	post := func(user, body string) (string, *testFault) {
+		req := httptest.NewRequest(http.MethodPost, "/api/movies/soap", strings.NewReader(envelope(soap11EnvelopeNS, "", body)))
+		if user != "" {
+			req = req.WithContext(auth.NewContext(req.Context(), &auth.Claims{Subject: user}))
+		}
+		rec := httptest.NewRecorder()
+		soapHandler(rec, req)
+		var env testEnvelope
+		if err := xml.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+			t.Fatalf("response is not XML: %v\n%s", err, rec.Body.String())
+		}
+		return env.Body.Inner, env.Body.Fault
+	}
+	review := func(op string, rating int) string {
+		return fmt.Sprintf(`<mov:%sReviewRequest><MovieID>1</MovieID><Rating>%d</Rating><Text>Dreams within dreams</Text></mov:%sReviewRequest>`, op, rating, op)
+	}
+
+	if _, f := post("", review("Post", 8)); f == nil || f.FaultCode != "wsse:FailedAuthentication" {
+		t.Errorf("anonymous PostReview: fault %+v, want wsse:FailedAuthentication", f)
+	}
+	for _, c := range []struct{ user, body string }{{"alice", review("Post", 8)}, {"bob", review("Post", 5)}} {
+		if _, f := post(c.user, c.body); f != nil {
+			t.Fatalf("PostReview as %s: %+v", c.user, f)
+		}
+	}
+	if _, f := post("alice", review("Post", 9)); f == nil || !strings.Contains(f.Detail11.Inner, "ReviewExistsFault") {
+		t.Errorf("second PostReview: fault %+v, want a ReviewExistsFault", f)
+	}
+	if _, f := post("alice", review("Post", 0)); f == nil || f.FaultCode != "soapenv:Client" {
+		t.Errorf("PostReview with rating 0: fault %+v, want a Client fault", f)
+	}
+	if _, f := post("carol", review("Update", 4)); f == nil || !strings.Contains(f.Detail11.Inner, "ReviewNotFoundFault") {
+		t.Errorf("UpdateReview without a review: fault %+v, want a ReviewNotFoundFault", f)
+	}
+	if _, f := post("bob", review("Update", 7)); f != nil {
+		t.Fatalf("UpdateReview: %+v", f)
+	}
+
+	body, f := post("", `<mov:GetMovieDetailsRequest><ID>1</ID></mov:GetMovieDetailsRequest>`)
+	if f != nil || !strings.Contains(body, "<AverageRating>7.5</AverageRating>") || !strings.Contains(body, "<ReviewCount>2</ReviewCount>") {
+		t.Errorf("GetMovieDetails does not report the average rating:\n%s", body)
+	}
+	if body, f = post("", `<mov:ListReviewsRequest><MovieID>1</MovieID></mov:ListReviewsRequest>`); f != nil || strings.Count(body, "<Review>") != 2 {
+		t.Errorf("ListReviews:\n%s", body)
+	}
+
+	for _, user := range []string{"alice", "bob"} {
+		if _, f := post(user, `<mov:DeleteReviewRequest><MovieID>1</MovieID></mov:DeleteReviewRequest>`); f != nil {
+			t.Fatalf("DeleteReview as %s: %+v", user, f)
+		}
+	}
+	if body, _ = post("", `<mov:GetMovieDetailsRequest><ID>1</ID></mov:GetMovieDetailsRequest>`); strings.Contains(body, "AverageRating") {
+		t.Errorf("AverageRating of a movie without reviews:\n%s", body)
+	}
+}
+
+func TestSearchMovies(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   SearchMoviesRequest
+		total int
+		ids   []int
+	}{
+		{"defaults", SearchMoviesRequest{}, 2, []int{1, 2}},
+		{"title substring", SearchMoviesRequest{Title: "KNIGHT"}, 1, []int{2}},
+		{"genre and years", SearchMoviesRequest{Genre: "Action", YearFrom: 2009, YearTo: 2020}, 1, []int{1}},
+		{"genre alias", SearchMoviesRequest{Genre: "science fiction"}, 1, []int{1}},
+		{"sort by year", SearchMoviesRequest{SortBy: "Year"}, 2, []int{2, 1}},
+		{"descending paged", SearchMoviesRequest{SortBy: "title", SortOrder: "desc", PageSize: 1, Page: 2}, 2, []int{1}},
+		{"past last page", SearchMoviesRequest{Page: 5}, 2, nil},
+		{"huge page", SearchMoviesRequest{Page: math.MaxInt / 50, PageSize: 100}, 2, nil},
+		{"largest page", SearchMoviesRequest{Page: math.MaxInt, PageSize: 100}, 2, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := handleSearchMovies(tc.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var ids []int
+			for _, m := range resp.Movies {
+				ids = append(ids, m.ID)
+			}
+			if resp.TotalCount != tc.total || fmt.Sprint(ids) != fmt.Sprint(tc.ids) {
+				t.Errorf("got total %d, ids %v; want total %d, ids %v", resp.TotalCount, ids, tc.total, tc.ids)
+			}
+		})
+	}
+
+	for _, bad := range []SearchMoviesRequest{{SortBy: "rating"}, {SortOrder: "up"}, {YearFrom: 2010, YearTo: 2000}, {Page: -1}} {
+		if _, err := handleSearchMovies(bad); err == nil {
+			t.Errorf("expected a fault for %+v", bad)
+		}
+	}
+}
+
+// securityHeaderXML renders a Header with a UsernameToken; nonce and created are optional.
+func securityHeaderXML(user, passwordType, password, nonce, created string) string {
+	token := `<wsse:Username>` + user + `</wsse:Username><wsse:Password Type="` + passwordType + `">` + password + `</wsse:Password>`
+	if nonce != "" {
+		token += `<wsse:Nonce EncodingType="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary">` + nonce + `</wsse:Nonce>`
+	}
+	if created != "" {
+		token += `<wsu:Created>` + created + `</wsu:Created>`
+	}
+	return `<env:Header><wsse:Security env:mustUnderstand="1" xmlns:wsse="` + wsseNS + `" xmlns:wsu="` + wsuNS + `">` +
+		`<wsse:UsernameToken>` + token + `</wsse:UsernameToken></wsse:Security></env:Header>`
+}
+
+func TestWSSecurityUsernameToken(t *testing.T) {
+	now := time.Now().UTC()
+	created := now.Format(time.RFC3339)
+	nonce := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	digest := func(n, c, password string) string { return passwordDigest([]byte(n), c, password) }
+	deleteMissing := `<mov:DeleteMovieRequest><ID>404</ID></mov:DeleteMovieRequest>`
+
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+	}{
+		{"no token", "", false},
+		{"password text", securityHeaderXML("editor", passwordTextType, "editor-secret", "", ""), true},
+		{"wrong password", securityHeaderXML("editor", passwordTextType, "guess", "", ""), false},
+		{"unknown user", securityHeaderXML("mallory", passwordTextType, "editor-secret", "", ""), false},
+		{"password digest", securityHeaderXML("editor", passwordDigestType, digest("n-1", created, "editor-secret"), nonce("n-1"), created), true},
+		{"replayed digest", securityHeaderXML("editor", passwordDigestType, digest("n-1", created, "editor-secret"), nonce("n-1"), created), false},
+		{"digest without nonce", securityHeaderXML("editor", passwordDigestType, digest("", created, "editor-secret"), "", created), false},
+		{"wrong digest", securityHeaderXML("editor", passwordDigestType, digest("n-2", created, "guess"), nonce("n-2"), created), false},
+		{"stale created", securityHeaderXML("editor", passwordDigestType, digest("n-3", "2001-01-01T00:00:00Z", "editor-secret"), nonce("n-3"), "2001-01-01T00:00:00Z"), false},
+		{"replayed text nonce", securityHeaderXML("editor", passwordTextType, "editor-secret", nonce("n-1"), created), false},
+		{"text with nonce and created", securityHeaderXML("editor", passwordTextType, "editor-secret", nonce("n-4"), created), true},
+		{"text nonce without created", securityHeaderXML("editor", passwordTextType, "editor-secret", nonce("n-5"), ""), false},
+		{"text created without nonce", securityHeaderXML("editor", passwordTextType, "editor-secret", "", created), false},
+		{"stale text created", securityHeaderXML("editor", passwordTextType, "editor-secret", nonce("n-6"), "2001-01-01T00:00:00Z"), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, env := postSoap(t, envelope(soap11EnvelopeNS, tc.header, deleteMissing), nil)
+			f := env.Body.Fault
+			if f == nil {
+				t.Fatalf("expected a fault:\n%s", rec.Body.String())
+			}
+			// authenticated requests get as far as the MovieNotFoundFault
+			if authenticated := f.FaultCode == "soapenv:Client"; authenticated != tc.ok {
+				t.Errorf("faultcode = %q, authenticated = %v, want %v", f.FaultCode, authenticated, tc.ok)
+			}
+			if !tc.ok && (f.FaultCode != "wsse:FailedAuthentication" || !strings.Contains(rec.Body.String(), `xmlns:wsse="`+wsseNS+`"`)) {
+				t.Errorf("expected a wsse:FailedAuthentication fault:\n%s", rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("bearer token", func(t *testing.T) {
+		for _, tc := range []struct {
+			name  string
+			roles []string
+			ok    bool
+		}{
+			{"editor", []string{auth.RoleEditor}, true},
+			{"viewer", nil, false},
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/movies/soap", strings.NewReader(envelope(soap11EnvelopeNS, "", deleteMissing)))
+			req = req.WithContext(auth.NewContext(req.Context(), &auth.Claims{Subject: "alice", Roles: tc.roles}))
+			rec := httptest.NewRecorder()
+			soapHandler(rec, req)
+			// editors get as far as the MovieNotFoundFault
+			if authorized := strings.Contains(rec.Body.String(), "MovieNotFoundFault"); authorized != tc.ok {
+				t.Errorf("%s: authorized = %v, want %v:\n%s", tc.name, authorized, tc.ok, rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("read operations stay public", func(t *testing.T) {
+		if _, env := postSoap(t, envelope(soap11EnvelopeNS, "", `<mov:ListMoviesRequest/>`), nil); env.Body.Fault != nil {
+			t.Errorf("ListMovies faulted without credentials: %+v", env.Body.Fault)
+		}
+	})
+
+	t.Run("SOAP 1.2 subcode", func(t *testing.T) {
+		rec, _ := postSoap(t, envelope(soap12EnvelopeNS, "", deleteMissing), nil)
+		body := rec.Body.String()
+		if rec.Code != http.StatusBadRequest || !strings.Contains(body, "<soapenv:Value>soapenv:Sender</soapenv:Value>") ||
+			!strings.Contains(body, "<soapenv:Value>wsse:FailedAuthentication</soapenv:Value>") {
+			t.Errorf("expected Sender with a wsse:FailedAuthentication subcode, got %d:\n%s", rec.Code, body)
+		}
+	})
+}
+
+// TestClientFaultStatus pins the HTTP status of client faults: 500 with SOAP
+// 1.1, as its HTTP binding requires for every fault, and 400 with SOAP 1.2.
+// Either way the reply is a typed Client/Sender fault, not a Server fault.
+func TestClientFaultStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name, body, detail string
+	}{
+		{"not found", `<mov:GetMovieDetailsRequest><ID>99</ID></mov:GetMovieDetailsRequest>`, "<mov:MovieNotFoundFault>"},
+		{"validation", `<mov:GetMovieDetailsRequest><ID>abc</ID></mov:GetMovieDetailsRequest>`, ""},
+	} {
+		rec, env := postSoap(t, envelope(soap11EnvelopeNS, "", tc.body), nil)
+		if f := env.Body.Fault; rec.Code != http.StatusInternalServerError || f == nil || f.FaultCode != "soapenv:Client" {
+			t.Errorf("SOAP 1.1 %s: status %d, fault %+v; want 500 with soapenv:Client", tc.name, rec.Code, f)
+		} else if tc.detail != "" && (f.Detail11 == nil || !strings.Contains(f.Detail11.Inner, tc.detail)) {
+			t.Errorf("SOAP 1.1 %s: detail %+v, want %s", tc.name, f.Detail11, tc.detail)
+		}
+
+		rec, env = postSoap(t, envelope(soap12EnvelopeNS, "", tc.body), map[string]string{"Content-Type": "application/soap+xml"})
+		if f := env.Body.Fault; rec.Code != http.StatusBadRequest || f == nil || f.Code.Value != "soapenv:Sender" {
+			t.Errorf("SOAP 1.2 %s: status %d, fault %+v; want 400 with soapenv:Sender", tc.name, rec.Code, f)
+		} else if tc.detail != "" && (f.Detail12 == nil || !strings.Contains(f.Detail12.Inner, tc.detail)) {
+			t.Errorf("SOAP 1.2 %s: detail %+v, want %s", tc.name, f.Detail12, tc.detail)
+		}
+	}
+}
+
+func TestWSDLMovieView(t *testing.T) {
+	wsdl := generateWSDL("http://localhost:8083/api/movies/soap")
+	start := strings.Index(wsdl, `<xsd:complexType name="MovieView">`)
+	if start < 0 {
+		t.Fatalf("no MovieView type in the WSDL:\n%s", wsdl)
+	}
+	view := wsdl[start : start+strings.Index(wsdl[start:], "\n      </xsd:complexType>")]
+	// The stored Movie fields are inlined before the derived ones
+	if !regexp.MustCompile(`(?s)name="ID".*name="WatchURL".*name="AverageRating" type="xsd:double" minOccurs="0".*name="ReviewCount"`).MatchString(view) {
+		t.Errorf("MovieView does not list the movie and rating fields:\n%s", view)
+	}
+}
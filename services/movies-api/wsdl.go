@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// --- WSDL 1.1 Generation ---
+//
+// The WSDL document is generated from soapOperations and the Go request and
+// response types (via their xml struct tags), so it cannot drift from what
+// soapHandler actually accepts. Operations use the document/literal wrapped
+// style: top-level elements live in the service namespace, child elements
+// are unqualified.
+
+// xsdBuiltins maps Go kinds to XML Schema built-in types.
+var xsdBuiltins = map[reflect.Kind]string{
+	reflect.String:  "xsd:string",
+	reflect.Bool:    "xsd:boolean",
+	reflect.Int:     "xsd:int",
+	reflect.Int32:   "xsd:int",
+	reflect.Int64:   "xsd:long",
+	reflect.Float32: "xsd:float",
+	reflect.Float64: "xsd:double",
+}
+
+// wsdlBindings lists the SOAP bindings (one per supported version) and their
+// WSDL extension prefixes.
+var wsdlBindings = []struct {
+	Name, Port, Prefix string
+}{
+	{Name: "MovieServiceSoapBinding", Port: "MovieServicePort", Prefix: "soap"},
+	{Name: "MovieServiceSoap12Binding", Port: "MovieServiceSoap12Port", Prefix: "soap12"},
+}
+
+// schemaWriter accumulates the named complex types referenced by the operations.
+type schemaWriter struct {
+	types []string // rendered complexType definitions, in discovery order
+	seen  map[reflect.Type]bool
+}
+
+// elementName strips the literal "mov:" prefix used by the response structs.
+func elementName(tag string) string {
+	if i := strings.LastIndex(tag, " "); i >= 0 {
+		tag = tag[i+1:]
+	}
+	if i := strings.Index(tag, ":"); i >= 0 {
+		tag = tag[i+1:]
+	}
+	return tag
+}
+
+// xsdType returns the schema type for t, registering a named complexType for structs.
+func (sw *schemaWriter) xsdType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "xsd:dateTime" // marshalled as RFC 3339
+	}
+	if t.Kind() == reflect.Struct {
+		if !sw.seen[t] {
+			sw.seen[t] = true
+			def := fmt.Sprintf("      <xsd:complexType name=%q>\n%s      </xsd:complexType>\n", t.Name(), sw.sequence(t, "        "))
+			sw.types = append(sw.types, def)
+		}
+		return "tns:" + t.Name()
+	}
+	if name, ok := xsdBuiltins[t.Kind()]; ok {
+		return name
+	}
+	return "xsd:anyType"
+}
+
+// sequence renders the xsd:sequence (and attributes) for the fields of struct t.
+func (sw *schemaWriter) sequence(t reflect.Type, indent string) string {
+	var elems, attrs strings.Builder
+	sw.fields(t, indent, &elems, &attrs)
+	return fmt.Sprintf("%s<xsd:sequence>\n%s%s</xsd:sequence>\n%s", indent, elems.String(), indent, attrs.String())
+}
+
+// fields renders the elements and attributes of the fields of struct t. Like
+// encoding/xml, it flattens the fields of embedded structs into t.
+func (sw *schemaWriter) fields(t reflect.Type, indent string, elems, attrs *strings.Builder) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("xml") == "" {
+			sw.fields(f.Type, indent, elems, attrs)
+			continue
+		}
+		if !f.IsExported() || f.Name == "XMLName" {
+			continue
+		}
+		tag := f.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name, opts := parts[0], parts[1:]
+		if name == "" {
+			name = f.Name
+		}
+		optional := false
+		isAttr := false
+		for _, o := range opts {
+			switch o {
+			case "omitempty":
+				optional = true
+			case "attr":
+				isAttr = true
+			case "chardata", "innerxml", "comment", "any":
+				name = ""
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+			optional = true
+		}
+		if isAttr {
+			use := "required"
+			if optional {
+				use = "optional"
+			}
+			fmt.Fprintf(attrs, "%s<xsd:attribute name=%q type=%q use=%q/>\n", indent, elementName(name), sw.xsdType(ft), use)
+			continue
+		}
+
+		repeated := ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8
+		if repeated {
+			ft = ft.Elem()
+		}
+		occurs := ""
+		if optional || repeated {
+			occurs += ` minOccurs="0"`
+		}
+		if repeated {
+			occurs += ` maxOccurs="unbounded"`
+		}
+
+		// "Outer>Inner" wraps the repeated elements in a container element,
+		// which encoding/xml omits when there are no elements to wrap
+		if outer, inner, nested := strings.Cut(name, ">"); nested {
+			outerOccurs := ""
+			if optional || repeated {
+				outerOccurs = ` minOccurs="0"`
+			}
+			fmt.Fprintf(elems, "%s  <xsd:element name=%q%s>\n%s    <xsd:complexType>\n%s      <xsd:sequence>\n", indent, elementName(outer), outerOccurs, indent, indent)
+			fmt.Fprintf(elems, "%s        <xsd:element name=%q type=%q%s/>\n", indent, elementName(inner), sw.xsdType(ft), occurs)
+			fmt.Fprintf(elems, "%s      </xsd:sequence>\n%s    </xsd:complexType>\n%s  </xsd:element>\n", indent, indent, indent)
+			continue
+		}
+		fmt.Fprintf(elems, "%s  <xsd:element name=%q type=%q%s/>\n", indent, elementName(name), sw.xsdType(ft), occurs)
+	}
+}
+
+// topLevelElement renders a global element with an anonymous complex type.
+func (sw *schemaWriter) topLevelElement(t reflect.Type) string {
+	field, _ := t.FieldByName("XMLName")
+	name := elementName(field.Tag.Get("xml"))
+	if name == "" {
+		name = t.Name()
+	}
+	return fmt.Sprintf("      <xsd:element name=%q>\n        <xsd:complexType>\n%s        </xsd:complexType>\n      </xsd:element>\n",
+		name, sw.sequence(t, "          "))
+}
+
+// generateWSDL renders the WSDL 1.1 document for the service at location.
+func generateWSDL(location string) string {
+	sw := &schemaWriter{seen: make(map[reflect.Type]bool)}
+	var elements strings.Builder
+	var faults []reflect.Type // distinct fault detail types, in declaration order
+	for _, op := range soapOperations {
+		elements.WriteString(sw.topLevelElement(op.RequestType))
+		elements.WriteString(sw.topLevelElement(op.ResponseType))
+		for _, f := range op.Faults {
+			if !sw.seen[f] {
+				sw.seen[f] = true
+				faults = append(faults, f)
+				elements.WriteString(sw.topLevelElement(f))
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<wsdl:definitions name="MovieService" targetNamespace=%q
+    xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/"
+    xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/"
+    xmlns:soap12="http://schemas.xmlsoap.org/wsdl/soap12/"
+    xmlns:xsd="http://www.w3.org/2001/XMLSchema"
+    xmlns:tns=%q>
+  <wsdl:types>
+    <xsd:schema targetNamespace=%q elementFormDefault="unqualified">
+`, movieServiceNS, movieServiceNS, movieServiceNS)
+	b.WriteString(elements.String())
+	for _, def := range sw.types {
+		b.WriteString(def)
+	}
+	b.WriteString("    </xsd:schema>\n  </wsdl:types>\n")
+
+	for _, op := range soapOperations {
+		fmt.Fprintf(&b, "  <wsdl:message name=\"%sRequest\">\n    <wsdl:part name=\"parameters\" element=\"tns:%sRequest\"/>\n  </wsdl:message>\n", op.Name, op.Name)
+		fmt.Fprintf(&b, "  <wsdl:message name=\"%sResponse\">\n    <wsdl:part name=\"parameters\" element=\"tns:%sResponse\"/>\n  </wsdl:message>\n", op.Name, op.Name)
+	}
+	for _, f := range faults {
+		fmt.Fprintf(&b, "  <wsdl:message name=\"%s\">\n    <wsdl:part name=\"fault\" element=\"tns:%s\"/>\n  </wsdl:message>\n", f.Name(), f.Name())
+	}
+
+	b.WriteString("  <wsdl:portType name=\"MovieServicePortType\">\n")
+	for _, op := range soapOperations {
+		fmt.Fprintf(&b, "    <wsdl:operation name=%q>\n      <wsdl:input message=\"tns:%sRequest\"/>\n      <wsdl:output message=\"tns:%sResponse\"/>\n", op.Name, op.Name, op.Name)
+		for _, f := range op.Faults {
+			fmt.Fprintf(&b, "      <wsdl:fault name=\"%s\" message=\"tns:%s\"/>\n", f.Name(), f.Name())
+		}
+		b.WriteString("    </wsdl:operation>\n")
+	}
+	b.WriteString("  </wsdl:portType>\n")
+
+	for _, bd := range wsdlBindings {
+		fmt.Fprintf(&b, "  <wsdl:binding name=\"%s\" type=\"tns:MovieServicePortType\">\n", bd.Name)
+		fmt.Fprintf(&b, "    <%s:binding style=\"document\" transport=\"http://schemas.xmlsoap.org/soap/http\"/>\n", bd.Prefix)
+		for _, op := range soapOperations {
+			fmt.Fprintf(&b, "    <wsdl:operation name=%q>\n      <%s:operation soapAction=%q style=\"document\"/>\n", op.Name, bd.Prefix, op.Action)
+			fmt.Fprintf(&b, "      <wsdl:input>\n        <%s:body use=\"literal\"/>\n      </wsdl:input>\n", bd.Prefix)
+			fmt.Fprintf(&b, "      <wsdl:output>\n        <%s:body use=\"literal\"/>\n      </wsdl:output>\n", bd.Prefix)
+			for _, f := range op.Faults {
+				fmt.Fprintf(&b, "      <wsdl:fault name=\"%s\">\n        <%s:fault name=\"%s\" use=\"literal\"/>\n      </wsdl:fault>\n", f.Name(), bd.Prefix, f.Name())
+			}
+			b.WriteString("    </wsdl:operation>\n")
+		}
+		b.WriteString("  </wsdl:binding>\n")
+	}
+
+	b.WriteString("  <wsdl:service name=\"MovieService\">\n")
+	for _, bd := range wsdlBindings {
+		fmt.Fprintf(&b, "    <wsdl:port name=\"%s\" binding=\"tns:%s\">\n      <%s:address location=%q/>\n    </wsdl:port>\n", bd.Port, bd.Name, bd.Prefix, location)
+	}
+	b.WriteString("  </wsdl:service>\n</wsdl:definitions>\n")
+	return b.String()
+}
+
+// serviceLocation returns the public URL of the endpoint that served r,
+// honouring the X-Forwarded-* headers set by the gateway.
+func serviceLocation(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return scheme + "://" + host + r.URL.Path
+}
+
+// wsdlHandler serves GET <endpoint>?wsdl.
+func wsdlHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	fmt.Fprint(w, generateWSDL(serviceLocation(r)))
+}
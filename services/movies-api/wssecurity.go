@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"gopkg.in/yaml.v3"
+)
+
+// --- WS-Security UsernameToken Authentication ---
+//
+// Mutating operations require a bearer token with the editor role or a
+// wsse:Security header with a UsernameToken (WS-Security UsernameToken
+// Profile 1.0). Both PasswordText and PasswordDigest are accepted. Nonce and
+// Created must be sent together: the timestamp bounds the token's lifetime and
+// the nonce is remembered for that window, so a captured token cannot be
+// replayed. A PasswordText token may omit both; it carries the password itself
+// and is only as safe as the transport (use TLS).
+
+const (
+	wsseNS = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"
+	wsuNS  = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+
+	passwordTextType   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordText"
+	passwordDigestType = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest"
+)
+
+// tokenFreshness bounds the age of a Created timestamp (and the time nonces are
+// remembered); tokenClockSkew tolerates clients whose clock runs ahead.
+const (
+	tokenFreshness = 5 * time.Minute
+	tokenClockSkew = 30 * time.Second
+)
+
+// securityHeader is the wsse:Security header block.
+type securityHeader struct {
+	XMLName       xml.Name       `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Security"`
+	UsernameToken *usernameToken `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd UsernameToken"`
+}
+
+type usernameToken struct {
+	Username string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Username"`
+	Password struct {
+		Type  string `xml:"Type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Password"`
+	Nonce   string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Nonce"`
+	Created string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Created"`
+}
+
+// failedAuthentication returns the wsse:FailedAuthentication fault. The
+// reason sent to the client is deliberately generic; detail is only logged.
+func failedAuthentication(detail string, args ...interface{}) error {
+	log.Printf("Authentication failed: %s", fmt.Sprintf(detail, args...))
+	return &soapFaultError{
+		Code:    "Client",
+		Subcode: xml.Name{Space: wsseNS, Local: "FailedAuthentication"},
+		Reason:  "The security token could not be authenticated or authorized",
+	}
+}
+
+// --- Credentials ---
+
+// credentialsFile is the YAML document listing the users allowed to write.
+type credentialsFile struct {
+	Users []struct {
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"users"`
+}
+
+// credentialStore maps usernames to passwords. Passwords are kept in clear
+// text because PasswordDigest verification needs the shared secret.
+type credentialStore struct {
+	passwords map[string]string
+}
+
+// Users allowed to call mutating operations, loaded from CREDENTIALS_FILE
+var credentials = &credentialStore{passwords: map[string]string{}}
+
+// loadCredentials reads a credentials file. A missing file yields an empty
+// store, which rejects every write.
+func loadCredentials(path string) (*credentialStore, error) {
+	store := &credentialStore{passwords: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("Credentials file %s not found; write operations are disabled", path)
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file credentialsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i, u := range file.Users {
+		if u.Username == "" || u.Password == "" {
+			return nil, fmt.Errorf("%s: user #%d needs a username and a password", path, i+1)
+		}
+		if _, dup := store.passwords[u.Username]; dup {
+			return nil, fmt.Errorf("%s: duplicate user %q", path, u.Username)
+		}
+		store.passwords[u.Username] = u.Password
+	}
+	log.Printf("Loaded %d SOAP users from %s", len(store.passwords), path)
+	return store, nil
+}
+
+// --- Replay Protection ---
+
+// nonceCache remembers nonces seen within the freshness window.
+type nonceCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // nonce -> expiry
+}
+
+var usedNonces = &nonceCache{seen: make(map[string]time.Time)}
+
+// use records nonce and reports whether it was unused.
+func (c *nonceCache) use(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for n, expiry := range c.seen {
+		if now.After(expiry) {
+			delete(c.seen, n)
+		}
+	}
+	if _, replayed := c.seen[nonce]; replayed {
+		return false
+	}
+	c.seen[nonce] = now.Add(tokenFreshness + tokenClockSkew)
+	return true
+}
+
+// --- Verification ---
+
+// authenticate verifies the UsernameToken of a request and returns the user name.
+func authenticate(sec *securityHeader, now time.Time) (string, error) {
+	if sec == nil || sec.UsernameToken == nil {
+		return "", failedAuthentication("no UsernameToken in the request")
+	}
+	token := sec.UsernameToken
+	username := strings.TrimSpace(token.Username)
+	password, known := credentials.passwords[username]
+
+	var nonce []byte
+	if token.Nonce != "" {
+		var err error
+		if nonce, err = base64.StdEncoding.DecodeString(strings.TrimSpace(token.Nonce)); err != nil {
+			return "", failedAuthentication("nonce for %q is not base64", username)
+		}
+	}
+	created := strings.TrimSpace(token.Created)
+	if (nonce == nil) != (created == "") {
+		// A nonce is only remembered for the freshness window, so without
+		// Created it could be replayed once the window has passed.
+		return "", failedAuthentication("token for %q needs both Nonce and Created, or neither", username)
+	}
+	if created != "" {
+		ts, err := time.Parse(time.RFC3339, created)
+		if err != nil {
+			return "", failedAuthentication("invalid Created timestamp %q", created)
+		}
+		if ts.After(now.Add(tokenClockSkew)) || now.Sub(ts) > tokenFreshness {
+			return "", failedAuthentication("token for %q created at %s is outside the freshness window", username, created)
+		}
+	}
+
+	var ok bool
+	switch strings.TrimSpace(token.Password.Type) {
+	case passwordTextType, "":
+		ok = known && subtle.ConstantTimeCompare([]byte(token.Password.Value), []byte(password)) == 1
+	case passwordDigestType:
+		if nonce == nil || created == "" {
+			return "", failedAuthentication("PasswordDigest for %q without Nonce and Created", username)
+		}
+		ok = known && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token.Password.Value)), []byte(passwordDigest(nonce, created, password))) == 1
+	default:
+		return "", failedAuthentication("unsupported password type %q", token.Password.Type)
+	}
+	if !ok {
+		return "", failedAuthentication("invalid credentials for %q", username)
+	}
+
+	// Only remember nonces of valid tokens, so garbage cannot fill the cache.
+	if nonce != nil && !usedNonces.use(username+"\x00"+string(nonce), now) {
+		return "", failedAuthentication("replayed nonce for %q", username)
+	}
+	return username, nil
+}
+
+// authorize returns the user allowed to call a secured operation. A request
+// authenticated with a bearer token needs role, unless it is empty; otherwise
+// the WS-Security UsernameToken is checked (users of the credentials file are
+// editors).
+func authorize(ctx context.Context, sec *securityHeader, role string, now time.Time) (string, error) {
+	if claims, ok := auth.FromContext(ctx); ok {
+		if role != "" && !claims.HasRole(role) {
+			return "", failedAuthentication("bearer token of %q lacks the %q role", claims.Subject, role)
+		}
+		return claims.Subject, nil
+	}
+	return authenticate(sec, now)
+}
+
+// passwordDigest computes Base64(SHA-1(nonce + created + password)).
+func passwordDigest(nonce []byte, created, password string) string {
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(created))
+	h.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
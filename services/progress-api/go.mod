@@ -0,0 +1,12 @@
+module github.com/mbenabdallah/progress-api
+
+go 1.24.2
+
+require github.com/mbenabdallah/shared v0.0.0
+
+require (
+	go.etcd.io/bbolt v1.4.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
+
+replace github.com/mbenabdallah/shared => ../shared
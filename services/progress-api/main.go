@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// --- Progress Model ---
+
+// Content kinds, matching the kinds of the catalog API
+const (
+	KindSeries = "series"
+	KindAnime  = "anime"
+	KindMovie  = "movie"
+)
+
+// completionThreshold is the share of a unit that counts as watched when the
+// client does not say whether it completed it.
+const completionThreshold = 0.9
+
+// Progress is a user's position in one episode (series, anime) or movie.
+type Progress struct {
+	ID              int       `json:"id"`
+	UserID          string    `json:"userId"`
+	Kind            string    `json:"kind"`
+	ContentID       int       `json:"contentId"`
+	EpisodeID       int       `json:"episodeId,omitempty"` // 0 for movies
+	PositionSeconds int       `json:"positionSeconds"`
+	DurationSeconds int       `json:"durationSeconds,omitempty"`
+	Completed       bool      `json:"completed"`
+	LastWatchedAt   time.Time `json:"lastWatchedAt"`
+}
+
+// progressUpdate is the body of PUT requests. Completed defaults to whether
+// the position reached completionThreshold of the duration.
+type progressUpdate struct {
+	PositionSeconds int   `json:"positionSeconds"`
+	DurationSeconds int   `json:"durationSeconds"`
+	Completed       *bool `json:"completed"`
+}
+
+// episodic reports whether content of kind is watched episode by episode.
+func episodic(kind string) bool {
+	return kind == KindSeries || kind == KindAnime
+}
+
+// parseKind validates a content kind path or query value.
+func parseKind(v string) (string, error) {
+	switch v {
+	case KindSeries, KindAnime, KindMovie:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown kind %q (want series, anime or movie)", v)
+}
+
+// --- Request Helpers ---
+
+// requestUser returns the user a request acts for: the subject of its bearer
+// token. Anonymous requests are rejected with 401.
+func requestUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := auth.RequestUser(w, r)
+	if !ok {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// pathIDs parses the {contentId} and, if present, {episodeId} path values.
+func pathIDs(r *http.Request) (contentID, episodeID int, err error) {
+	if contentID, err = strconv.Atoi(r.PathValue("contentId")); err != nil || contentID <= 0 {
+		return 0, 0, fmt.Errorf("invalid content ID")
+	}
+	if v := r.PathValue("episodeId"); v != "" {
+		if episodeID, err = strconv.Atoi(v); err != nil || episodeID <= 0 {
+			return 0, 0, fmt.Errorf("invalid episode ID")
+		}
+	}
+	return contentID, episodeID, nil
+}
+
+// writeJSON encodes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// --- Handler Functions ---
+
+// listProgressHandler handles GET /api/progress?kind=&contentId=
+func listProgressHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	var kind string
+	if v := q.Get("kind"); v != "" {
+		var err error
+		if kind, err = parseKind(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	contentID := 0
+	if v := q.Get("contentId"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || kind == "" {
+			http.Error(w, "contentId must be a positive integer and requires kind", http.StatusBadRequest)
+			return
+		}
+		contentID = n
+	}
+
+	records, err := progressRepo.ForUser(user, func(k progressKey) bool {
+		return (kind == "" || k.Kind == kind) && (contentID == 0 || k.ContentID == contentID)
+	})
+	if err != nil {
+		log.Printf("Error listing progress of %q: %v", user, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, records)
+	log.Printf("Handled GET /api/progress request (%d records)", len(records))
+}
+
+// contentProgressHandler handles GET, PUT and DELETE on
+// /api/progress/{kind}/{contentId} and /api/progress/{kind}/{contentId}/episodes/{episodeId}.
+// Movies are tracked on the content path, series and anime per episode; GET
+// and DELETE on the content path of episodic content cover all its episodes.
+func contentProgressHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	kind, err := parseKind(r.PathValue("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	contentID, episodeID, err := pathIDs(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if episodeID != 0 && !episodic(kind) {
+		http.Error(w, "Movies have no episodes", http.StatusBadRequest)
+		return
+	}
+	matches := func(k progressKey) bool {
+		return k.Kind == kind && k.ContentID == contentID && (episodeID == 0 || k.EpisodeID == episodeID)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		records, err := progressRepo.ForUser(user, matches)
+		if err != nil {
+			log.Printf("Error loading progress of %q: %v", user, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, records)
+		log.Printf("Handled GET %s request", r.URL.Path)
+
+	case http.MethodPut:
+		if episodic(kind) && episodeID == 0 {
+			http.Error(w, "Progress of series and anime is recorded per episode (/episodes/{episodeId})", http.StatusBadRequest)
+			return
+		}
+		var update progressUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+		if update.PositionSeconds < 0 || update.DurationSeconds < 0 {
+			http.Error(w, "positionSeconds and durationSeconds cannot be negative", http.StatusBadRequest)
+			return
+		}
+		if update.DurationSeconds > 0 && update.PositionSeconds > update.DurationSeconds {
+			http.Error(w, "positionSeconds cannot exceed durationSeconds", http.StatusBadRequest)
+			return
+		}
+		completed := update.DurationSeconds > 0 && float64(update.PositionSeconds) >= completionThreshold*float64(update.DurationSeconds)
+		if update.Completed != nil {
+			completed = *update.Completed
+		}
+
+		saved, created, err := progressRepo.Save(Progress{
+			UserID:          user,
+			Kind:            kind,
+			ContentID:       contentID,
+			EpisodeID:       episodeID,
+			PositionSeconds: update.PositionSeconds,
+			DurationSeconds: update.DurationSeconds,
+			Completed:       completed,
+			LastWatchedAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			log.Printf("Error saving progress of %q: %v", user, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, saved)
+		log.Printf("Handled PUT %s request", r.URL.Path)
+
+	case http.MethodDelete:
+		removed, err := progressRepo.Delete(user, matches)
+		if err != nil {
+			log.Printf("Error deleting progress of %q: %v", user, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if removed == 0 {
+			http.Error(w, "No progress recorded", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		log.Printf("Handled DELETE %s request (%d records)", r.URL.Path, removed)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- Main Function ---
+
+func main() {
+	backend, err := storage.Open(storage.ConfigFromEnv("data/progress.db"))
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer backend.Close()
+	progressStore, err := storage.New[Progress](backend, "progress")
+	if err != nil {
+		log.Fatalf("Failed to open progress store: %v", err)
+	}
+	if progressRepo, err = newProgressRepository(progressStore); err != nil {
+		log.Fatalf("Failed to load progress records: %v", err)
+	}
+
+	// Progress belongs to the user named by the bearer token
+	verifier, err := auth.NewVerifier(auth.ConfigFromEnv("config/jwks.json"))
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	http.HandleFunc("/api/progress", listProgressHandler)
+	http.HandleFunc("/api/progress/continue-watching", continueWatchingHandler)
+	http.HandleFunc("/api/progress/summary", summaryHandler)
+	http.HandleFunc("/api/progress/{kind}/{contentId}", contentProgressHandler)
+	http.HandleFunc("/api/progress/{kind}/{contentId}/episodes/{episodeId}", contentProgressHandler)
+
+	// Basic health check endpoint
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "Progress API is running. Send a bearer token with requests to /api/progress")
+	})
+
+	port := "8085"
+	fmt.Printf("Progress API starting on port %s...\n", port)
+	log.Printf("Progress API starting on port %s...", port)
+	log.Fatal(http.ListenAndServe(":"+port, verifier.Authenticate(http.DefaultServeMux)))
+}
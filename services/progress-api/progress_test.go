@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+var testKey = auth.SigningKey{ID: "test", Algorithm: auth.HS256, Secret: []byte("0123456789abcdef0123456789abcdef")}
+
+// newTestServer serves the progress routes over an in-memory store, accepting
+// tokens signed with testKey.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	jwks, _ := json.Marshal(auth.JWKS{Keys: []auth.JWK{{
+		Kty: "oct", Kid: testKey.ID, K: base64.RawURLEncoding.EncodeToString(testKey.Secret),
+	}}})
+	if err := os.WriteFile(jwksFile, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.New[Progress](storage.NewMemoryBackend(), "progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progressRepo, err = newProgressRepository(store); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/progress", listProgressHandler)
+	mux.HandleFunc("/api/progress/continue-watching", continueWatchingHandler)
+	mux.HandleFunc("/api/progress/summary", summaryHandler)
+	mux.HandleFunc("/api/progress/{kind}/{contentId}", contentProgressHandler)
+	mux.HandleFunc("/api/progress/{kind}/{contentId}/episodes/{episodeId}", contentProgressHandler)
+	srv := httptest.NewServer(verifier.Authenticate(mux))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, user, path, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "" {
+		token, err := testKey.Sign(auth.Claims{Subject: user, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestSaveProgress(t *testing.T) {
+	srv := newTestServer(t)
+
+	var p Progress
+	if code := do(t, srv, "PUT", "alice", "/api/progress/series/1/episodes/2", `{"positionSeconds":600,"durationSeconds":3000}`, &p); code != http.StatusCreated {
+		t.Fatalf("first PUT: status %d, want 201", code)
+	}
+	if p.Completed || p.UserID != "alice" || p.EpisodeID != 2 {
+		t.Errorf("first PUT: got %+v", p)
+	}
+	if code := do(t, srv, "PUT", "alice", "/api/progress/series/1/episodes/2", `{"positionSeconds":2800,"durationSeconds":3000}`, &p); code != http.StatusOK {
+		t.Fatalf("second PUT: status %d, want 200", code)
+	}
+	if !p.Completed {
+		t.Errorf("position past %.0f%% of the duration should complete the episode", completionThreshold*100)
+	}
+
+	for _, c := range []struct {
+		method, user, path, body string
+		want                     int
+	}{
+		{"PUT", "", "/api/progress/movie/1", `{"positionSeconds":1}`, http.StatusUnauthorized},
+		{"PUT", "alice", "/api/progress/series/1", `{"positionSeconds":1}`, http.StatusBadRequest},
+		{"PUT", "alice", "/api/progress/movie/1/episodes/1", `{"positionSeconds":1}`, http.StatusBadRequest},
+		{"PUT", "alice", "/api/progress/movie/1", `{"positionSeconds":90,"durationSeconds":60}`, http.StatusBadRequest},
+		{"PUT", "alice", "/api/progress/podcast/1", `{"positionSeconds":1}`, http.StatusBadRequest},
+		{"DELETE", "bob", "/api/progress/series/1", "", http.StatusNotFound},
+		{"DELETE", "alice", "/api/progress/series/1", "", http.StatusNoContent},
+	} {
+		if code := do(t, srv, c.method, c.user, c.path, c.body, nil); code != c.want {
+			t.Errorf("%s %s as %q: status %d, want %d", c.method, c.path, c.user, code, c.want)
+		}
+	}
+}
+
+func TestContinueWatchingAndSummary(t *testing.T) {
+	srv := newTestServer(t)
+	for _, path := range []string{
+		"/api/progress/series/1/episodes/1",
+		"/api/progress/series/1/episodes/2",
+		"/api/progress/movie/4", // finished, left out of continue watching
+		"/api/progress/anime/3/episodes/1",
+	} {
+		do(t, srv, "PUT", "alice", path, `{"positionSeconds":100,"durationSeconds":100}`, nil)
+	}
+	do(t, srv, "PUT", "alice", "/api/progress/series/1/episodes/3", `{"positionSeconds":10,"durationSeconds":100}`, nil)
+	do(t, srv, "PUT", "bob", "/api/progress/series/1/episodes/4", `{"positionSeconds":100,"durationSeconds":100}`, nil)
+
+	var entries []contentProgress
+	if code := do(t, srv, "GET", "alice", "/api/progress/continue-watching", "", &entries); code != http.StatusOK {
+		t.Fatalf("continue watching: status %d", code)
+	}
+	var refs []string
+	for _, e := range entries {
+		refs = append(refs, e.Ref)
+	}
+	if got, want := strings.Join(refs, " "), "series:1 anime:3"; got != want {
+		t.Errorf("continue watching = %q, want %q", got, want)
+	}
+	if entries[0].WatchedEpisodes != 2 || entries[0].Last.EpisodeID != 3 {
+		t.Errorf("series:1 entry = %+v, want 2 watched episodes and episode 3 last", entries[0])
+	}
+
+	var summary []contentProgress
+	if code := do(t, srv, "GET", "alice", "/api/progress/summary?kind=series&ids=2,1", "", &summary); code != http.StatusOK {
+		t.Fatalf("summary: status %d", code)
+	}
+	if len(summary) != 2 || summary[0].ContentID != 2 || summary[0].WatchedEpisodes != 0 || summary[1].WatchedEpisodes != 2 {
+		t.Errorf("summary = %+v, want series 2 unwatched then series 1 with 2 episodes", summary)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// progressKey identifies the progress of one user in one episode or movie.
+type progressKey struct {
+	UserID    string
+	Kind      string
+	ContentID int
+	EpisodeID int // 0 for movies
+}
+
+func (p Progress) key() progressKey {
+	return progressKey{UserID: p.UserID, Kind: p.Kind, ContentID: p.ContentID, EpisodeID: p.EpisodeID}
+}
+
+// progressRepository is the concurrency-safe access point for progress
+// records. The store is keyed by integer IDs, so an in-memory index maps each
+// progressKey to the ID of its record; it is rebuilt from the store at startup
+// and kept in sync under the write lock.
+type progressRepository struct {
+	mu    sync.RWMutex
+	store storage.Store[Progress]
+	index map[progressKey]int
+}
+
+// Repository used by the handlers, set up in main
+var progressRepo *progressRepository
+
+func newProgressRepository(store storage.Store[Progress]) (*progressRepository, error) {
+	records, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	r := &progressRepository{store: store, index: make(map[progressKey]int, len(records))}
+	for _, p := range records {
+		r.index[p.key()] = p.ID
+	}
+	return r, nil
+}
+
+// Save inserts or replaces the record for p's key and returns the stored
+// record and whether it was created.
+func (r *progressRepository) Save(p Progress) (Progress, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, exists := r.index[p.key()]
+	if !exists {
+		var err error
+		if id, err = r.store.NextID(); err != nil {
+			return Progress{}, false, err
+		}
+	}
+	p.ID = id
+	if err := r.store.Put(id, p); err != nil {
+		return Progress{}, false, err
+	}
+	r.index[p.key()] = id
+	return p, !exists, nil
+}
+
+// Delete removes the records of a user matching keep and returns how many
+// were removed.
+func (r *progressRepository) Delete(userID string, keep func(progressKey) bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := 0
+	for key, id := range r.index {
+		if key.UserID != userID || !keep(key) {
+			continue
+		}
+		if _, err := r.store.Delete(id); err != nil {
+			return removed, err
+		}
+		delete(r.index, key)
+		removed++
+	}
+	return removed, nil
+}
+
+// ForUser returns the records of a user matching keep (nil keeps all),
+// most recently watched first.
+func (r *progressRepository) ForUser(userID string, keep func(progressKey) bool) ([]Progress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	records := []Progress{}
+	for key, id := range r.index {
+		if key.UserID != userID || (keep != nil && !keep(key)) {
+			continue
+		}
+		p, ok, err := r.store.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			records = append(records, p)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].LastWatchedAt.Equal(records[j].LastWatchedAt) {
+			return records[i].LastWatchedAt.After(records[j].LastWatchedAt)
+		}
+		return records[i].ID > records[j].ID
+	})
+	return records, nil
+}
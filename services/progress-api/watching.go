@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Continue Watching and Summaries ---
+
+const (
+	defaultContinueLimit = 20
+	maxContinueLimit     = 100
+)
+
+// contentProgress aggregates a user's records for one series, anime or movie.
+type contentProgress struct {
+	Kind            string    `json:"kind"`
+	ContentID       int       `json:"contentId"`
+	Ref             string    `json:"ref"` // "<kind>:<id>", as in the catalog API
+	WatchedEpisodes int       `json:"watchedEpisodes"`
+	LastWatchedAt   time.Time `json:"lastWatchedAt"`
+	Last            Progress  `json:"last"` // most recently watched episode or movie
+}
+
+// summarize groups records (most recent first) by content, keeping the order
+// of each content's most recent record.
+func summarize(records []Progress) []contentProgress {
+	type contentKey struct {
+		kind string
+		id   int
+	}
+	byContent := map[contentKey]*contentProgress{}
+	var out []*contentProgress
+	for _, p := range records {
+		k := contentKey{p.Kind, p.ContentID}
+		c := byContent[k]
+		if c == nil {
+			c = &contentProgress{
+				Kind:          p.Kind,
+				ContentID:     p.ContentID,
+				Ref:           fmt.Sprintf("%s:%d", p.Kind, p.ContentID),
+				LastWatchedAt: p.LastWatchedAt,
+				Last:          p,
+			}
+			byContent[k] = c
+			out = append(out, c)
+		}
+		if p.Completed && episodic(p.Kind) {
+			c.WatchedEpisodes++
+		}
+	}
+	summaries := make([]contentProgress, len(out))
+	for i, c := range out {
+		summaries[i] = *c
+	}
+	return summaries
+}
+
+// continueWatchingHandler handles GET /api/progress/continue-watching?kind=&limit=
+// It lists the content a user has started, most recently watched first.
+// Finished movies are left out; for series and anime a completed last
+// episode means the user continues with the next one.
+func continueWatchingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	var kind string
+	if v := q.Get("kind"); v != "" {
+		var err error
+		if kind, err = parseKind(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultContinueLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxContinueLimit)
+	}
+
+	records, err := progressRepo.ForUser(user, func(k progressKey) bool { return kind == "" || k.Kind == kind })
+	if err != nil {
+		log.Printf("Error listing progress of %q: %v", user, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	entries := []contentProgress{}
+	for _, c := range summarize(records) {
+		if c.Kind == KindMovie && c.Last.Completed {
+			continue
+		}
+		entries = append(entries, c)
+		if len(entries) == limit {
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, entries)
+	log.Printf("Handled GET /api/progress/continue-watching request (%d entries)", len(entries))
+}
+
+// summaryHandler handles GET /api/progress/summary?kind=&ids=
+// It returns one entry per requested content ID, including content the user
+// has not started, so services can derive per-user counters such as
+// watchedEpisodes. Without ids it covers all content of kind with progress.
+func summaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	kind, err := parseKind(q.Get("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var ids []int
+	wanted := map[int]bool{}
+	if v := q.Get("ids"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || id <= 0 {
+				http.Error(w, "ids must be a comma-separated list of positive integers", http.StatusBadRequest)
+				return
+			}
+			if !wanted[id] {
+				wanted[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	records, err := progressRepo.ForUser(user, func(k progressKey) bool {
+		return k.Kind == kind && (len(wanted) == 0 || wanted[k.ContentID])
+	})
+	if err != nil {
+		log.Printf("Error listing progress of %q: %v", user, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	summaries := summarize(records)
+	if len(ids) > 0 {
+		found := map[int]contentProgress{}
+		for _, s := range summaries {
+			found[s.ContentID] = s
+		}
+		summaries = summaries[:0]
+		for _, id := range ids {
+			s, ok := found[id]
+			if !ok {
+				s = contentProgress{Kind: kind, ContentID: id, Ref: fmt.Sprintf("%s:%d", kind, id)}
+			}
+			summaries = append(summaries, s)
+		}
+	} else {
+		sort.Slice(summaries, func(i, j int) bool { return summaries[i].ContentID < summaries[j].ContentID })
+	}
+	if summaries == nil {
+		summaries = []contentProgress{}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+	log.Printf("Handled GET /api/progress/summary request (%d entries)", len(summaries))
+}
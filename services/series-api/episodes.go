@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// --- Episode Sub-resource Handlers ---
+
+// episodeIDFromRequest parses the {episodeId} path variable of an episode route.
+func episodeIDFromRequest(r *http.Request) (int, error) {
+	idStr, ok := mux.Vars(r)["episodeId"]
+	if !ok {
+		return 0, fmt.Errorf("missing episode ID")
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid episode ID format")
+	}
+	return id, nil
+}
+
+// findEpisode returns the index of the episode with the given ID, or -1.
+func findEpisode(episodes []Episode, episodeID int) int {
+	for i, ep := range episodes {
+		if ep.ID == episodeID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Highest episode ID assigned so far, per series ID. Episode IDs are never
+// reused, so progress recorded against a deleted episode cannot attach to a
+// later one.
+var episodeIDStore storage.Store[int]
+
+// recordEpisodeIDs raises the highest episode ID recorded for the series to
+// cover episodes, which may come from fixtures or a PUT of the series, and
+// returns it. It is called before episodes are removed so their IDs stay
+// taken. The caller holds the write lock of storeMutex.
+func recordEpisodeIDs(seriesID int, episodes []Episode) (int, error) {
+	last, _, err := episodeIDStore.Get(seriesID)
+	if err != nil {
+		return 0, err
+	}
+	highest := last
+	for _, ep := range episodes {
+		highest = max(highest, ep.ID)
+	}
+	if highest > last {
+		err = episodeIDStore.Put(seriesID, highest)
+	}
+	return highest, err
+}
+
+// purgeDroppedEpisodeReviews deletes the reviews of the episodes in before
+// that are missing from after. The episodes are already gone, so errors are
+// only logged. The caller holds the write lock of storeMutex.
+func purgeDroppedEpisodeReviews(seriesID int, before, after []Episode) {
+	for _, ep := range before {
+		if findEpisode(after, ep.ID) >= 0 {
+			continue
+		}
+		if _, err := reviewRepo.DeleteEpisode(seriesID, ep.ID); err != nil {
+			log.Printf("Error deleting reviews of episode %d of series (ID: %d): %v", ep.ID, seriesID, err)
+		}
+	}
+}
+
+// nextEpisodeID reserves the ID to assign to a newly added episode of the
+// series. The caller holds the write lock of storeMutex.
+func nextEpisodeID(seriesID int, episodes []Episode) (int, error) {
+	last, err := recordEpisodeIDs(seriesID, episodes)
+	if err != nil {
+		return 0, err
+	}
+	if err := episodeIDStore.Put(seriesID, last+1); err != nil {
+		return 0, err
+	}
+	return last + 1, nil
+}
+
+// decodeEpisode reads an Episode from the request body, rejecting an "id"
+// that differs from expectedID (0 means no ID may be supplied).
+func decodeEpisode(r *http.Request, expectedID int) (Episode, int, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return Episode{}, http.StatusBadRequest, fmt.Errorf("invalid request body")
+	}
+	defer r.Body.Close()
+
+	if bodyID, ok := raw["id"]; ok && string(bodyID) != "null" {
+		var n int
+		if err := json.Unmarshal(bodyID, &n); err != nil || n != expectedID {
+			return Episode{}, http.StatusConflict, fmt.Errorf("episode ID is assigned by the server and cannot be changed")
+		}
+	}
+
+	body, _ := json.Marshal(raw)
+	var ep Episode
+	if err := json.Unmarshal(body, &ep); err != nil {
+		return Episode{}, http.StatusBadRequest, fmt.Errorf("invalid request body")
+	}
+	if ep.Title == "" {
+		return Episode{}, http.StatusBadRequest, fmt.Errorf("title is required")
+	}
+	return ep, 0, nil
+}
+
+func writeEpisodeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("Error encoding episode response: %v", err)
+	}
+}
+
+// getEpisodesHandler handles GET /series/{id}/episodes
+func getEpisodesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storeMutex.RLock()
+	series, exists, err := seriesStore.Get(id)
+	storeMutex.RUnlock()
+
+	if err != nil {
+		log.Printf("Error loading series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	episodes := series.Episodes
+	if episodes == nil {
+		episodes = []Episode{}
+	}
+	writeEpisodeJSON(w, http.StatusOK, episodes)
+	log.Printf("Handled GET /series/%d/episodes request", id)
+}
+
+// getEpisodeHandler handles GET /series/{id}/episodes/{episodeId}
+func getEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	episodeID, err := episodeIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storeMutex.RLock()
+	series, exists, err := seriesStore.Get(id)
+	storeMutex.RUnlock()
+
+	if err != nil {
+		log.Printf("Error loading series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
+		return
+	}
+	idx := findEpisode(series.Episodes, episodeID)
+	if idx < 0 {
+		http.Error(w, fmt.Sprintf("Episode with ID %d not found in series %d", episodeID, id), http.StatusNotFound)
+		return
+	}
+
+	writeEpisodeJSON(w, http.StatusOK, series.Episodes[idx])
+	log.Printf("Handled GET /series/%d/episodes/%d request", id, episodeID)
+}
+
+// createEpisodeHandler handles POST /series/{id}/episodes
+func createEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ep, status, err := decodeEpisode(r, 0)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	storeMutex.Lock() // Write lock held across read-modify-write
+	series, exists, err := seriesStore.Get(id)
+	if err == nil && exists {
+		ep.ID, err = nextEpisodeID(id, series.Episodes)
+	}
+	if err == nil && exists {
+		series.Episodes = append(series.Episodes, ep)
+		// Only an exceeded total is raised; a larger announced total stands
+		if series.TotalEpisodes < len(series.Episodes) {
+			series.TotalEpisodes = len(series.Episodes)
+		}
+		err = seriesStore.Put(id, series)
+	}
+	storeMutex.Unlock()
+
+	if err != nil {
+		log.Printf("Error adding episode to series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/series/%d/episodes/%d", id, ep.ID))
+	writeEpisodeJSON(w, http.StatusCreated, ep)
+	log.Printf("Handled POST /series/%d/episodes request, created episode ID: %d", id, ep.ID)
+}
+
+// updateEpisodeHandler handles PUT /series/{id}/episodes/{episodeId}
+func updateEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	episodeID, err := episodeIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ep, status, err := decodeEpisode(r, episodeID)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	ep.ID = episodeID
+
+	storeMutex.Lock() // Write lock held across read-modify-write
+	defer storeMutex.Unlock()
+
+	series, exists, err := seriesStore.Get(id)
+	if err != nil {
+		log.Printf("Error loading series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
+		return
+	}
+	idx := findEpisode(series.Episodes, episodeID)
+	if idx < 0 {
+		http.Error(w, fmt.Sprintf("Episode with ID %d not found in series %d", episodeID, id), http.StatusNotFound)
+		return
+	}
+	series.Episodes[idx] = ep
+	if err := seriesStore.Put(id, series); err != nil {
+		log.Printf("Error updating episode %d of series (ID: %d): %v", episodeID, id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	writeEpisodeJSON(w, http.StatusOK, ep)
+	log.Printf("Handled PUT /series/%d/episodes/%d request", id, episodeID)
+}
+
+// deleteEpisodeHandler handles DELETE /series/{id}/episodes/{episodeId}
+func deleteEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	episodeID, err := episodeIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storeMutex.Lock() // Write lock held across read-modify-write
+	defer storeMutex.Unlock()
+
+	series, exists, err := seriesStore.Get(id)
+	if err != nil {
+		log.Printf("Error loading series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
+		return
+	}
+	idx := findEpisode(series.Episodes, episodeID)
+	if idx < 0 {
+		http.Error(w, fmt.Sprintf("Episode with ID %d not found in series %d", episodeID, id), http.StatusNotFound)
+		return
+	}
+	if _, err := recordEpisodeIDs(id, series.Episodes); err != nil {
+		log.Printf("Error recording episode IDs of series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// A fully listed series stays fully listed; otherwise the announced
+	// total is left alone.
+	if series.TotalEpisodes == len(series.Episodes) {
+		series.TotalEpisodes--
+	}
+	series.Episodes = append(series.Episodes[:idx], series.Episodes[idx+1:]...)
+	if err := seriesStore.Put(id, series); err != nil {
+		log.Printf("Error deleting episode %d of series (ID: %d): %v", episodeID, id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// The episode is gone, so a failed purge does not fail the request
+	if _, err := reviewRepo.DeleteEpisode(id, episodeID); err != nil {
+		log.Printf("Error deleting reviews of episode %d of series (ID: %d): %v", episodeID, id, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Handled DELETE /series/%d/episodes/%d request", id, episodeID)
+}
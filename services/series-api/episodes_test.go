@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/mbenabdallah/shared/reviews"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+func TestEpisodes(t *testing.T) {
+	srv := newTestServer(t, Series{ID: 1, Title: "Dark", TotalEpisodes: 26, Episodes: []Episode{
+		{ID: 1, Title: "Secrets"},
+		{ID: 2, Title: "Lies"},
+	}})
+
+	var ep Episode
+	resp := do(t, srv, "POST", "editor", "/api/series/1/episodes", `{"title":"Past and Present"}`, &ep)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST: status %d, want 201", resp.StatusCode)
+	}
+	if ep.ID != 3 || resp.Header.Get("Location") != "/api/series/1/episodes/3" {
+		t.Errorf("POST: got %+v at %q", ep, resp.Header.Get("Location"))
+	}
+	if resp := do(t, srv, "PUT", "editor", "/api/series/1/episodes/3", `{"title":"Double Lives","watchUrl":"https://example.com/dark/3"}`, &ep); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: status %d, want 200", resp.StatusCode)
+	}
+	var got Episode
+	do(t, srv, "GET", "", "/api/series/1/episodes/3", "", &got)
+	if got != ep || got.Title != "Double Lives" {
+		t.Errorf("GET after PUT = %+v, want %+v", got, ep)
+	}
+
+	var series Series
+	do(t, srv, "GET", "", "/api/series/1", "", &series)
+	if series.TotalEpisodes != 26 {
+		t.Errorf("totalEpisodes = %d, want the announced 26 to stand", series.TotalEpisodes)
+	}
+
+	for _, c := range []struct {
+		method, user, path, body string
+		want                     int
+	}{
+		{"POST", "", "/api/series/1/episodes", `{"title":"Anonymous"}`, http.StatusUnauthorized},
+		{"POST", "viewer", "/api/series/1/episodes", `{"title":"Viewer"}`, http.StatusForbidden},
+		{"POST", "editor", "/api/series/1/episodes", `{"watchUrl":"x"}`, http.StatusBadRequest},
+		{"POST", "editor", "/api/series/1/episodes", `{"id":7,"title":"Chosen ID"}`, http.StatusConflict},
+		{"POST", "editor", "/api/series/42/episodes", `{"title":"Missing"}`, http.StatusNotFound},
+		{"PUT", "editor", "/api/series/1/episodes/3", `{"id":4,"title":"Moved"}`, http.StatusConflict},
+		{"PUT", "editor", "/api/series/1/episodes/9", `{"title":"Missing"}`, http.StatusNotFound},
+		{"GET", "", "/api/series/1/episodes/9", "", http.StatusNotFound},
+		{"GET", "", "/api/series/42/episodes", "", http.StatusNotFound},
+		{"DELETE", "editor", "/api/series/1/episodes/9", "", http.StatusNotFound},
+	} {
+		if resp := do(t, srv, c.method, c.user, c.path, c.body, nil); resp.StatusCode != c.want {
+			t.Errorf("%s %s as %q with %s: status %d, want %d", c.method, c.path, c.user, c.body, resp.StatusCode, c.want)
+		}
+	}
+}
+
+func TestEpisodeIDsAreNotReused(t *testing.T) {
+	srv := newTestServer(t, Series{ID: 1, Title: "Dark", TotalEpisodes: 2, Episodes: []Episode{
+		{ID: 1, Title: "Secrets"},
+		{ID: 2, Title: "Lies"},
+	}})
+
+	if resp := do(t, srv, "DELETE", "editor", "/api/series/1/episodes/2", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE: status %d, want 204", resp.StatusCode)
+	}
+	var series Series
+	do(t, srv, "GET", "", "/api/series/1", "", &series)
+	if series.TotalEpisodes != 1 {
+		t.Errorf("totalEpisodes of a fully listed series after DELETE = %d, want 1", series.TotalEpisodes)
+	}
+
+	var ep Episode
+	if resp := do(t, srv, "POST", "editor", "/api/series/1/episodes", `{"title":"Past and Present"}`, &ep); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST: status %d, want 201", resp.StatusCode)
+	}
+	if ep.ID != 3 {
+		t.Errorf("episode ID after deleting episode 2 = %d, want 3", ep.ID)
+	}
+	do(t, srv, "GET", "", "/api/series/1", "", &series)
+	if series.TotalEpisodes != 2 {
+		t.Errorf("totalEpisodes after exceeding it = %d, want 2", series.TotalEpisodes)
+	}
+
+	var episodes []Episode
+	do(t, srv, "GET", "", "/api/series/1/episodes", "", &episodes)
+	if len(episodes) != 2 || episodes[0].ID != 1 || episodes[1].ID != 3 {
+		t.Errorf("GET episodes = %+v", episodes)
+	}
+}
+
+func TestEpisodeIDsDroppedByReplacementAreNotReused(t *testing.T) {
+	srv := newTestServer(t, Series{ID: 1, Title: "Dark", TotalEpisodes: 3, Episodes: []Episode{
+		{ID: 1, Title: "Secrets"},
+		{ID: 2, Title: "Lies"},
+		{ID: 3, Title: "Past and Present"},
+	}})
+
+	if resp := do(t, srv, "PATCH", "editor", "/api/series/1", `{"episodes":[{"id":1,"title":"Secrets"}]}`, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH: status %d, want 200", resp.StatusCode)
+	}
+	var ep Episode
+	do(t, srv, "POST", "editor", "/api/series/1/episodes", `{"title":"Double Lives"}`, &ep)
+	if ep.ID != 4 {
+		t.Errorf("episode ID after a PATCH dropped episodes 2 and 3 = %d, want 4", ep.ID)
+	}
+}
+
+func TestReplacedEpisodesAreValidated(t *testing.T) {
+	srv := newTestServer(t, Series{ID: 1, Title: "Dark", TotalEpisodes: 1, Episodes: []Episode{{ID: 1, Title: "Secrets"}}})
+
+	for _, episodes := range []string{
+		`[{"id":1,"title":"Secrets"},{"id":1,"title":"Lies"}]`,
+		`[{"id":0,"title":"Secrets"}]`,
+		`[{"id":-2,"title":"Secrets"}]`,
+		`[{"id":2,"title":" "}]`,
+		`[{"id":1,"title":"Secrets"},{"id":2,"title":"Lies"}]`, // more than totalEpisodes
+	} {
+		if resp := do(t, srv, "PUT", "editor", "/api/series/1", `{"title":"Dark","totalEpisodes":1,"episodes":`+episodes+`}`, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("PUT with episodes %s: status %d, want 400", episodes, resp.StatusCode)
+		}
+		if resp := do(t, srv, "PATCH", "editor", "/api/series/1", `{"episodes":`+episodes+`}`, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("PATCH with episodes %s: status %d, want 400", episodes, resp.StatusCode)
+		}
+	}
+	var series Series
+	do(t, srv, "GET", "", "/api/series/1", "", &series)
+	if len(series.Episodes) != 1 || series.Episodes[0].Title != "Secrets" {
+		t.Errorf("rejected replacements changed the episodes: %+v", series.Episodes)
+	}
+
+	// Client-chosen IDs are recorded, so later episodes are numbered after them
+	if resp := do(t, srv, "PUT", "editor", "/api/series/1", `{"title":"Dark","totalEpisodes":2,"episodes":[{"id":10,"title":"Alpha and Omega"}]}`, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: status %d, want 200", resp.StatusCode)
+	}
+	if resp := do(t, srv, "PATCH", "editor", "/api/series/1", `{"episodes":[]}`, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH: status %d, want 200", resp.StatusCode)
+	}
+	var ep Episode
+	do(t, srv, "POST", "editor", "/api/series/1/episodes", `{"title":"Beginnings and Endings"}`, &ep)
+	if ep.ID != 11 {
+		t.Errorf("episode ID after a PUT brought episode 10 = %d, want 11", ep.ID)
+	}
+
+	var created Series
+	do(t, srv, "POST", "editor", "/api/series", `{"title":"1899","totalEpisodes":8,"episodes":[{"id":5,"title":"The Ship"}]}`, &created)
+	do(t, srv, "POST", "editor", "/api/series/"+strconv.Itoa(created.ID)+"/episodes", `{"title":"The Fog"}`, &ep)
+	if ep.ID != 6 {
+		t.Errorf("episode ID after POST brought episode 5 = %d, want 6", ep.ID)
+	}
+	if resp := do(t, srv, "POST", "editor", "/api/series", `{"title":"1899","episodes":[{"id":5,"title":"The Ship"}]}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST with more episodes than totalEpisodes: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestDroppedEpisodesLoseTheirReviews(t *testing.T) {
+	srv := newTestServer(t, Series{ID: 1, Title: "Dark", TotalEpisodes: 3, Episodes: []Episode{
+		{ID: 1, Title: "Secrets"},
+		{ID: 2, Title: "Lies"},
+		{ID: 3, Title: "Past and Present"},
+	}})
+	for ep := 1; ep <= 3; ep++ {
+		path := "/api/series/1/episodes/" + strconv.Itoa(ep) + "/reviews"
+		if resp := do(t, srv, "POST", "alice", path, `{"rating":8}`, nil); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("POST %s: status %d, want 201", path, resp.StatusCode)
+		}
+	}
+
+	do(t, srv, "PUT", "editor", "/api/series/1", `{"title":"Dark","totalEpisodes":3,"episodes":[{"id":1,"title":"Secrets"},{"id":2,"title":"Lies"}]}`, nil)
+	do(t, srv, "PATCH", "editor", "/api/series/1", `{"episodes":[{"id":1,"title":"Secrets"}]}`, nil)
+	for ep, want := range map[int]int{1: 1, 2: 0, 3: 0} {
+		if summary, _ := reviewRepo.Summary(reviews.Episode(1, ep)); summary.Count != want {
+			t.Errorf("episode %d has %d reviews, want %d", ep, summary.Count, want)
+		}
+	}
+}
+
+func TestDeleteEpisodeSucceedsWhenReviewPurgeFails(t *testing.T) {
+	srv := newTestServer(t, Series{ID: 1, Title: "Dark", TotalEpisodes: 1, Episodes: []Episode{{ID: 1, Title: "Secrets"}}})
+	broken, err := storage.OpenBoltBackend(filepath.Join(t.TempDir(), "reviews.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reviewRepo, err = reviews.Open(broken); err != nil {
+		t.Fatal(err)
+	}
+	if resp := do(t, srv, "POST", "alice", "/api/series/1/episodes/1/reviews", `{"rating":8}`, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST review: status %d, want 201", resp.StatusCode)
+	}
+	broken.Close() // purging the review now fails
+
+	if resp := do(t, srv, "DELETE", "editor", "/api/series/1/episodes/1", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE: status %d, want 204 once the episode is gone", resp.StatusCode)
+	}
+	if resp := do(t, srv, "GET", "", "/api/series/1/episodes/1", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET of the deleted episode: status %d, want 404", resp.StatusCode)
+	}
+}
@@ -2,4 +2,15 @@ module github.com/mbenabdallah/series-api
 
 go 1.24.2
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/mbenabdallah/shared v0.0.0
+)
+
+require (
+	go.etcd.io/bbolt v1.4.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/mbenabdallah/shared => ../shared
@@ -4,11 +4,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/mux"
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/genre"
+	"github.com/mbenabdallah/shared/reviews"
+	"github.com/mbenabdallah/shared/seed"
+	"github.com/mbenabdallah/shared/storage"
 )
 
 // Episode struct definition
@@ -20,90 +27,47 @@ type Episode struct {
 
 // Series struct definition
 type Series struct {
-	ID              int       `json:"id"`
-	Title           string    `json:"title"`
-	Genre           string    `json:"genre"`
-	TotalEpisodes   int       `json:"totalEpisodes"`
-	WatchedEpisodes int       `json:"watchedEpisodes"` // Example additional field
-	CoverURL        string    `json:"coverUrl"`        // New field
-	Episodes        []Episode `json:"episodes"`        // New field
-}
-
-// In-memory data store (using a map for easier ID lookup)
-var seriesStore = make(map[int]Series)
-var nextSeriesID = 4
-var storeMutex = &sync.RWMutex{} // Mutex to handle concurrent access
-
-// Initialize with some sample data
-func init() {
-	seriesStore[1] = Series{
-		ID:              1,
-		Title:           "Breaking Bad",
-		Genre:           "Crime Drama",
-		TotalEpisodes:   7,
-		WatchedEpisodes: 0,
-		CoverURL:        "https://www.bpmcdn.com/f/files/kelowna/import/2022-06/29555137_web1_220630-KCN-Breaking-Bad-_1.jpg",
-		Episodes: []Episode{
-			{ID: 1, Title: "Pilot", WatchURL: "https://example.com/breakingbad/s01e01.mp4"},
-			{ID: 2, Title: "Cat's in the Bag...", WatchURL: "https://example.com/breakingbad/s01e02.mp4"},
-			{ID: 3, Title: "...And the Bag's in the River", WatchURL: "https://example.com/breakingbad/s01e03.mp4"},
-			{ID: 4, Title: "Cancer Man", WatchURL: "https://example.com/breakingbad/s01e04.mp4"},
-			{ID: 5, Title: "Gray Matter", WatchURL: "https://example.com/breakingbad/s01e05.mp4"},
-			{ID: 6, Title: "Crazy Handful of Nothin'", WatchURL: "https://example.com/breakingbad/s01e06.mp4"},
-			{ID: 7, Title: "A No-Rough-Stuff-Type Deal", WatchURL: "https://example.com/breakingbad/s01e07.mp4"},
-		},
-	}
-	seriesStore[2] = Series{
-		ID:              2,
-		Title:           "Invincible",
-		Genre:           "Action, Adventure, Animation",
-		TotalEpisodes:   8,
-		WatchedEpisodes: 0,
-		CoverURL:        "https://www.vitalthrills.com/wp-content/uploads/2024/12/invincibleccxp1.jpg",
-		Episodes: []Episode{
-			{ID: 1, Title: "It's About Time",
-				WatchURL: "https://dn721603.ca.archive.org/0/items/Invincible_Season_1/EP1.ia.mp4"},
-			{ID: 2, Title: "Here Goes Nothing",
-				WatchURL: "https://dn721603.ca.archive.org/0/items/Invincible_Season_1/EP2.ia.mp4"},
-			{ID: 3, Title: "Who You Calling Ugly?",
-				WatchURL: "https://dn721603.ca.archive.org/0/items/Invincible_Season_1/EP3.ia.mp4"},
-			{ID: 4, Title: "Neil Armstrong, Eat Your Heart Out",
-				WatchURL: "https://dn721603.ca.archive.org/0/items/Invincible_Season_1/EP4.ia.mp4"},
-			{ID: 5, Title: "That Actually Hurt",
-				WatchURL: "https://dn721603.ca.archive.org/0/items/Invincible_Season_1/EP5.ia.mp4"},
-			{ID: 6, Title: "You Look Kinda Dead",
-				WatchURL: "https://dn721603.ca.archive.org/0/items/Invincible_Season_1/EP6.ia.mp4"},
-			{ID: 7, Title: "We Need to Talk",
-				WatchURL: "https://dn721603.ca.archive.org/0/items/Invincible_Season_1/EP7.ia.mp4"},
-			{ID: 8, Title: "Where I Really Come From",
-				WatchURL: "https://dn721603.ca.archive.org/0/items/Invincible_Season_1/EP8.ia.mp4"},
-		},
-	}
-
-	seriesStore[3] = Series{
-		ID:              3,
-		Title:           "Severance",
-		Genre:           "Sci-Fi Thriller", // Assumed genre
-		TotalEpisodes:   12,                // Counted from the provided image (may be incomplete)
-		WatchedEpisodes: 0,
-		CoverURL:        "https://img.newsroom.cj.net/wp-content/uploads/2023/07/image-1.png",
-		Episodes: []Episode{
-			// Based on provided image and URL structure, encoding special characters in URL filename part
-			// Using the base path from S01E01 and host from S01E02 example for all episodes
-			{ID: 1, Title: "GOOD NEWS ABOUT HELL-1", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S01E01%20-%20GOOD%20NEWS%20ABOUT%20%20HELL-1.mp4"}, // S01E01
-			{ID: 2, Title: "HALF LOOP", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S01E02%20-%20HALF%20LOOP.mp4"},                                  // S01E02
-			{ID: 3, Title: "IN PERPETUITY", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S01E03%20-%20IN%20PERPETUITY.mp4"},                          // S01E03
-			{ID: 4, Title: "HIDE AND SEEK", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S01E06%20-%20HIDE%20AND%20SEEK.mp4"},                        // S01E06 (Skipped E04, E05, E07 as not in image)
-			{ID: 5, Title: "WHAT'S FOR DINNER", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S01E08%20-%20WHAT%27S%20FOR%20DINNER.mp4"},              // S01E08 - Encoded ' as %27
-			{ID: 6, Title: "HELLO, MS. COBELL", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S02E01%20-%20HELLO%2C%20MS.%20COBELL.mp4"},              // S02E01 - Encoded , as %2C and . as %2E
-			{ID: 7, Title: "GOODBYE MS. SELVIG", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S02E02%20-%20GOODBYE%20MS.%20SELVIG.mp4"},              // S02E02 - Encoded . as %2E
-			{ID: 8, Title: "WHO IS ALIVE", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S02E03%20-%20WHO%20IS%20ALIVE.mp4"},                          // S02E03
-			{ID: 9, Title: "WOES HOLLOW", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S02E04%20-%20WOES%20HOLLOW.mp4"},                              // S02E04
-			{ID: 10, Title: "TROJAN'S HORSE", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S02E05%20-%20TROJAN%27S%20HORSE.mp4"},                     // S02E05 - Encoded ' as %27
-			{ID: 11, Title: "ATTILA", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S02E06%20-%20ATTILA.mp4"},                                         // S02E06
-			{ID: 12, Title: "CHIKHAI BARDO", WatchURL: "https://ia800107.us.archive.org/28/items/severance-s-01-e-01-good-news-about-hell-1/SEVERANCE%20S02E07%20-%20CHIKHAI%20BARDO.mp4"},                         // S02E07 (Skipped E08+ as not in image)
-		},
+	ID            int        `json:"id"`
+	Title         string     `json:"title"`
+	Genres        genre.List `json:"genres"` // Canonical genre slugs
+	Genre         string     `json:"genre"`  // Legacy display form of Genres, accepted on input
+	TotalEpisodes int        `json:"totalEpisodes"`
+	CoverURL      string     `json:"coverUrl"` // New field
+	Episodes      []Episode  `json:"episodes"` // New field
+}
+
+// Data store, selected by STORAGE_BACKEND (memory or bolt) at startup
+var seriesStore storage.Store[Series]
+var storeMutex = &sync.RWMutex{} // Serializes read-modify-write sequences on the store
+
+// genreFields returns the genre list and its legacy string form for the genre registry helpers
+func (s *Series) genreFields() (*genre.List, *string) {
+	return &s.Genres, &s.Genre
+}
+
+// validateSeries checks a series, from a fixture or a request body, before it
+// is written to the store
+func validateSeries(s Series) error {
+	if strings.TrimSpace(s.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	if err := genre.Canonicalize(s.genreFields()); err != nil {
+		return err
 	}
+	seen := make(map[int]bool, len(s.Episodes))
+	for _, ep := range s.Episodes {
+		if ep.ID <= 0 || seen[ep.ID] {
+			return fmt.Errorf("episode IDs must be positive and unique (got %d)", ep.ID)
+		}
+		seen[ep.ID] = true
+		if strings.TrimSpace(ep.Title) == "" {
+			return fmt.Errorf("episode %d: title is required", ep.ID)
+		}
+	}
+	if s.TotalEpisodes < len(s.Episodes) {
+		return fmt.Errorf("totalEpisodes (%d) is less than the number of episodes (%d)", s.TotalEpisodes, len(s.Episodes))
+	}
+	return nil
 }
 
 // --- Handler Functions ---
@@ -111,16 +75,30 @@ func init() {
 // getSeriesHandler handles GET /series
 func getSeriesHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received request path: %s", r.URL.Path) // Log received path
-	storeMutex.RLock()                                  // Read lock
-	defer storeMutex.RUnlock()
+	params, err := parseListParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	seriesList := make([]Series, 0, len(seriesStore))
-	for _, s := range seriesStore {
-		seriesList = append(seriesList, s)
+	storeMutex.RLock() // Read lock
+	seriesList, err := seriesStore.List()
+	storeMutex.RUnlock()
+	if err != nil {
+		log.Printf("Error listing series: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
+	page := paginateSeries(seriesList, params)
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(seriesList); err != nil {
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	w.Header().Set("Link", linkHeader(r.URL, params, page))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	if err := json.NewEncoder(w).Encode(viewsFor(r, page.Items)); err != nil {
 		log.Printf("Error encoding series list: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
@@ -144,22 +122,20 @@ func getSeriesByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	storeMutex.RLock() // Read lock
-	// Log the current state of the store before lookup
-	log.Printf("Current seriesStore: %+v\n", seriesStore)
-	// Use direct map lookup
-	series, exists := seriesStore[id]
+	series, exists, err := seriesStore.Get(id)
 	storeMutex.RUnlock()
+	if err != nil {
+		log.Printf("Error loading series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
 	if !exists {
 		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(series); err != nil {
-		log.Printf("Error encoding series (ID: %d): %v", id, err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-	}
+	writeSeries(w, r, http.StatusOK, series)
 	log.Printf("Handled GET /series/%d request", id)
 }
 
@@ -173,9 +149,13 @@ func createSeriesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	// Basic validation (e.g., title is required)
-	if newSeries.Title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+	// Accept either a genres list or the legacy genre string
+	if err := genre.Canonicalize(newSeries.genreFields()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateSeries(newSeries); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	// Add default empty slice for episodes if not provided, prevents null in JSON
@@ -184,38 +164,349 @@ func createSeriesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	storeMutex.Lock() // Write lock
-	newSeries.ID = nextSeriesID
-	seriesStore[newSeries.ID] = newSeries
-	nextSeriesID++
+	id, err := seriesStore.NextID()
+	if err == nil {
+		newSeries.ID = id
+		_, err = recordEpisodeIDs(id, newSeries.Episodes) // client-chosen episode IDs stay taken
+	}
+	if err == nil {
+		err = seriesStore.Put(newSeries.ID, newSeries)
+	}
 	storeMutex.Unlock()
+	if err != nil {
+		log.Printf("Error storing new series: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	writeSeries(w, r, http.StatusCreated, newSeries)
+	log.Printf("Handled POST /series request, created series ID: %d", newSeries.ID)
+}
+
+// seriesIDFromRequest parses the {id} path variable of a series route.
+func seriesIDFromRequest(r *http.Request) (int, error) {
+	idStr, ok := mux.Vars(r)["id"]
+	if !ok {
+		return 0, fmt.Errorf("missing series ID")
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid series ID format")
+	}
+	return id, nil
+}
 
+// writeSeries encodes a single series, as seen by the requesting user, as the JSON response body.
+func writeSeries(w http.ResponseWriter, r *http.Request, status int, series Series) {
+	view := viewsFor(r, []Series{series})[0]
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(newSeries); err != nil {
-		log.Printf("Error encoding created series (ID: %d): %v", newSeries.ID, err)
-		// Note: Cannot write header again here as it's already sent.
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		log.Printf("Error encoding series (ID: %d): %v", series.ID, err)
 	}
-	log.Printf("Handled POST /series request, created series ID: %d", newSeries.ID)
 }
 
-func main() {
+// updateSeriesHandler handles PUT /series/{id} (full replacement)
+func updateSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Decode into a raw map first so an explicit "id" in the body can be detected.
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		log.Printf("Error decoding request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := checkBodyID(raw, id); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	body, _ := json.Marshal(raw)
+	var replacement Series
+	if err := json.Unmarshal(body, &replacement); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		log.Printf("Error decoding series: %v", err)
+		return
+	}
+	if err := genre.Canonicalize(replacement.genreFields()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateSeries(replacement); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if replacement.Episodes == nil {
+		replacement.Episodes = []Episode{}
+	}
+	replacement.ID = id
+
+	storeMutex.Lock() // Write lock
+	current, exists, err := seriesStore.Get(id)
+	if err == nil && exists {
+		// The replacement may drop episodes or bring its own IDs
+		_, err = recordEpisodeIDs(id, append(current.Episodes, replacement.Episodes...))
+	}
+	if err == nil && exists {
+		err = seriesStore.Put(id, replacement)
+	}
+	if err == nil && exists {
+		purgeDroppedEpisodeReviews(id, current.Episodes, replacement.Episodes)
+	}
+	storeMutex.Unlock() // Released before writeSeries calls the progress API
+	if err != nil {
+		log.Printf("Error replacing series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	writeSeries(w, r, http.StatusOK, replacement)
+	log.Printf("Handled PUT /series/%d request", id)
+}
+
+// patchSeriesHandler handles PATCH /series/{id} using JSON Merge Patch (RFC 7396)
+func patchSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			http.Error(w, "Content-Type must be application/merge-patch+json", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	var patch map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid merge patch document", http.StatusBadRequest)
+		log.Printf("Error decoding merge patch: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	storeMutex.Lock() // Write lock held across read-modify-write
+	updated, status, err := patchSeries(id, patch)
+	storeMutex.Unlock() // Released before writeSeries calls the progress API
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Printf("Error patching series (ID: %d): %v", id, err)
+			http.Error(w, "Internal Server Error", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeSeries(w, r, http.StatusOK, updated)
+	log.Printf("Handled PATCH /series/%d request", id)
+}
+
+// patchSeries applies a merge patch to the stored series with the given ID
+// and stores the result. On failure it returns the HTTP status to respond
+// with. The caller holds the write lock of storeMutex.
+func patchSeries(id int, patch map[string]interface{}) (Series, int, error) {
+	current, exists, err := seriesStore.Get(id)
+	if err != nil {
+		return Series{}, http.StatusInternalServerError, err
+	}
+	if !exists {
+		return Series{}, http.StatusNotFound, fmt.Errorf("Series with ID %d not found", id)
+	}
+
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return Series{}, http.StatusInternalServerError, err
+	}
+	var target map[string]interface{}
+	if err := json.Unmarshal(currentJSON, &target); err != nil {
+		return Series{}, http.StatusInternalServerError, err
+	}
+
+	// A patch of the legacy genre string replaces the current genre list
+	if _, legacy := patch["genre"]; legacy {
+		if _, list := patch["genres"]; !list {
+			delete(target, "genres")
+		}
+	}
+
+	merged, _ := mergePatch(target, patch).(map[string]interface{})
+	if mergedID, ok := merged["id"].(float64); !ok || int(mergedID) != id {
+		return Series{}, http.StatusConflict, fmt.Errorf("Series ID cannot be changed")
+	}
+
+	mergedJSON, _ := json.Marshal(merged)
+	var updated Series
+	if err := json.Unmarshal(mergedJSON, &updated); err != nil {
+		log.Printf("Error decoding patched series (ID: %d): %v", id, err)
+		return Series{}, http.StatusBadRequest, fmt.Errorf("Merge patch produced an invalid series")
+	}
+	if err := genre.Canonicalize(updated.genreFields()); err != nil {
+		return Series{}, http.StatusBadRequest, err
+	}
+	if err := validateSeries(updated); err != nil {
+		return Series{}, http.StatusBadRequest, err
+	}
+	if updated.Episodes == nil {
+		updated.Episodes = []Episode{}
+	}
+	// The patch may drop episodes or bring its own IDs
+	if _, err := recordEpisodeIDs(id, append(current.Episodes, updated.Episodes...)); err != nil {
+		return Series{}, http.StatusInternalServerError, err
+	}
+	if err := seriesStore.Put(id, updated); err != nil {
+		return Series{}, http.StatusInternalServerError, err
+	}
+	purgeDroppedEpisodeReviews(id, current.Episodes, updated.Episodes)
+	return updated, http.StatusOK, nil
+}
+
+// deleteSeriesHandler handles DELETE /series/{id}
+func deleteSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storeMutex.Lock() // Write lock
+	exists, err := seriesStore.Delete(id)
+	if err == nil && exists {
+		_, err = reviewRepo.DeleteItem(id) // and the reviews of the series and its episodes
+	}
+	if err == nil && exists {
+		_, err = episodeIDStore.Delete(id) // series IDs are never reused
+	}
+	storeMutex.Unlock()
+
+	if err != nil {
+		log.Printf("Error deleting series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Handled DELETE /series/%d request", id)
+}
+
+// checkBodyID rejects a request body whose "id" differs from the path ID.
+func checkBodyID(raw map[string]json.RawMessage, id int) error {
+	bodyID, ok := raw["id"]
+	if !ok || string(bodyID) == "null" {
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(bodyID, &n); err != nil || n != id {
+		return fmt.Errorf("series ID cannot be changed")
+	}
+	return nil
+}
+
+// mergePatch applies an RFC 7396 JSON Merge Patch to target and returns the result.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+// newRouter returns the routes of the service. The caller wraps it in the
+// verifier's Authenticate middleware.
+func newRouter() *mux.Router {
 	r := mux.NewRouter()
+	registerReviewRoutes(r)
 
 	// Define routes
 	apiRouter := r.PathPrefix("/api/series").Subrouter() // Base path for series API
+	apiRouter.Use(func(next http.Handler) http.Handler { return auth.RequireRoleForWrites(auth.RoleEditor, next) })
 	apiRouter.HandleFunc("", getSeriesHandler).Methods("GET")
 	apiRouter.HandleFunc("", createSeriesHandler).Methods("POST")
 	apiRouter.HandleFunc("/{id:[0-9]+}", getSeriesByIDHandler).Methods("GET")
+	apiRouter.HandleFunc("/{id:[0-9]+}", updateSeriesHandler).Methods("PUT")
+	apiRouter.HandleFunc("/{id:[0-9]+}", patchSeriesHandler).Methods("PATCH")
+	apiRouter.HandleFunc("/{id:[0-9]+}", deleteSeriesHandler).Methods("DELETE")
+	apiRouter.HandleFunc("/{id:[0-9]+}/episodes", getEpisodesHandler).Methods("GET")
+	apiRouter.HandleFunc("/{id:[0-9]+}/episodes", createEpisodeHandler).Methods("POST")
+	apiRouter.HandleFunc("/{id:[0-9]+}/episodes/{episodeId:[0-9]+}", getEpisodeHandler).Methods("GET")
+	apiRouter.HandleFunc("/{id:[0-9]+}/episodes/{episodeId:[0-9]+}", updateEpisodeHandler).Methods("PUT")
+	apiRouter.HandleFunc("/{id:[0-9]+}/episodes/{episodeId:[0-9]+}", deleteEpisodeHandler).Methods("DELETE")
 
 	// Simple root handler for health check / info
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "Series REST API is running. Try /series")
 	})
+	return r
+}
+
+func main() {
+	backend, err := storage.Open(storage.ConfigFromEnv("data/series.db"))
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer backend.Close()
+	if seriesStore, err = storage.New[Series](backend, "series"); err != nil {
+		log.Fatalf("Failed to open series store: %v", err)
+	}
+	if episodeIDStore, err = storage.New[int](backend, "episode_ids"); err != nil {
+		log.Fatalf("Failed to open episode ID store: %v", err)
+	}
+	if _, err := seed.Apply(seriesStore, seed.OptionsFromEnv("seed"), "series",
+		func(s Series) int { return s.ID }, validateSeries); err != nil {
+		log.Fatalf("Failed to seed series store: %v", err)
+	}
+	// Convert legacy genre strings (fixtures, older records) to genre lists
+	if n, err := genre.Migrate(seriesStore, func(s Series) int { return s.ID }, (*Series).genreFields); err != nil {
+		log.Fatalf("Failed to migrate series genres: %v", err)
+	} else if n > 0 {
+		log.Printf("Migrated genres of %d series", n)
+	}
+	if reviewRepo, err = reviews.Open(backend); err != nil {
+		log.Fatalf("Failed to open review store: %v", err)
+	}
+
+	// Bearer tokens are verified against the JWKS file; catalogue writes need
+	// the editor role, reviews any signed-in user
+	verifier, err := auth.NewVerifier(auth.ConfigFromEnv("config/jwks.json"))
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	r := newRouter()
 
 	port := "8081"
 	fmt.Printf("Series REST API starting on port %s...\n", port)
 	log.Printf("Series REST API starting on port %s...", port)
 
 	// Start server
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	log.Fatal(http.ListenAndServe(":"+port, verifier.Authenticate(r)))
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/reviews"
+	"github.com/mbenabdallah/shared/storage"
+)
+
+var testKey = auth.SigningKey{ID: "test", Algorithm: auth.HS256, Secret: []byte("0123456789abcdef0123456789abcdef")}
+
+// newTestServer serves the series routes over an in-memory store holding
+// series, accepting tokens signed with testKey.
+func newTestServer(t *testing.T, series ...Series) *httptest.Server {
+	t.Helper()
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	jwks, _ := json.Marshal(auth.JWKS{Keys: []auth.JWK{{
+		Kty: "oct", Kid: testKey.ID, K: base64.RawURLEncoding.EncodeToString(testKey.Secret),
+	}}})
+	if err := os.WriteFile(jwksFile, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := storage.NewMemoryBackend()
+	if seriesStore, err = storage.New[Series](backend, "series"); err != nil {
+		t.Fatal(err)
+	}
+	if episodeIDStore, err = storage.New[int](backend, "episode_ids"); err != nil {
+		t.Fatal(err)
+	}
+	if reviewRepo, err = reviews.Open(backend); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range series {
+		if err := seriesStore.Put(s.ID, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(verifier.Authenticate(newRouter()))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do sends a request as user (anonymous if empty; "editor" gets the editor
+// role) and decodes a successful JSON response into out.
+func do(t *testing.T, srv *httptest.Server, method, user, path, body string, out interface{}) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "" {
+		claims := auth.Claims{Subject: user, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+		if user == "editor" {
+			claims.Roles = []string{auth.RoleEditor}
+		}
+		token, err := testKey.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+func TestSeriesCRUD(t *testing.T) {
+	srv := newTestServer(t)
+
+	var created Series
+	resp := do(t, srv, "POST", "editor", "/api/series", `{"id":99,"title":"Dark","genre":"Sci-Fi, Drama","totalEpisodes":26}`, &created)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST: status %d, want 201", resp.StatusCode)
+	}
+	if created.ID != 1 || created.Episodes == nil || !created.Genres.Contains("sci-fi") {
+		t.Errorf("POST: got %+v", created)
+	}
+
+	var replaced Series
+	if resp := do(t, srv, "PUT", "editor", "/api/series/1", `{"title":"Dark (2017)","genres":["drama"],"totalEpisodes":26}`, &replaced); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: status %d, want 200", resp.StatusCode)
+	}
+	if replaced.ID != 1 || replaced.Title != "Dark (2017)" || replaced.Genre != "Drama" {
+		t.Errorf("PUT: got %+v", replaced)
+	}
+
+	var patched Series
+	if resp := do(t, srv, "PATCH", "editor", "/api/series/1", `{"coverUrl":"https://example.com/dark.jpg","genre":null}`, &patched); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH: status %d, want 200", resp.StatusCode)
+	}
+	if patched.Title != "Dark (2017)" || patched.CoverURL != "https://example.com/dark.jpg" || patched.TotalEpisodes != 26 {
+		t.Errorf("PATCH kept the other fields? got %+v", patched)
+	}
+
+	for _, c := range []struct {
+		method, user, path, body string
+		want                     int
+	}{
+		{"POST", "", "/api/series", `{"title":"Anonymous"}`, http.StatusUnauthorized},
+		{"POST", "viewer", "/api/series", `{"title":"Viewer"}`, http.StatusForbidden},
+		{"POST", "editor", "/api/series", `{"genre":"Drama"}`, http.StatusBadRequest},
+		{"POST", "editor", "/api/series", `{"title":"X","genres":["polka"]}`, http.StatusBadRequest},
+		{"POST", "editor", "/api/series", `not json`, http.StatusBadRequest},
+		{"PUT", "editor", "/api/series/1", `{"id":2,"title":"Dark"}`, http.StatusConflict},
+		{"PUT", "editor", "/api/series/1", `{"title":""}`, http.StatusBadRequest},
+		{"PUT", "editor", "/api/series/42", `{"title":"Missing"}`, http.StatusNotFound},
+		{"PATCH", "editor", "/api/series/1", `{"id":2}`, http.StatusConflict},
+		{"PATCH", "editor", "/api/series/1", `{"title":null}`, http.StatusBadRequest},
+		{"PATCH", "editor", "/api/series/42", `{"title":"Missing"}`, http.StatusNotFound},
+		{"GET", "", "/api/series/1", "", http.StatusOK},
+		{"DELETE", "editor", "/api/series/1", "", http.StatusNoContent},
+		{"GET", "", "/api/series/1", "", http.StatusNotFound},
+		{"DELETE", "editor", "/api/series/1", "", http.StatusNotFound},
+	} {
+		if resp := do(t, srv, c.method, c.user, c.path, c.body, nil); resp.StatusCode != c.want {
+			t.Errorf("%s %s as %q with %s: status %d, want %d", c.method, c.path, c.user, c.body, resp.StatusCode, c.want)
+		}
+	}
+}
+
+func TestPatchRequiresMergePatchContentType(t *testing.T) {
+	srv := newTestServer(t, Series{ID: 1, Title: "Dark", Episodes: []Episode{}})
+
+	req, _ := http.NewRequest("PATCH", srv.URL+"/api/series/1", strings.NewReader(`{"title":"Dark"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	token, _ := testKey.Sign(auth.Claims{Subject: "editor", Roles: []string{auth.RoleEditor}, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("status %d, want 415", resp.StatusCode)
+	}
+}
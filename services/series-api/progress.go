@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/reviews"
+)
+
+// --- Per-User Progress ---
+
+// seriesView is a series as returned to a user: the stored record plus the
+// number of its episodes the requesting user has watched, derived from the
+// progress API, and the average of its reviews. These fields are read-only
+// and ignored on input.
+type seriesView struct {
+	Series
+	WatchedEpisodes int      `json:"watchedEpisodes"`
+	AverageRating   *float64 `json:"averageRating"` // null without reviews
+	ReviewCount     int      `json:"reviewCount"`
+}
+
+// progressTimeout bounds the call to the progress API made for each response.
+const progressTimeout = 2 * time.Second
+
+// progressAPIURL is the base URL of the progress API (PROGRESS_API_URL). When
+// empty, or for anonymous requests, watchedEpisodes is 0.
+var progressAPIURL = strings.TrimRight(os.Getenv("PROGRESS_API_URL"), "/")
+
+var progressClient = &http.Client{Timeout: progressTimeout}
+
+// watchedEpisodes asks the progress API how many episodes of each series the
+// authenticated user of r has completed, forwarding the user's bearer token.
+// Failures are logged and leave the counts at 0 so the series themselves are
+// still served.
+func watchedEpisodes(r *http.Request, ids []int) map[int]int {
+	counts := make(map[int]int, len(ids))
+	if _, ok := auth.FromContext(r.Context()); !ok || progressAPIURL == "" || len(ids) == 0 {
+		return counts
+	}
+
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	q := url.Values{"kind": {"series"}, "ids": {strings.Join(parts, ",")}}
+	ctx, cancel := context.WithTimeout(r.Context(), progressTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, progressAPIURL+"/api/progress/summary?"+q.Encode(), nil)
+	if err != nil {
+		log.Printf("Error building progress request: %v", err)
+		return counts
+	}
+	req.Header.Set("Authorization", r.Header.Get("Authorization"))
+	resp, err := progressClient.Do(req)
+	if err != nil {
+		log.Printf("Error fetching watch progress: %v", err)
+		return counts
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Error fetching watch progress: unexpected HTTP status %d", resp.StatusCode)
+		return counts
+	}
+	var summaries []struct {
+		ContentID       int `json:"contentId"`
+		WatchedEpisodes int `json:"watchedEpisodes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&summaries); err != nil {
+		log.Printf("Error decoding watch progress: %v", err)
+		return counts
+	}
+	for _, s := range summaries {
+		counts[s.ContentID] = s.WatchedEpisodes
+	}
+	return counts
+}
+
+// viewsFor attaches the requesting user's watched episode counts and the
+// review averages to series. Counts are capped at totalEpisodes, as progress
+// may outlive deleted episodes.
+func viewsFor(r *http.Request, series []Series) []seriesView {
+	ids := make([]int, len(series))
+	for i, s := range series {
+		ids[i] = s.ID
+	}
+	counts := watchedEpisodes(r, ids)
+	views := make([]seriesView, len(series))
+	for i, s := range series {
+		views[i] = seriesView{Series: s, WatchedEpisodes: min(counts[s.ID], max(s.TotalEpisodes, len(s.Episodes)))}
+		summary, err := reviewRepo.Summary(reviews.Item(s.ID))
+		if err != nil {
+			log.Printf("Error loading review summary (ID: %d): %v", s.ID, err)
+			continue
+		}
+		views[i].AverageRating, views[i].ReviewCount = summary.Average(), summary.Count
+	}
+	return views
+}
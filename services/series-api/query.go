@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mbenabdallah/shared/genre"
+)
+
+// --- Listing: Filtering, Sorting and Pagination ---
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// listParams holds the parsed query parameters of GET /series.
+type listParams struct {
+	Limit  int
+	Offset int
+	Cursor *listCursor
+	Sort   string // field name, optionally prefixed with "-" for descending
+	Genre  string // genre slug
+	Query  string
+}
+
+// listCursor is the decoded form of the opaque "cursor" query parameter.
+// It records the sort position of the last item on the previous page so that
+// paging stays stable when series are added or removed in between requests.
+type listCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   int    `json:"i"`
+}
+
+var sortFields = map[string]bool{"id": true, "title": true, "genre": true}
+
+// parseListParams validates the listing query parameters.
+func parseListParams(q url.Values) (listParams, error) {
+	p := listParams{
+		Limit: defaultPageLimit,
+		Sort:  "id",
+		Query: strings.TrimSpace(q.Get("q")),
+	}
+
+	if v := strings.TrimSpace(q.Get("genre")); v != "" {
+		g, ok := genre.Lookup(v)
+		if !ok {
+			return p, fmt.Errorf("unknown genre %q", v)
+		}
+		p.Genre = g.Slug
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("limit must be a positive integer")
+		}
+		if n > maxPageLimit {
+			n = maxPageLimit
+		}
+		p.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("offset must be a non-negative integer")
+		}
+		p.Offset = n
+	}
+	if v := q.Get("sort"); v != "" {
+		if !sortFields[strings.TrimPrefix(v, "-")] {
+			return p, fmt.Errorf("sort must be one of id, title, genre (prefix with - for descending)")
+		}
+		p.Sort = v
+	}
+	if v := q.Get("cursor"); v != "" {
+		if q.Get("offset") != "" {
+			return p, fmt.Errorf("cursor and offset cannot be combined")
+		}
+		c, err := decodeCursor(v)
+		if err != nil || c.Sort != p.Sort {
+			return p, fmt.Errorf("invalid cursor")
+		}
+		p.Cursor = &c
+	}
+	return p, nil
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// sortKey returns the value a series is ordered by for the given field.
+func sortKey(s Series, field string) string {
+	switch field {
+	case "title":
+		return strings.ToLower(s.Title)
+	case "genre":
+		return strings.ToLower(s.Genre)
+	default:
+		return ""
+	}
+}
+
+// seriesLess orders two series by the sort spec, using the ID as tie-breaker
+// so the ordering is total and therefore stable between requests.
+func seriesLess(sortSpec string, aKey string, aID int, bKey string, bID int) bool {
+	desc := strings.HasPrefix(sortSpec, "-")
+	if aKey != bKey {
+		return (aKey < bKey) != desc
+	}
+	return (aID < bID) != desc
+}
+
+// matchesFilters reports whether a series satisfies the genre and q filters.
+func matchesFilters(s Series, p listParams) bool {
+	if p.Genre != "" && !s.Genres.Contains(p.Genre) {
+		return false
+	}
+	if p.Query != "" && !strings.Contains(strings.ToLower(s.Title), strings.ToLower(p.Query)) {
+		return false
+	}
+	return true
+}
+
+// listPage is one page of a filtered, sorted listing.
+type listPage struct {
+	Items      []Series
+	Total      int
+	NextCursor string
+	HasPrev    bool
+}
+
+// paginateSeries filters, sorts and slices the given series.
+func paginateSeries(all []Series, p listParams) listPage {
+	field := strings.TrimPrefix(p.Sort, "-")
+
+	filtered := make([]Series, 0, len(all))
+	for _, s := range all {
+		if matchesFilters(s, p) {
+			filtered = append(filtered, s)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		return seriesLess(p.Sort, sortKey(filtered[i], field), filtered[i].ID, sortKey(filtered[j], field), filtered[j].ID)
+	})
+
+	start := p.Offset
+	if p.Cursor != nil {
+		start = sort.Search(len(filtered), func(i int) bool {
+			return seriesLess(p.Sort, p.Cursor.Key, p.Cursor.ID, sortKey(filtered[i], field), filtered[i].ID)
+		})
+	}
+	if start > len(filtered) {
+		start = len(filtered)
+	}
+	end := start + p.Limit
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+
+	page := listPage{Items: filtered[start:end], Total: len(filtered), HasPrev: start > 0}
+	if end < len(filtered) && end > start {
+		last := filtered[end-1]
+		page.NextCursor = encodeCursor(listCursor{Sort: p.Sort, Key: sortKey(last, field), ID: last.ID})
+	}
+	return page
+}
+
+// linkHeader builds an RFC 8288 Link header with next/prev/first relations.
+func linkHeader(base *url.URL, p listParams, page listPage) string {
+	link := func(mutate func(q url.Values), rel string) string {
+		u := *base
+		q := u.Query()
+		q.Del("cursor")
+		q.Del("offset")
+		mutate(q)
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+	}
+
+	links := []string{link(func(q url.Values) {}, "first")}
+	if page.NextCursor != "" {
+		if p.Cursor != nil {
+			links = append(links, link(func(q url.Values) { q.Set("cursor", page.NextCursor) }, "next"))
+		} else {
+			next := p.Offset + len(page.Items)
+			links = append(links, link(func(q url.Values) { q.Set("offset", strconv.Itoa(next)) }, "next"))
+		}
+	}
+	if p.Cursor == nil && page.HasPrev {
+		prev := p.Offset - p.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, link(func(q url.Values) { q.Set("offset", strconv.Itoa(prev)) }, "prev"))
+	}
+	return strings.Join(links, ", ")
+}
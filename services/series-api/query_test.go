@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mbenabdallah/shared/genre"
+)
+
+func listFixtures() []Series {
+	return []Series{
+		{ID: 1, Title: "Breaking Bad", Genres: genre.List{"crime", "drama"}, Genre: "Crime, Drama"},
+		{ID: 2, Title: "Stranger Things", Genres: genre.List{"sci-fi", "horror"}, Genre: "Sci-Fi, Horror"},
+		{ID: 3, Title: "Better Call Saul", Genres: genre.List{"crime", "drama"}, Genre: "Crime, Drama"},
+		{ID: 4, Title: "Dark", Genres: genre.List{"sci-fi"}, Genre: "Sci-Fi"},
+		{ID: 5, Title: "Arcane", Genres: genre.List{"animation"}, Genre: "Animation"},
+	}
+}
+
+var linkRel = regexp.MustCompile(`<([^>]*)>; rel="(\w+)"`)
+
+// links parses a Link header into paths with queries keyed by relation.
+func links(t *testing.T, header string) map[string]string {
+	t.Helper()
+	rels := make(map[string]string)
+	for _, m := range linkRel.FindAllStringSubmatch(header, -1) {
+		u, err := url.Parse(m[1])
+		if err != nil {
+			t.Fatalf("Link %q: %v", m[1], err)
+		}
+		rels[m[2]] = u.RequestURI()
+	}
+	return rels
+}
+
+func ids(series []Series) []int {
+	out := make([]int, len(series))
+	for i, s := range series {
+		out[i] = s.ID
+	}
+	return out
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListOffsetPaging(t *testing.T) {
+	srv := newTestServer(t, listFixtures()...)
+
+	var page []Series
+	resp := do(t, srv, "GET", "", "/api/series?limit=2&offset=2", "", &page)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	if got := ids(page); !equalIDs(got, []int{3, 4}) {
+		t.Errorf("page IDs = %v, want [3 4]", got)
+	}
+	if got := resp.Header.Get("X-Total-Count"); got != "5" {
+		t.Errorf("X-Total-Count = %q, want 5", got)
+	}
+	rels := links(t, resp.Header.Get("Link"))
+	for rel, want := range map[string]string{
+		"first": "/api/series?limit=2",
+		"next":  "/api/series?limit=2&offset=4",
+		"prev":  "/api/series?limit=2&offset=0",
+	} {
+		if rels[rel] != want {
+			t.Errorf("Link rel=%s = %q, want %q", rel, rels[rel], want)
+		}
+	}
+
+	resp = do(t, srv, "GET", "", "/api/series?limit=2&offset=4", "", &page)
+	if got := ids(page); !equalIDs(got, []int{5}) {
+		t.Errorf("last page IDs = %v, want [5]", got)
+	}
+	if _, ok := links(t, resp.Header.Get("Link"))["next"]; ok || resp.Header.Get("X-Next-Cursor") != "" {
+		t.Errorf("last page advertises a next page: %q", resp.Header.Get("Link"))
+	}
+}
+
+func TestListCursorPaging(t *testing.T) {
+	srv := newTestServer(t, listFixtures()...)
+
+	var page []Series
+	resp := do(t, srv, "GET", "", "/api/series?sort=title&limit=2", "", &page)
+	seen := ids(page)
+	cursor := resp.Header.Get("X-Next-Cursor")
+	// A series added before the cursor must not shift the next page
+	seriesStore.Put(6, Series{ID: 6, Title: "Atlanta"})
+
+	for pages := 1; cursor != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor paging does not terminate")
+		}
+		path := "/api/series?" + url.Values{"cursor": {cursor}, "limit": {"2"}, "sort": {"title"}}.Encode()
+		resp := do(t, srv, "GET", "", path, "", &page)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+		}
+		seen = append(seen, ids(page)...)
+		cursor = resp.Header.Get("X-Next-Cursor")
+		next, ok := links(t, resp.Header.Get("Link"))["next"]
+		if want := "cursor=" + url.QueryEscape(cursor); cursor != "" && (!ok || !strings.Contains(next, want)) {
+			t.Errorf("next link %q does not carry %s", next, want)
+		}
+	}
+	want := []int{5, 3, 1, 4, 2}
+	if !equalIDs(seen, want) {
+		t.Errorf("IDs by title = %v, want %v", seen, want)
+	}
+}
+
+func TestListFiltersAndSorting(t *testing.T) {
+	srv := newTestServer(t, listFixtures()...)
+
+	for _, c := range []struct {
+		query string
+		want  []int
+	}{
+		{"sort=-id", []int{5, 4, 3, 2, 1}},
+		{"sort=genre", []int{5, 1, 3, 4, 2}},
+		{"genre=Crime&sort=-title", []int{1, 3}},
+		{"genre=science-fiction", []int{2, 4}},
+		{"q=BAD", []int{1}},
+		{"q=nothing", []int{}},
+		{"limit=1000", []int{1, 2, 3, 4, 5}},
+	} {
+		var page []Series
+		resp := do(t, srv, "GET", "", "/api/series?"+c.query, "", &page)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", c.query, resp.StatusCode)
+			continue
+		}
+		if got := ids(page); !equalIDs(got, c.want) {
+			t.Errorf("%s: IDs = %v, want %v", c.query, got, c.want)
+		}
+	}
+
+	byTitle := encodeCursor(listCursor{Sort: "title", Key: "dark", ID: 4})
+	for _, query := range []string{
+		"limit=0",
+		"limit=x",
+		"offset=-1",
+		"sort=rating",
+		"genre=polka",
+		"cursor=not-a-cursor",
+		"cursor=" + byTitle,                     // issued for another sort order
+		"sort=title&offset=2&cursor=" + byTitle, // cursor and offset combined
+	} {
+		if resp := do(t, srv, "GET", "", "/api/series?"+query, "", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mbenabdallah/shared/auth"
+	"github.com/mbenabdallah/shared/reviews"
+)
+
+// --- Review Sub-resource Handlers ---
+
+// Reviews of series and episodes, set up in main. Any signed-in user may
+// review; each user has one review per series and per episode.
+var reviewRepo *reviews.Repository
+
+// reviewsResponse is the body of GET .../reviews.
+type reviewsResponse struct {
+	AverageRating *float64         `json:"averageRating"` // null without reviews
+	ReviewCount   int              `json:"reviewCount"`
+	Reviews       []reviews.Review `json:"reviews"`
+}
+
+// reviewInput is the body of POST and PUT .../reviews.
+type reviewInput struct {
+	Rating int    `json:"rating"`
+	Text   string `json:"text"`
+}
+
+// registerReviewRoutes adds the review routes of series and episodes to r.
+// They are registered outside the editor-only catalogue routes.
+func registerReviewRoutes(r *mux.Router) {
+	for _, path := range []string{
+		"/api/series/{id:[0-9]+}/reviews",
+		"/api/series/{id:[0-9]+}/episodes/{episodeId:[0-9]+}/reviews",
+	} {
+		r.HandleFunc(path, getReviewsHandler).Methods("GET")
+		r.HandleFunc(path, createReviewHandler).Methods("POST")
+		r.HandleFunc(path, updateReviewHandler).Methods("PUT")
+		r.HandleFunc(path, deleteReviewHandler).Methods("DELETE")
+	}
+}
+
+// reviewTarget parses the path of a review route and checks that the series
+// (and episode) exists, writing the error response if not. The caller holds
+// storeMutex so the target cannot be deleted concurrently.
+func reviewTarget(w http.ResponseWriter, r *http.Request) (reviews.Target, bool) {
+	id, err := seriesIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return reviews.Target{}, false
+	}
+	target := reviews.Item(id)
+	if _, ok := mux.Vars(r)["episodeId"]; ok {
+		if target.EpisodeID, err = episodeIDFromRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return reviews.Target{}, false
+		}
+	}
+
+	series, exists, err := seriesStore.Get(id)
+	if err != nil {
+		log.Printf("Error loading series (ID: %d): %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return reviews.Target{}, false
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Series with ID %d not found", id), http.StatusNotFound)
+		return reviews.Target{}, false
+	}
+	if target.EpisodeID != 0 && findEpisode(series.Episodes, target.EpisodeID) < 0 {
+		http.Error(w, fmt.Sprintf("Episode with ID %d not found in series %d", target.EpisodeID, id), http.StatusNotFound)
+		return reviews.Target{}, false
+	}
+	return target, true
+}
+
+// reviewPath returns the path of a review route for logging.
+func reviewPath(t reviews.Target) string {
+	if t.EpisodeID != 0 {
+		return fmt.Sprintf("/series/%d/episodes/%d/reviews", t.ItemID, t.EpisodeID)
+	}
+	return fmt.Sprintf("/series/%d/reviews", t.ItemID)
+}
+
+// reviewUser returns the subject of the request's bearer token, writing a
+// 401 response for anonymous requests.
+func reviewUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := auth.RequestUser(w, r)
+	if !ok {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// writeReviewError maps a repository error to its response.
+func writeReviewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, reviews.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, reviews.ErrExists):
+		http.Error(w, "You have already reviewed this; use PUT to change your review", http.StatusConflict)
+	case errors.Is(err, reviews.ErrNotFound):
+		http.Error(w, "You have not reviewed this", http.StatusNotFound)
+	default:
+		log.Printf("Error storing review: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// writeReviewJSON encodes v as the JSON response body with the given status.
+func writeReviewJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding review response: %v", err)
+	}
+}
+
+// getReviewsHandler handles GET /series/{id}/reviews and
+// GET /series/{id}/episodes/{episodeId}/reviews
+func getReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	storeMutex.RLock() // Read lock
+	target, ok := reviewTarget(w, r)
+	storeMutex.RUnlock()
+	if !ok {
+		return
+	}
+
+	list, err := reviewRepo.List(target)
+	var summary reviews.Summary
+	if err == nil {
+		summary, err = reviewRepo.Summary(target)
+	}
+	if err != nil {
+		log.Printf("Error loading reviews: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeReviewJSON(w, http.StatusOK, reviewsResponse{AverageRating: summary.Average(), ReviewCount: summary.Count, Reviews: list})
+	log.Printf("Handled GET %s request", reviewPath(target))
+}
+
+// createReviewHandler handles POST .../reviews: the user's first review of the target
+func createReviewHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := reviewUser(w, r)
+	if !ok {
+		return
+	}
+	var in reviewInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	storeMutex.RLock() // Keeps the series from being deleted meanwhile
+	defer storeMutex.RUnlock()
+	target, ok := reviewTarget(w, r)
+	if !ok {
+		return
+	}
+	review, err := reviewRepo.Post(user, target, in.Rating, in.Text)
+	if err != nil {
+		writeReviewError(w, err)
+		return
+	}
+	writeReviewJSON(w, http.StatusCreated, review)
+	log.Printf("Handled POST %s request", reviewPath(target))
+}
+
+// updateReviewHandler handles PUT .../reviews: replaces the user's review of the target
+func updateReviewHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := reviewUser(w, r)
+	if !ok {
+		return
+	}
+	var in reviewInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	target, ok := reviewTarget(w, r)
+	if !ok {
+		return
+	}
+	review, err := reviewRepo.Update(user, target, in.Rating, in.Text)
+	if err != nil {
+		writeReviewError(w, err)
+		return
+	}
+	writeReviewJSON(w, http.StatusOK, review)
+	log.Printf("Handled PUT %s request", reviewPath(target))
+}
+
+// deleteReviewHandler handles DELETE .../reviews: removes the user's review of the target
+func deleteReviewHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := reviewUser(w, r)
+	if !ok {
+		return
+	}
+
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	target, ok := reviewTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := reviewRepo.Delete(user, target); err != nil {
+		writeReviewError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Handled DELETE %s request", reviewPath(target))
+}
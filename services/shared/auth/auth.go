@@ -0,0 +1,191 @@
+// Package auth authenticates requests with JSON Web Tokens and authorizes
+// them by role.
+//
+// Tokens are signed with HS256 or RS256 and verified against the keys of a
+// local JWKS file (AUTH_JWKS_FILE). The file is re-read when it changes, so
+// keys can be rotated without restarting the services. A token names its
+// user in "sub" and carries the user's roles in "roles".
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Roles known to the services
+const (
+	RoleEditor = "editor" // may create, change and delete catalogue entries
+)
+
+// Errors returned by Check, and wrapped by Verify
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("insufficient role")
+	ErrInvalidToken    = errors.New("invalid token")
+)
+
+// Config selects the key set and the expected issuer and audience.
+type Config struct {
+	JWKSFile string // JWKS file holding the verification keys
+	Issuer   string // required "iss" claim, if not empty
+	Audience string // required "aud" entry, if not empty
+}
+
+// ConfigFromEnv reads AUTH_JWKS_FILE (default defaultPath), AUTH_ISSUER and
+// AUTH_AUDIENCE.
+func ConfigFromEnv(defaultPath string) Config {
+	cfg := Config{
+		JWKSFile: os.Getenv("AUTH_JWKS_FILE"),
+		Issuer:   os.Getenv("AUTH_ISSUER"),
+		Audience: os.Getenv("AUTH_AUDIENCE"),
+	}
+	if cfg.JWKSFile == "" {
+		cfg.JWKSFile = defaultPath
+	}
+	return cfg
+}
+
+// --- Request Context ---
+
+type contextKey struct{}
+
+// tokenErrorKey holds why the bearer token of an anonymous request was rejected.
+type tokenErrorKey struct{}
+
+// NewContext returns a copy of ctx carrying the claims of the authenticated user.
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext returns the claims stored by the middleware, if the request
+// carried a valid token.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// Check returns ErrUnauthenticated if ctx has no user and ErrForbidden if the
+// user lacks role. If the request carried a token the middleware rejected, the
+// error wraps ErrInvalidToken instead. GraphQL resolvers use it to guard
+// mutations.
+func Check(ctx context.Context, role string) error {
+	claims, ok := FromContext(ctx)
+	if !ok {
+		if err, _ := ctx.Value(tokenErrorKey{}).(error); err != nil {
+			return err
+		}
+		return ErrUnauthenticated
+	}
+	if !claims.HasRole(role) {
+		return fmt.Errorf("%w: %q role required", ErrForbidden, role)
+	}
+	return nil
+}
+
+// --- HTTP Middleware ---
+
+// Authenticate verifies the bearer token of each request and stores its
+// claims in the request context. Requests without a token, or with an invalid
+// or expired one, pass through anonymously, so public routes keep working and
+// a client can still exchange its refresh token. Routes that need a user reject
+// them with RequireRole or Check, which report an invalid token as such.
+func (v *Verifier) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := BearerToken(r.Header.Get("Authorization"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, err := v.Verify(token)
+		if err != nil {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenErrorKey{}, err)))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+	})
+}
+
+// RequireRole rejects requests whose user lacks role: 401 without a valid
+// token, 403 with one. It must run behind Authenticate.
+func RequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(w, r, role) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRoleForWrites applies RequireRole to every request except GET, HEAD
+// and OPTIONS, for REST routers whose writes all need the same role.
+func RequireRoleForWrites(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !allowed(w, r, role) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestUser returns the claims of the user of r. Anonymous requests are
+// rejected with 401, which names an invalid or expired token as the cause.
+func RequestUser(w http.ResponseWriter, r *http.Request) (*Claims, bool) {
+	claims, ok := FromContext(r.Context())
+	if !ok {
+		unauthorized(w, r)
+	}
+	return claims, ok
+}
+
+// allowed writes the 401/403 response and returns false if the user of r lacks role.
+func allowed(w http.ResponseWriter, r *http.Request, role string) bool {
+	switch err := Check(r.Context(), role); {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrForbidden):
+		writeChallenge(w, http.StatusForbidden, `error="insufficient_scope"`, err.Error())
+	default:
+		unauthorized(w, r)
+	}
+	return false
+}
+
+// unauthorized writes the 401 response for an anonymous request.
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	if err, _ := r.Context().Value(tokenErrorKey{}).(error); err != nil {
+		writeChallenge(w, http.StatusUnauthorized, `error="invalid_token"`, err.Error())
+		return
+	}
+	writeChallenge(w, http.StatusUnauthorized, "", "Authentication required")
+}
+
+// BearerToken extracts the token of an "Authorization: Bearer <token>" value.
+func BearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// writeChallenge sends an error with a WWW-Authenticate header (RFC 6750).
+func writeChallenge(w http.ResponseWriter, status int, params, message string) {
+	challenge := "Bearer"
+	if params != "" {
+		challenge += " " + params
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, message, status)
+}
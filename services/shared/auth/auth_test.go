@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// writeJWKS writes keys to the JWKS file at path.
+func writeJWKS(t *testing.T, path string, keys ...JWK) {
+	t.Helper()
+	data, _ := json.Marshal(JWKS{Keys: keys})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestVerifier(t *testing.T, cfg Config, keys ...JWK) *Verifier {
+	t.Helper()
+	cfg.JWKSFile = filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, cfg.JWKSFile, keys...)
+	v, err := NewVerifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func hsJWK(kid string) JWK {
+	return JWK{Kty: "oct", Kid: kid, Alg: HS256, K: b64.EncodeToString(testSecret)}
+}
+
+func valid(sub string, roles ...string) Claims {
+	return Claims{Subject: sub, Roles: roles, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := SigningKey{ID: "rs", Algorithm: RS256, Private: rsaKey}
+	hs := SigningKey{ID: "hs", Algorithm: HS256, Secret: testSecret}
+	v := newTestVerifier(t, Config{Issuer: "auth-api", Audience: "catalog"}, hsJWK("hs"), rs.PublicJWK())
+
+	sign := func(k SigningKey, c Claims) string {
+		token, err := k.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	ok := valid("alice", RoleEditor)
+	ok.Issuer, ok.Audience = "auth-api", Audience{"catalog", "progress"}
+
+	expired := ok
+	expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	noExp := ok
+	noExp.ExpiresAt = 0
+	otherAud := ok
+	otherAud.Audience = Audience{"progress"}
+	wrongSecret := SigningKey{ID: "hs", Algorithm: HS256, Secret: []byte(strings.Repeat("x", 32))}
+	unknownKid := SigningKey{ID: "gone", Algorithm: HS256, Secret: testSecret}
+	// An HS256 token must not verify against the RSA key's public material
+	rsAsHS := SigningKey{ID: "rs", Algorithm: HS256, Secret: rsaKey.N.Bytes()}
+	refresh := ok
+	refresh.TokenUse = TokenUseRefresh
+	unsigned := b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + strings.Split(sign(hs, ok), ".")[1] + "."
+
+	for _, c := range []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"HS256", sign(hs, ok), true},
+		{"RS256", sign(rs, ok), true},
+		{"expired", sign(hs, expired), false},
+		{"no exp", sign(hs, noExp), false},
+		{"other audience", sign(hs, otherAud), false},
+		{"wrong secret", sign(wrongSecret, ok), false},
+		{"unknown kid", sign(unknownKid, ok), false},
+		{"algorithm confusion", sign(rsAsHS, ok), false},
+		{"refresh token", sign(hs, refresh), false},
+		{"alg none", unsigned, false},
+		{"garbage", "not.a.token", false},
+	} {
+		claims, err := v.Verify(c.token)
+		if c.valid && (err != nil || claims.Subject != "alice" || !claims.HasRole(RoleEditor)) {
+			t.Errorf("%s: Verify = %+v, %v; want alice with editor role", c.name, claims, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%s: token accepted", c.name)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	v := newTestVerifier(t, Config{}, hsJWK("old"))
+	next := SigningKey{ID: "new", Algorithm: HS256, Secret: testSecret}
+	token, _ := next.Sign(valid("alice"))
+	if _, err := v.Verify(token); err == nil {
+		t.Fatal("token of an unpublished key accepted")
+	}
+
+	writeJWKS(t, v.cfg.JWKSFile, hsJWK("new"))
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(v.cfg.JWKSFile, future, future)
+	v.mu.Lock()
+	v.checked = time.Time{} // skip the reload delay
+	v.mu.Unlock()
+	if _, err := v.Verify(token); err != nil {
+		t.Fatalf("token of the rotated-in key rejected: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	v := newTestVerifier(t, Config{}, hsJWK("hs"))
+	key := SigningKey{ID: "hs", Algorithm: HS256, Secret: testSecret}
+	editor, _ := key.Sign(valid("alice", RoleEditor))
+	viewer, _ := key.Sign(valid("bob"))
+	expiredClaims := valid("alice", RoleEditor)
+	expiredClaims.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	expired, _ := key.Sign(expiredClaims)
+
+	h := v.Authenticate(RequireRoleForWrites(RoleEditor, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := FromContext(r.Context()); ok {
+			w.Write([]byte(claims.Subject))
+		}
+	})))
+	for _, c := range []struct {
+		method, token string
+		want          int
+	}{
+		{"GET", "", http.StatusOK},
+		{"GET", viewer, http.StatusOK},
+		{"GET", "bogus", http.StatusOK}, // served anonymously
+		{"GET", expired, http.StatusOK},
+		{"POST", "", http.StatusUnauthorized},
+		{"POST", "bogus", http.StatusUnauthorized},
+		{"POST", expired, http.StatusUnauthorized},
+		{"POST", viewer, http.StatusForbidden},
+		{"POST", editor, http.StatusOK},
+		{"DELETE", editor, http.StatusOK},
+	} {
+		req := httptest.NewRequest(c.method, "/api/series", nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s with token %.10q: status %d, want %d", c.method, c.token, rec.Code, c.want)
+		}
+		if rec.Code != http.StatusOK && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s with token %.10q: no WWW-Authenticate challenge", c.method, c.token)
+		}
+		if rec.Code == http.StatusOK && c.token != viewer && c.token != editor && rec.Body.Len() > 0 {
+			t.Errorf("%s with token %.10q: served as %q, want anonymous", c.method, c.token, rec.Body.String())
+		}
+	}
+
+	// A rejected token is reported as invalid, not as missing
+	req := httptest.NewRequest("POST", "/api/series", nil)
+	req.Header.Set("Authorization", "Bearer "+expired)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer error="invalid_token"` {
+		t.Errorf("challenge for an expired token = %q", got)
+	}
+
+	me := v.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := RequestUser(w, r); ok {
+			w.Write([]byte(claims.Subject))
+		}
+	}))
+	for token, want := range map[string]string{"": "Bearer", expired: `Bearer error="invalid_token"`, viewer: ""} {
+		req := httptest.NewRequest("GET", "/api/progress", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		me.ServeHTTP(rec, req)
+		if got := rec.Header().Get("WWW-Authenticate"); got != want || (want == "") != (rec.Code == http.StatusOK) {
+			t.Errorf("RequestUser with token %.10q: status %d, challenge %q; want %q", token, rec.Code, got, want)
+		}
+	}
+}
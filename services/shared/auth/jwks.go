@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// --- JSON Web Key Sets (RFC 7517) ---
+
+// JWK is a JSON Web Key. Symmetric HS256 keys ("oct") carry their secret in
+// K, RSA keys their public modulus and exponent in N and E.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	K   string `json:"k,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set document.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Key is a verification key parsed from a JWK.
+type Key struct {
+	ID        string
+	Algorithm string // HS256 or RS256
+	secret    []byte
+	public    *rsa.PublicKey
+}
+
+// ParseKey converts a JWK into a verification key. Keys without an "alg"
+// get HS256 for "oct" and RS256 for "RSA".
+func ParseKey(jwk JWK) (Key, error) {
+	key := Key{ID: jwk.Kid, Algorithm: jwk.Alg}
+	switch jwk.Kty {
+	case "oct":
+		if key.Algorithm == "" {
+			key.Algorithm = HS256
+		}
+		if key.Algorithm != HS256 {
+			return Key{}, fmt.Errorf("oct key %q: unsupported algorithm %q", jwk.Kid, jwk.Alg)
+		}
+		secret, err := b64.DecodeString(jwk.K)
+		if err != nil || len(secret) < 32 {
+			return Key{}, fmt.Errorf("oct key %q: k must be a base64url secret of at least 32 bytes", jwk.Kid)
+		}
+		key.secret = secret
+	case "RSA":
+		if key.Algorithm == "" {
+			key.Algorithm = RS256
+		}
+		if key.Algorithm != RS256 {
+			return Key{}, fmt.Errorf("RSA key %q: unsupported algorithm %q", jwk.Kid, jwk.Alg)
+		}
+		n, errN := b64.DecodeString(jwk.N)
+		e, errE := b64.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return Key{}, fmt.Errorf("RSA key %q: n and e must be base64url, with a modulus of at least 2048 bits", jwk.Kid)
+		}
+		key.public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	default:
+		return Key{}, fmt.Errorf("key %q: unsupported key type %q", jwk.Kid, jwk.Kty)
+	}
+	return key, nil
+}
+
+// PublicJWK returns the JWK of an RS256 signing key's public half.
+func (k SigningKey) PublicJWK() JWK {
+	return JWK{
+		Kty: "RSA",
+		Kid: k.ID,
+		Alg: RS256,
+		Use: "sig",
+		N:   b64.EncodeToString(k.Private.N.Bytes()),
+		E:   b64.EncodeToString(big.NewInt(int64(k.Private.E)).Bytes()),
+	}
+}
+
+// parseKeySet parses a JWKS document. Keys whose "use" is not "sig" are skipped.
+func parseKeySet(data []byte) ([]Key, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	var keys []Key
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := ParseKey(jwk)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// --- Verifier ---
+
+// reloadInterval is how often the JWKS file is checked for changes. A token
+// with an unknown kid triggers a check sooner, at most once per second.
+const (
+	reloadInterval = 10 * time.Second
+	minReloadDelay = time.Second
+)
+
+// Verifier verifies tokens against the keys of a JWKS file.
+type Verifier struct {
+	cfg Config
+
+	mu      sync.Mutex
+	keys    []Key
+	modTime time.Time // of the loaded file
+	checked time.Time // last time the file was checked
+}
+
+// NewVerifier loads the key set of cfg.JWKSFile. A missing file yields an
+// empty key set, which rejects every token, so only reads stay possible; an
+// invalid file is an error.
+func NewVerifier(cfg Config) (*Verifier, error) {
+	v := &Verifier{cfg: cfg}
+	if err := v.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	if len(v.keys) == 0 {
+		log.Printf("No JWT verification keys in %s; authenticated requests will be rejected", cfg.JWKSFile)
+	} else {
+		log.Printf("Loaded %d JWT verification keys from %s", len(v.keys), cfg.JWKSFile)
+	}
+	return v, nil
+}
+
+// keysFor returns the keys a token with kid may be signed with: the key with
+// that ID, or every key if the token names none.
+func (v *Verifier) keysFor(kid string) []Key {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	if since := now.Sub(v.checked); since >= reloadInterval || (kid != "" && !v.hasKey(kid) && since >= minReloadDelay) {
+		if err := v.reload(now); err != nil {
+			log.Printf("Keeping previous JWT keys: %v", err)
+		}
+	}
+	if kid == "" {
+		return v.keys
+	}
+	for _, k := range v.keys {
+		if k.ID == kid {
+			return []Key{k}
+		}
+	}
+	return nil
+}
+
+func (v *Verifier) hasKey(kid string) bool {
+	for _, k := range v.keys {
+		if k.ID == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload re-reads the JWKS file now, for processes that just rewrote it.
+func (v *Verifier) Reload() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.modTime = time.Time{}
+	return v.reload(time.Now())
+}
+
+// reload re-reads the JWKS file if it changed. The caller holds v.mu (or
+// owns v exclusively).
+func (v *Verifier) reload(now time.Time) error {
+	v.checked = now
+	info, err := os.Stat(v.cfg.JWKSFile)
+	if os.IsNotExist(err) {
+		v.keys, v.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if v.keys != nil && info.ModTime().Equal(v.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(v.cfg.JWKSFile)
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", v.cfg.JWKSFile, err)
+	}
+	if keys == nil {
+		keys = []Key{}
+	}
+	v.keys, v.modTime = keys, info.ModTime()
+	return nil
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+// clockSkew tolerates issuers whose clock runs ahead of ours.
+const clockSkew = 30 * time.Second
+
+// TokenUseRefresh marks refresh tokens, which only the issuer accepts.
+const TokenUseRefresh = "refresh"
+
+// Claims are the JWT claims used by the services.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Username  string   `json:"preferred_username,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	TokenUse  string   `json:"token_use,omitempty"` // empty for access tokens
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`           // Unix seconds, required
+	NotBefore int64    `json:"nbf,omitempty"` // Unix seconds
+	IssuedAt  int64    `json:"iat,omitempty"` // Unix seconds
+	ID        string   `json:"jti,omitempty"`
+}
+
+// HasRole reports whether the claims grant role.
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Audience is the "aud" claim, which may be a string or an array of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("aud must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+func (a Audience) contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// --- Verification ---
+
+// Verify checks the signature, lifetime, issuer and audience of a compact
+// JWS access token and returns its claims. Errors wrap ErrInvalidToken.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	return v.verifyUse(token, "")
+}
+
+// VerifyRefresh is Verify for refresh tokens.
+func (v *Verifier) VerifyRefresh(token string) (*Claims, error) {
+	return v.verifyUse(token, TokenUseRefresh)
+}
+
+func (v *Verifier) verifyUse(token, use string) (*Claims, error) {
+	claims, err := v.verify(token, time.Now())
+	if err == nil && claims.TokenUse != use {
+		err = fmt.Errorf("token_use %q not accepted here", claims.TokenUse)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (v *Verifier) verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("malformed header: %v", err)
+	}
+	if h.Alg != HS256 && h.Alg != RS256 {
+		return nil, fmt.Errorf("unsupported algorithm %q", h.Alg)
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature")
+	}
+
+	// The key decides the algorithm: an HS256 token is never checked
+	// against an RSA key, nor the reverse.
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range v.keysFor(h.Kid) {
+		if key.Algorithm == h.Alg && key.verify(signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("signature not valid for any key (kid %q)", h.Kid)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %v", err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("missing sub claim")
+	}
+	if claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("missing exp claim")
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, fmt.Errorf("token not valid yet")
+	}
+	if v.cfg.Issuer != "" && claims.Issuer != v.cfg.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if v.cfg.Audience != "" && !claims.Audience.contains(v.cfg.Audience) {
+		return nil, fmt.Errorf("token not issued for %q", v.cfg.Audience)
+	}
+	return &claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := b64.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verify checks an HS256 or RS256 signature with the key.
+func (k *Key) verify(signed, sig []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(signed)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case RS256:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(k.public, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
+
+// --- Signing ---
+
+// SigningKey signs tokens. Secret is used for HS256, Private for RS256; ID
+// becomes the "kid" header and must match a key of the verifiers' key set.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Secret    []byte
+	Private   *rsa.PrivateKey
+}
+
+// Sign returns the compact JWS form of claims.
+func (k SigningKey) Sign(claims Claims) (string, error) {
+	h, err := json.Marshal(header{Alg: k.Algorithm, Kid: k.ID, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+
+	var sig []byte
+	switch k.Algorithm {
+	case HS256:
+		if len(k.Secret) == 0 {
+			return "", fmt.Errorf("auth: HS256 key %q has no secret", k.ID)
+		}
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case RS256:
+		if k.Private == nil {
+			return "", fmt.Errorf("auth: RS256 key %q has no private key", k.ID)
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k.Private, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("auth: unsupported algorithm %q", k.Algorithm)
+	}
+	return signed + "." + b64.EncodeToString(sig), nil
+}
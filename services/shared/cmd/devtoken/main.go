@@ -0,0 +1,66 @@
+// Command devtoken mints HS256 tokens for local development with a shared
+// secret from a JWKS file, e.g.
+//
+//	go run ./cmd/devtoken -sub alice -roles editor
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mbenabdallah/shared/auth"
+)
+
+func main() {
+	jwksFile := flag.String("jwks", "config/jwks.json", "JWKS file holding the HS256 secret")
+	kid := flag.String("kid", "", "key ID (default: the first HS256 key)")
+	sub := flag.String("sub", "dev", "subject (user ID)")
+	roles := flag.String("roles", "", "comma-separated roles, e.g. editor")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime")
+	flag.Parse()
+
+	data, err := os.ReadFile(*jwksFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var set auth.JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		log.Fatalf("parsing %s: %v", *jwksFile, err)
+	}
+	var key *auth.SigningKey
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "oct" || (*kid != "" && jwk.Kid != *kid) {
+			continue
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			log.Fatalf("key %q: %v", jwk.Kid, err)
+		}
+		key = &auth.SigningKey{ID: jwk.Kid, Algorithm: auth.HS256, Secret: secret}
+		break
+	}
+	if key == nil {
+		log.Fatalf("no HS256 key in %s", *jwksFile)
+	}
+
+	now := time.Now()
+	claims := auth.Claims{
+		Subject:   *sub,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(*ttl).Unix(),
+	}
+	if *roles != "" {
+		claims.Roles = strings.Split(*roles, ",")
+	}
+	token, err := key.Sign(claims)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(token)
+}
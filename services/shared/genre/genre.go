@@ -0,0 +1,251 @@
+// Package genre is the registry of canonical genres shared by the catalogue
+// services.
+//
+// Records store genres as a List of slugs ("crime", "sci-fi"). The free-form
+// strings used before the registry existed ("Crime Drama", "Adventure,
+// Animation, Family") are still accepted on input and parsed into a List.
+package genre
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// Genre is a registry entry.
+type Genre struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// registry lists the known genres in display order.
+var registry = []Genre{
+	{"action", "Action"},
+	{"adventure", "Adventure"},
+	{"animation", "Animation"},
+	{"comedy", "Comedy"},
+	{"crime", "Crime"},
+	{"documentary", "Documentary"},
+	{"drama", "Drama"},
+	{"family", "Family"},
+	{"fantasy", "Fantasy"},
+	{"historical", "Historical"},
+	{"horror", "Horror"},
+	{"mecha", "Mecha"},
+	{"music", "Music"},
+	{"mystery", "Mystery"},
+	{"psychological", "Psychological"},
+	{"romance", "Romance"},
+	{"sci-fi", "Sci-Fi"},
+	{"slice-of-life", "Slice of Life"},
+	{"sports", "Sports"},
+	{"supernatural", "Supernatural"},
+	{"thriller", "Thriller"},
+	{"war", "War"},
+	{"western", "Western"},
+}
+
+// aliases maps alternative spellings (in key form) to slugs.
+var aliases = map[string]string{
+	"science-fiction": "sci-fi",
+	"scifi":           "sci-fi",
+	"sf":              "sci-fi",
+	"history":         "historical",
+	"musical":         "music",
+	"sport":           "sports",
+	"animated":        "animation",
+}
+
+var (
+	bySlug = map[string]Genre{}
+	byKey  = map[string]string{} // slug, name or alias key -> slug
+)
+
+func init() {
+	for _, g := range registry {
+		bySlug[g.Slug] = g
+		byKey[g.Slug] = g.Slug
+		byKey[key(g.Name)] = g.Slug
+	}
+	for alias, slug := range aliases {
+		byKey[alias] = slug
+	}
+}
+
+// key lowercases s and joins its words with dashes ("Slice of Life" -> "slice-of-life").
+func key(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-' || r == '\t'
+	}), "-")
+}
+
+// All returns the registry in display order.
+func All() []Genre {
+	return append([]Genre(nil), registry...)
+}
+
+// Lookup finds a genre by slug, name or alias, ignoring case.
+func Lookup(s string) (Genre, bool) {
+	slug, ok := byKey[key(s)]
+	if !ok {
+		return Genre{}, false
+	}
+	return bySlug[slug], true
+}
+
+// --- Lists ---
+
+// List is a set of genre slugs in the order they were given.
+type List []string
+
+// Names returns the display names of the genres.
+func (l List) Names() []string {
+	names := make([]string, 0, len(l))
+	for _, slug := range l {
+		if g, ok := bySlug[slug]; ok {
+			names = append(names, g.Name)
+		} else {
+			names = append(names, slug)
+		}
+	}
+	return names
+}
+
+// String is the legacy display form, e.g. "Crime, Drama".
+func (l List) String() string {
+	return strings.Join(l.Names(), ", ")
+}
+
+// Contains reports whether l holds slug.
+func (l List) Contains(slug string) bool {
+	for _, s := range l {
+		if s == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownError reports values that are not in the registry.
+type UnknownError struct {
+	Values []string
+}
+
+func (e *UnknownError) Error() string {
+	return fmt.Sprintf("unknown genre %q (see the genre registry for accepted values)", strings.Join(e.Values, `", "`))
+}
+
+// Parse reads a legacy genre string. Parts are separated by commas, slashes,
+// pipes or semicolons; a part that is not a genre itself is read as
+// space-separated genres ("Crime Drama", "Sci-Fi Thriller"). Parts that
+// cannot be resolved are reported in an *UnknownError, alongside the genres
+// that were recognized.
+func Parse(s string) (List, error) {
+	return Normalize([]string{s})
+}
+
+// Normalize resolves values given as slugs, names, aliases or legacy strings
+// into a List without duplicates. Like Parse, it returns the recognized
+// genres together with an *UnknownError for the rest.
+func Normalize(values []string) (List, error) {
+	list := List{}
+	var unknown []string
+	add := func(slug string) {
+		if !list.Contains(slug) {
+			list = append(list, slug)
+		}
+	}
+	for _, v := range values {
+		parts := strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == '/' || r == '|' || r == ';'
+		})
+		for _, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if g, ok := Lookup(part); ok {
+				add(g.Slug)
+				continue
+			}
+			slugs, ok := splitWords(strings.Fields(part))
+			for _, slug := range slugs {
+				add(slug)
+			}
+			if !ok {
+				unknown = append(unknown, part)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		return list, &UnknownError{Values: unknown}
+	}
+	return list, nil
+}
+
+// splitWords resolves words greedily, preferring the longest run of words
+// that names a genre ("slice of life comedy" -> slice-of-life, comedy). ok
+// is false if some word could not be resolved.
+func splitWords(words []string) (slugs []string, ok bool) {
+	ok = true
+	for i := 0; i < len(words); {
+		j := len(words)
+		for ; j > i; j-- {
+			if g, found := Lookup(strings.Join(words[i:j], " ")); found {
+				slugs = append(slugs, g.Slug)
+				break
+			}
+		}
+		if j == i {
+			ok = false
+			j = i + 1
+		}
+		i = j
+	}
+	return slugs, ok
+}
+
+// Canonicalize resolves a record's genres in place: list wins if it is not
+// empty, otherwise the legacy string is parsed. Afterwards list holds the
+// canonical slugs and legacy their display form. On an *UnknownError both
+// still hold the genres that were recognized.
+func Canonicalize(list *List, legacy *string) error {
+	var resolved List
+	var err error
+	if len(*list) > 0 {
+		resolved, err = Normalize(*list)
+	} else {
+		resolved, err = Parse(*legacy)
+	}
+	*list, *legacy = resolved, resolved.String()
+	return err
+}
+
+// Migrate canonicalizes the genres of every record in store whose stored form
+// differs, e.g. records written before the registry existed. fields returns
+// pointers to a record's genre list and legacy string. Unknown genres are
+// logged and dropped. It returns the number of records rewritten.
+func Migrate[T any](store storage.Store[T], idOf func(T) int, fields func(*T) (*List, *string)) (int, error) {
+	records, err := store.List()
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, rec := range records {
+		list, legacy := fields(&rec)
+		before, beforeLegacy := strings.Join(*list, ","), *legacy
+		if err := Canonicalize(list, legacy); err != nil {
+			log.Printf("genre: record %d: %v; keeping %v", idOf(rec), err, *list)
+		}
+		if strings.Join(*list, ",") == before && *legacy == beforeLegacy {
+			continue
+		}
+		if err := store.Put(idOf(rec), rec); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
@@ -0,0 +1,56 @@
+package genre
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    List
+		unknown []string
+	}{
+		{"Drama, Mystery, Psychological", List{"drama", "mystery", "psychological"}, nil},
+		{"Crime Drama", List{"crime", "drama"}, nil},
+		{"Sci-Fi Thriller", List{"sci-fi", "thriller"}, nil},
+		{"science fiction / HORROR", List{"sci-fi", "horror"}, nil},
+		{"Slice of Life Comedy", List{"slice-of-life", "comedy"}, nil},
+		{"drama, Drama", List{"drama"}, nil},
+		{"", List{}, nil},
+		{"Black Comedy, Action", List{"comedy", "action"}, []string{"Black Comedy"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		var unknown *UnknownError
+		if tt.unknown == nil {
+			if err != nil {
+				t.Errorf("Parse(%q) error = %v", tt.in, err)
+			}
+		} else if !errors.As(err, &unknown) || !reflect.DeepEqual(unknown.Values, tt.unknown) {
+			t.Errorf("Parse(%q) error = %v, want unknown %v", tt.in, err, tt.unknown)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	list, legacy := List{"Sci-Fi", "thriller"}, "ignored when a list is given"
+	if err := Canonicalize(&list, &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, List{"sci-fi", "thriller"}) || legacy != "Sci-Fi, Thriller" {
+		t.Errorf("Canonicalize = %v, %q", list, legacy)
+	}
+
+	list, legacy = nil, "Adventure, Animation, Family"
+	if err := Canonicalize(&list, &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, List{"adventure", "animation", "family"}) || legacy != "Adventure, Animation, Family" {
+		t.Errorf("Canonicalize = %v, %q", list, legacy)
+	}
+}
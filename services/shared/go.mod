@@ -0,0 +1,10 @@
+module github.com/mbenabdallah/shared
+
+go 1.24.2
+
+require (
+	go.etcd.io/bbolt v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.29.0 // indirect
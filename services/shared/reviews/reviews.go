@@ -0,0 +1,355 @@
+// Package reviews stores the ratings and reviews users post about catalogue
+// items and keeps a running aggregate (count and rating total) per item.
+//
+// A review targets an item (a series, an anime, a movie) or one of its
+// episodes. Each user has at most one review per target; the services expose
+// posting, editing and deleting it through their own protocol and report the
+// aggregate as averageRating.
+package reviews
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbenabdallah/shared/storage"
+)
+
+// Rating bounds and the maximum review text length (in characters)
+const (
+	MinRating     = 1
+	MaxRating     = 10
+	MaxTextLength = 2000
+)
+
+var (
+	// ErrInvalid wraps validation errors of a rating or text.
+	ErrInvalid = errors.New("invalid review")
+	// ErrExists is returned when a user reviews a target twice.
+	ErrExists = errors.New("review already exists")
+	// ErrNotFound is returned when a user has no review of a target.
+	ErrNotFound = errors.New("review not found")
+)
+
+// Target identifies what a review is about: an item, or one of its episodes.
+type Target struct {
+	ItemID    int
+	EpisodeID int // 0 for the item itself
+}
+
+// Item returns the target of an item.
+func Item(itemID int) Target { return Target{ItemID: itemID} }
+
+// Episode returns the target of an episode of an item.
+func Episode(itemID, episodeID int) Target { return Target{ItemID: itemID, EpisodeID: episodeID} }
+
+// Review is a user's rating of a target with an optional text.
+type Review struct {
+	ID        int       `json:"id"`
+	ItemID    int       `json:"itemId"`
+	EpisodeID int       `json:"episodeId,omitempty"`
+	UserID    string    `json:"userId"`
+	Rating    int       `json:"rating"`
+	Text      string    `json:"text,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (r Review) target() Target { return Target{ItemID: r.ItemID, EpisodeID: r.EpisodeID} }
+
+// Summary is the aggregate of the reviews of a target.
+type Summary struct {
+	Count int `json:"count"`
+	Total int `json:"total"` // sum of the ratings
+}
+
+// Average returns the mean rating rounded to two decimals, or nil if the
+// target has no reviews.
+func (s Summary) Average() *float64 {
+	if s.Count == 0 {
+		return nil
+	}
+	avg := math.Round(float64(s.Total)/float64(s.Count)*100) / 100
+	return &avg
+}
+
+// summaryRecord is a Summary as stored.
+type summaryRecord struct {
+	ID        int `json:"id"`
+	ItemID    int `json:"itemId"`
+	EpisodeID int `json:"episodeId,omitempty"`
+	Summary
+}
+
+// Validate checks a rating and returns the trimmed text.
+func Validate(rating int, text string) (string, error) {
+	if rating < MinRating || rating > MaxRating {
+		return "", fmt.Errorf("%w: rating must be between %d and %d", ErrInvalid, MinRating, MaxRating)
+	}
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) > MaxTextLength {
+		return "", fmt.Errorf("%w: text must be at most %d characters", ErrInvalid, MaxTextLength)
+	}
+	return text, nil
+}
+
+// --- Repository ---
+
+// reviewKey identifies the review of one user of one target.
+type reviewKey struct {
+	Target
+	UserID string
+}
+
+// Repository is the concurrency-safe access point for reviews. Reviews and
+// summaries live in two collections of the service's storage backend; the
+// indexes mapping keys to record IDs are rebuilt at startup.
+type Repository struct {
+	mu        sync.RWMutex
+	reviews   storage.Store[Review]
+	summaries storage.Store[summaryRecord]
+	byKey     map[reviewKey]int
+	byTarget  map[Target]int // summary ID
+}
+
+// Open returns the repository kept in the "reviews" and "review_summaries"
+// collections of b. Summaries that disagree with the stored reviews (e.g.
+// after a crash between the two writes) are recomputed.
+func Open(b storage.Backend) (*Repository, error) {
+	reviews, err := storage.New[Review](b, "reviews")
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := storage.New[summaryRecord](b, "review_summaries")
+	if err != nil {
+		return nil, err
+	}
+	r := &Repository{reviews: reviews, summaries: summaries, byKey: map[reviewKey]int{}, byTarget: map[Target]int{}}
+
+	all, err := reviews.List()
+	if err != nil {
+		return nil, err
+	}
+	want := map[Target]Summary{}
+	for _, rev := range all {
+		r.byKey[reviewKey{rev.target(), rev.UserID}] = rev.ID
+		s := want[rev.target()]
+		s.Count++
+		s.Total += rev.Rating
+		want[rev.target()] = s
+	}
+	records, err := summaries.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		t := Target{ItemID: rec.ItemID, EpisodeID: rec.EpisodeID}
+		r.byTarget[t] = rec.ID
+		if rec.Summary != want[t] {
+			if err := r.putSummary(t, want[t]); err != nil {
+				return nil, err
+			}
+		}
+		delete(want, t)
+	}
+	for t, s := range want {
+		if err := r.putSummary(t, s); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Post stores the first review of a user for a target.
+func (r *Repository) Post(userID string, t Target, rating int, text string) (Review, error) {
+	text, err := Validate(rating, text)
+	if err != nil {
+		return Review{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := reviewKey{t, userID}
+	if _, exists := r.byKey[key]; exists {
+		return Review{}, ErrExists
+	}
+	id, err := r.reviews.NextID()
+	if err != nil {
+		return Review{}, err
+	}
+	now := time.Now().UTC()
+	rev := Review{ID: id, ItemID: t.ItemID, EpisodeID: t.EpisodeID, UserID: userID, Rating: rating, Text: text, CreatedAt: now, UpdatedAt: now}
+	if err := r.reviews.Put(id, rev); err != nil {
+		return Review{}, err
+	}
+	r.byKey[key] = id
+	return rev, r.adjust(t, 1, rating)
+}
+
+// Update replaces the rating and text of a user's review of a target.
+func (r *Repository) Update(userID string, t Target, rating int, text string) (Review, error) {
+	text, err := Validate(rating, text)
+	if err != nil {
+		return Review{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rev, err := r.get(reviewKey{t, userID})
+	if err != nil {
+		return Review{}, err
+	}
+	delta := rating - rev.Rating
+	rev.Rating, rev.Text, rev.UpdatedAt = rating, text, time.Now().UTC()
+	if err := r.reviews.Put(rev.ID, rev); err != nil {
+		return Review{}, err
+	}
+	return rev, r.adjust(t, 0, delta)
+}
+
+// Delete removes a user's review of a target.
+func (r *Repository) Delete(userID string, t Target) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := reviewKey{t, userID}
+	rev, err := r.get(key)
+	if err != nil {
+		return err
+	}
+	if _, err := r.reviews.Delete(rev.ID); err != nil {
+		return err
+	}
+	delete(r.byKey, key)
+	return r.adjust(t, -1, -rev.Rating)
+}
+
+// List returns the reviews of a target, newest first.
+func (r *Repository) List(t Target) ([]Review, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := []Review{}
+	for key, id := range r.byKey {
+		if key.Target != t {
+			continue
+		}
+		rev, ok, err := r.reviews.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			list = append(list, rev)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+// Summary returns the aggregate of a target.
+func (r *Repository) Summary(t Target) (Summary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byTarget[t]
+	if !ok {
+		return Summary{}, nil
+	}
+	rec, _, err := r.summaries.Get(id)
+	return rec.Summary, err
+}
+
+// DeleteItem removes every review of an item and its episodes, for when the
+// item is deleted. It returns how many reviews were removed.
+func (r *Repository) DeleteItem(itemID int) (int, error) {
+	return r.purge(func(t Target) bool { return t.ItemID == itemID })
+}
+
+// DeleteEpisode removes every review of one episode.
+func (r *Repository) DeleteEpisode(itemID, episodeID int) (int, error) {
+	return r.purge(func(t Target) bool { return t == Episode(itemID, episodeID) })
+}
+
+func (r *Repository) purge(match func(Target) bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := 0
+	for key, id := range r.byKey {
+		if !match(key.Target) {
+			continue
+		}
+		if _, err := r.reviews.Delete(id); err != nil {
+			return removed, err
+		}
+		delete(r.byKey, key)
+		removed++
+	}
+	for t, id := range r.byTarget {
+		if !match(t) {
+			continue
+		}
+		if _, err := r.summaries.Delete(id); err != nil {
+			return removed, err
+		}
+		delete(r.byTarget, t)
+	}
+	return removed, nil
+}
+
+// get returns the review for key. The caller holds r.mu.
+func (r *Repository) get(key reviewKey) (Review, error) {
+	id, ok := r.byKey[key]
+	if !ok {
+		return Review{}, ErrNotFound
+	}
+	rev, ok, err := r.reviews.Get(id)
+	if err != nil {
+		return Review{}, err
+	}
+	if !ok {
+		return Review{}, ErrNotFound
+	}
+	return rev, nil
+}
+
+// adjust adds count and total to the summary of t. The caller holds r.mu.
+func (r *Repository) adjust(t Target, count, total int) error {
+	var s Summary
+	if id, ok := r.byTarget[t]; ok {
+		rec, _, err := r.summaries.Get(id)
+		if err != nil {
+			return err
+		}
+		s = rec.Summary
+	}
+	s.Count += count
+	s.Total += total
+	return r.putSummary(t, s)
+}
+
+// putSummary stores the summary of t. The caller holds r.mu (or owns r
+// exclusively).
+func (r *Repository) putSummary(t Target, s Summary) error {
+	id, ok := r.byTarget[t]
+	if !ok {
+		var err error
+		if id, err = r.summaries.NextID(); err != nil {
+			return err
+		}
+	}
+	if err := r.summaries.Put(id, summaryRecord{ID: id, ItemID: t.ItemID, EpisodeID: t.EpisodeID, Summary: s}); err != nil {
+		return err
+	}
+	r.byTarget[t] = id
+	return nil
+}
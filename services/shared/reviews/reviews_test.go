@@ -0,0 +1,106 @@
+package reviews
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mbenabdallah/shared/storage"
+)
+
+func average(t *testing.T, r *Repository, target Target) float64 {
+	t.Helper()
+	s, err := r.Summary(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Average() == nil {
+		return 0
+	}
+	return *s.Average()
+}
+
+func TestRepository(t *testing.T) {
+	r, err := Open(storage.NewMemoryBackend())
+	if err != nil {
+		t.Fatal(err)
+	}
+	show, pilot := Item(1), Episode(1, 10)
+
+	if _, err := r.Post("alice", show, 8, "  Great  "); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Post("alice", show, 9, ""); !errors.Is(err, ErrExists) {
+		t.Errorf("second review: err = %v, want ErrExists", err)
+	}
+	rev, err := r.Post("bob", show, 5, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Post("alice", pilot, 10, ""); err != nil {
+		t.Fatal(err) // an episode is a separate target
+	}
+	for _, rating := range []int{0, 11} {
+		if _, err := r.Post("carol", show, rating, ""); !errors.Is(err, ErrInvalid) {
+			t.Errorf("rating %d: err = %v, want ErrInvalid", rating, err)
+		}
+	}
+	if got := average(t, r, show); got != 6.5 {
+		t.Errorf("average = %v, want 6.5", got)
+	}
+
+	if _, err := r.Update("bob", show, 6, "Better on rewatch"); err != nil {
+		t.Fatal(err)
+	}
+	if got := average(t, r, show); got != 7 {
+		t.Errorf("average after update = %v, want 7", got)
+	}
+	list, err := r.List(show)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != rev.ID || list[1].Text != "Great" {
+		t.Errorf("List = %+v", list)
+	}
+
+	if err := r.Delete("alice", show); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete("alice", show); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: err = %v, want ErrNotFound", err)
+	}
+	if got := average(t, r, show); got != 6 {
+		t.Errorf("average after delete = %v, want 6", got)
+	}
+
+	if n, err := r.DeleteItem(1); err != nil || n != 2 {
+		t.Errorf("DeleteItem = %d, %v; want 2", n, err)
+	}
+	if s, _ := r.Summary(pilot); s.Average() != nil || s.Count != 0 {
+		t.Errorf("episode summary after DeleteItem = %+v", s)
+	}
+}
+
+func TestOpenRepairsSummaries(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	r, err := Open(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Post("alice", Item(1), 4, "")
+	r.Post("bob", Item(1), 8, "")
+
+	// Simulate a crash between writing a review and its summary
+	summaries, _ := storage.New[summaryRecord](backend, "review_summaries")
+	summaries.Put(r.byTarget[Item(1)], summaryRecord{ID: r.byTarget[Item(1)], ItemID: 1, Summary: Summary{Count: 1, Total: 4}})
+
+	r, err = Open(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := average(t, r, Item(1)); got != 6 {
+		t.Errorf("average after reopening = %v, want 6", got)
+	}
+	if _, err := r.Post("alice", Item(1), 1, ""); !errors.Is(err, ErrExists) {
+		t.Errorf("review after reopening: err = %v, want ErrExists", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Package seed loads catalogue fixtures from JSON or YAML files into a
+// storage.Store at service startup.
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mbenabdallah/shared/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Mode controls when fixtures are applied to a store.
+type Mode string
+
+const (
+	// IfEmpty seeds only a store that holds no records (the default).
+	IfEmpty Mode = "if-empty"
+	// Always upserts every fixture, overwriting records with the same ID.
+	Always Mode = "always"
+	// Never skips seeding entirely.
+	Never Mode = "never"
+)
+
+// Options configures where fixtures are read from and when they are applied.
+type Options struct {
+	Dir  string
+	Mode Mode
+}
+
+// OptionsFromEnv reads SEED_DIR and SEED_MODE, falling back to defaultDir and IfEmpty.
+func OptionsFromEnv(defaultDir string) Options {
+	opts := Options{
+		Dir:  strings.TrimSpace(os.Getenv("SEED_DIR")),
+		Mode: Mode(strings.ToLower(strings.TrimSpace(os.Getenv("SEED_MODE")))),
+	}
+	if opts.Dir == "" {
+		opts.Dir = defaultDir
+	}
+	if opts.Mode == "" {
+		opts.Mode = IfEmpty
+	}
+	return opts
+}
+
+// extensions lists the fixture file extensions in lookup order.
+var extensions = []string{".json", ".yaml", ".yml"}
+
+// Load reads the fixture file <dir>/<name>.json (or .yaml/.yml) into a slice
+// of T. Fields that do not exist on T are rejected so typos in fixtures are
+// reported instead of silently dropped.
+func Load[T any](dir, name string) ([]T, error) {
+	for _, ext := range extensions {
+		path := filepath.Join(dir, name+ext)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("seed: reading %s: %w", path, err)
+		}
+		if ext != ".json" {
+			if data, err = yamlToJSON(data); err != nil {
+				return nil, fmt.Errorf("seed: parsing %s: %w", path, err)
+			}
+		}
+		var items []T
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&items); err != nil {
+			return nil, fmt.Errorf("seed: decoding %s: %w", path, err)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("seed: no %s fixture (%s) found in %s", name, strings.Join(extensions, ", "), dir)
+}
+
+// yamlToJSON converts a YAML document to JSON so that fixtures in either
+// format are decoded through the same json struct tags.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// Apply loads the fixture called name and writes it to store according to
+// opts.Mode. idOf returns a record's ID and validate checks a single record;
+// duplicate or non-positive IDs are rejected before anything is written.
+// It returns the number of records written.
+func Apply[T any](store storage.Store[T], opts Options, name string, idOf func(T) int, validate func(T) error) (int, error) {
+	switch opts.Mode {
+	case Never:
+		log.Printf("Seeding of %s skipped (SEED_MODE=never)", name)
+		return 0, nil
+	case IfEmpty:
+		n, err := store.Len()
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			log.Printf("Seeding of %s skipped: store already holds %d records", name, n)
+			return 0, nil
+		}
+	case Always:
+	default:
+		return 0, fmt.Errorf("seed: unknown mode %q", opts.Mode)
+	}
+
+	items, err := Load[T](opts.Dir, name)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[int]bool, len(items))
+	for i, item := range items {
+		id := idOf(item)
+		if id <= 0 {
+			return 0, fmt.Errorf("seed: %s[%d]: id must be a positive integer", name, i)
+		}
+		if seen[id] {
+			return 0, fmt.Errorf("seed: %s[%d]: duplicate id %d", name, i, id)
+		}
+		seen[id] = true
+		if err := validate(item); err != nil {
+			return 0, fmt.Errorf("seed: %s[%d] (id %d): %w", name, i, id, err)
+		}
+	}
+
+	for _, item := range items {
+		if err := store.Put(idOf(item), item); err != nil {
+			return 0, err
+		}
+	}
+	log.Printf("Seeded %d %s from %s", len(items), name, opts.Dir)
+	return len(items), nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltBackend persists collections as buckets of a single bbolt database file.
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+// OpenBoltBackend opens (or creates) the database file at path.
+func OpenBoltBackend(path string) (*BoltBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("storage: creating data directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("storage: opening %s: %w", path, err)
+	}
+	return &BoltBackend{db: db}, nil
+}
+
+// Collection returns the named collection, creating its bucket if needed.
+func (b *BoltBackend) Collection(name string) (Collection, error) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(name))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage: creating bucket %s: %w", name, err)
+	}
+	return &boltCollection{db: b.db, bucket: []byte(name)}, nil
+}
+
+// Close closes the database file.
+func (b *BoltBackend) Close() error { return b.db.Close() }
+
+type boltCollection struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// itob encodes an ID big-endian so bucket iteration follows numeric order.
+func itob(id int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func (c *boltCollection) Keys() ([]int, error) {
+	var ids []int
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(c.bucket).ForEach(func(k, _ []byte) error {
+			ids = append(ids, int(binary.BigEndian.Uint64(k)))
+			return nil
+		})
+	})
+	return ids, err
+}
+
+func (c *boltCollection) Get(id int) ([]byte, bool, error) {
+	var data []byte
+	err := c.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(c.bucket).Get(itob(id)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return data, data != nil, err
+}
+
+func (c *boltCollection) Put(id int, data []byte) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(c.bucket)
+		// Keep the bucket sequence ahead of explicitly chosen IDs so NextID
+		// never hands out an ID that is already in use.
+		if uint64(id) > b.Sequence() {
+			if err := b.SetSequence(uint64(id)); err != nil {
+				return err
+			}
+		}
+		return b.Put(itob(id), data)
+	})
+}
+
+func (c *boltCollection) Delete(id int) (bool, error) {
+	var existed bool
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(c.bucket)
+		existed = b.Get(itob(id)) != nil
+		return b.Delete(itob(id))
+	})
+	return existed, err
+}
+
+func (c *boltCollection) NextID() (int, error) {
+	var id uint64
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		id, err = tx.Bucket(c.bucket).NextSequence()
+		return err
+	})
+	return int(id), err
+}
+
+func (c *boltCollection) Len() (int, error) {
+	var n int
+	err := c.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(c.bucket).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
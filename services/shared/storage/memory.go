@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryBackend keeps collections in process memory. Data is lost on restart.
+type MemoryBackend struct {
+	mu          sync.Mutex
+	collections map[string]*memoryCollection
+}
+
+// NewMemoryBackend returns an empty in-memory backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{collections: make(map[string]*memoryCollection)}
+}
+
+// Collection returns the named collection, creating it on first use.
+func (b *MemoryBackend) Collection(name string) (Collection, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.collections[name]
+	if !ok {
+		c = &memoryCollection{records: make(map[int][]byte)}
+		b.collections[name] = c
+	}
+	return c, nil
+}
+
+// Close is a no-op for the in-memory backend.
+func (b *MemoryBackend) Close() error { return nil }
+
+type memoryCollection struct {
+	mu      sync.RWMutex
+	records map[int][]byte
+	lastID  int
+}
+
+func (c *memoryCollection) Keys() ([]int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ids := make([]int, 0, len(c.records))
+	for id := range c.records {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (c *memoryCollection) Get(id int) ([]byte, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	data, ok := c.records[id]
+	return data, ok, nil
+}
+
+func (c *memoryCollection) Put(id int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records[id] = append([]byte(nil), data...)
+	if id > c.lastID {
+		c.lastID = id
+	}
+	return nil
+}
+
+func (c *memoryCollection) Delete(id int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.records[id]
+	delete(c.records, id)
+	return ok, nil
+}
+
+func (c *memoryCollection) NextID() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastID++
+	return c.lastID, nil
+}
+
+func (c *memoryCollection) Len() (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.records), nil
+}
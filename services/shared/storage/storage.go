@@ -0,0 +1,138 @@
+// Package storage provides the record store shared by the catalogue services.
+//
+// A Backend holds one or more named collections of raw records. Store wraps a
+// collection and encodes records of a concrete type as JSON, so every backend
+// hands out independent copies and callers never share slices or maps with
+// the underlying storage.
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Store is a collection of records of type T keyed by integer ID.
+type Store[T any] interface {
+	// List returns all records ordered by ascending ID.
+	List() ([]T, error)
+	// Get returns the record with the given ID and whether it exists.
+	Get(id int) (T, bool, error)
+	// Put inserts or replaces the record with the given ID.
+	Put(id int, v T) error
+	// Delete removes the record with the given ID and reports whether it existed.
+	Delete(id int) (bool, error)
+	// NextID reserves and returns an ID greater than any ID stored so far.
+	NextID() (int, error)
+	// Len returns the number of records in the collection.
+	Len() (int, error)
+}
+
+// Collection is the raw, byte-oriented form of a Store implemented by backends.
+type Collection interface {
+	Keys() ([]int, error)
+	Get(id int) ([]byte, bool, error)
+	Put(id int, data []byte) error
+	Delete(id int) (bool, error)
+	NextID() (int, error)
+	Len() (int, error)
+}
+
+// Backend opens named collections. Close releases any underlying resources.
+type Backend interface {
+	Collection(name string) (Collection, error)
+	Close() error
+}
+
+// Config selects and configures a Backend.
+type Config struct {
+	Backend string // "memory" or "bolt"
+	Path    string // database file for the bolt backend
+}
+
+// ConfigFromEnv reads STORAGE_BACKEND and STORAGE_PATH, falling back to the
+// in-memory backend and defaultPath respectively.
+func ConfigFromEnv(defaultPath string) Config {
+	cfg := Config{
+		Backend: strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND"))),
+		Path:    strings.TrimSpace(os.Getenv("STORAGE_PATH")),
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = "memory"
+	}
+	if cfg.Path == "" {
+		cfg.Path = defaultPath
+	}
+	return cfg
+}
+
+// Open creates the Backend described by cfg.
+func Open(cfg Config) (Backend, error) {
+	switch cfg.Backend {
+	case "", "memory":
+		return NewMemoryBackend(), nil
+	case "bolt":
+		return OpenBoltBackend(cfg.Path)
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", cfg.Backend)
+	}
+}
+
+// New returns a Store for the named collection of backend b.
+func New[T any](b Backend, name string) (Store[T], error) {
+	c, err := b.Collection(name)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonStore[T]{c: c}, nil
+}
+
+// jsonStore adapts a raw Collection to a typed Store using JSON encoding.
+type jsonStore[T any] struct {
+	c Collection
+}
+
+func (s *jsonStore[T]) List() ([]T, error) {
+	ids, err := s.c.Keys()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, 0, len(ids))
+	for _, id := range ids {
+		v, ok, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			items = append(items, v)
+		}
+	}
+	return items, nil
+}
+
+func (s *jsonStore[T]) Get(id int) (T, bool, error) {
+	var v T
+	data, ok, err := s.c.Get(id)
+	if err != nil || !ok {
+		return v, ok, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, false, fmt.Errorf("storage: decoding record %d: %w", id, err)
+	}
+	return v, true, nil
+}
+
+func (s *jsonStore[T]) Put(id int, v T) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("storage: encoding record %d: %w", id, err)
+	}
+	return s.c.Put(id, data)
+}
+
+func (s *jsonStore[T]) Delete(id int) (bool, error) { return s.c.Delete(id) }
+
+func (s *jsonStore[T]) NextID() (int, error) { return s.c.NextID() }
+
+func (s *jsonStore[T]) Len() (int, error) { return s.c.Len() }
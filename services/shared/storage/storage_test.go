@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type record struct {
+	ID   int      `json:"id"`
+	Tags []string `json:"tags"`
+}
+
+// testStore runs the Store contract against a collection of backend b.
+func testStore(t *testing.T, b Backend) {
+	t.Helper()
+	s, err := New[record](b, "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := s.Get(1); ok || err != nil {
+		t.Fatalf("Get of a missing record = %v, %v; want not found", ok, err)
+	}
+	first, err := s.NextID()
+	if err != nil || first != 1 {
+		t.Fatalf("first NextID = %d, %v; want 1", first, err)
+	}
+	tags := []string{"a"}
+	if err := s.Put(first, record{ID: first, Tags: tags}); err != nil {
+		t.Fatal(err)
+	}
+	tags[0] = "changed" // the store keeps its own copy
+	if err := s.Put(5, record{ID: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(3, record{ID: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := s.Get(first)
+	if err != nil || !ok || !reflect.DeepEqual(got, record{ID: 1, Tags: []string{"a"}}) {
+		t.Errorf("Get(1) = %+v, %v, %v", got, ok, err)
+	}
+	got.Tags[0] = "changed" // nor does it share the copy it returns
+	if again, _, _ := s.Get(first); again.Tags[0] != "a" {
+		t.Errorf("Get returned a record sharing memory with the store")
+	}
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].ID != 1 || list[1].ID != 3 || list[2].ID != 5 {
+		t.Errorf("List = %+v, want IDs 1, 3, 5", list)
+	}
+	if n, err := s.Len(); n != 3 || err != nil {
+		t.Errorf("Len = %d, %v; want 3", n, err)
+	}
+
+	// NextID stays ahead of explicit IDs and of deleted records
+	if existed, err := s.Delete(5); !existed || err != nil {
+		t.Errorf("Delete(5) = %v, %v; want true", existed, err)
+	}
+	if existed, err := s.Delete(5); existed || err != nil {
+		t.Errorf("second Delete(5) = %v, %v; want false", existed, err)
+	}
+	if id, err := s.NextID(); id != 6 || err != nil {
+		t.Errorf("NextID after Put(5) and Delete(5) = %d, %v; want 6", id, err)
+	}
+	if id, _ := s.NextID(); id != 7 {
+		t.Errorf("NextID reused or skipped an ID: %d, want 7", id)
+	}
+
+	// Collections are independent
+	other, err := New[record](b, "others")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := other.Len(); n != 0 {
+		t.Errorf("another collection has %d records", n)
+	}
+	if id, _ := other.NextID(); id != 1 {
+		t.Errorf("NextID of another collection = %d, want 1", id)
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	testStore(t, NewMemoryBackend())
+}
+
+func TestBoltBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "test.db")
+	b, err := OpenBoltBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, b)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records and the ID sequence survive reopening
+	b, err = OpenBoltBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	s, err := New[record](b, "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.List()
+	if err != nil || len(list) != 2 || list[0].Tags[0] != "a" {
+		t.Errorf("List after reopening = %+v, %v", list, err)
+	}
+	if id, err := s.NextID(); id != 8 || err != nil {
+		t.Errorf("NextID after reopening = %d, %v; want 8", id, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open(Config{Backend: "cassandra"}); err == nil {
+		t.Error("unknown backend accepted")
+	}
+	b, err := Open(Config{Backend: "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.(*MemoryBackend); !ok {
+		t.Errorf("Open(memory) = %T", b)
+	}
+}